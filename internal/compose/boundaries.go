@@ -0,0 +1,235 @@
+package compose
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+// Confidence of the different indicators of a section boundary. When several
+// indicators point to the same measure, the confidences are combined.
+const (
+	confidenceFinalBarline  = 0.9
+	confidenceDoubleBarline = 0.8
+	confidenceRepeat        = 0.8
+	confidenceKeyChange     = 0.7
+	confidenceTimeChange    = 0.7
+	confidenceTempoChange   = 0.6
+	confidencePhrase16      = 0.5
+	confidencePhrase8       = 0.4
+
+	phraseLength = 8
+)
+
+// Words that mark tempo or character of a section
+var tempoWords = []string{
+	"adagio", "agitato", "allegretto", "allegro", "andante", "andantino", "animato",
+	"furioso", "grave", "hurry", "larghetto", "largo", "lento", "maestoso", "misterioso",
+	"moderato", "mosso", "prestissimo", "presto", "tempo", "tranquillo", "vivace", "vivo",
+}
+
+// boundary marks the first measure of a new section
+type boundary struct {
+	measure    int
+	confidence float64
+	reasons    []string
+}
+
+func tempoWord(text string) string {
+	for _, word := range split(normalize(text)) {
+		if slices.Contains(tempoWords, word) {
+			return word
+		}
+	}
+	return ""
+}
+
+type boundaryCollector struct {
+	numMeasures int
+	found       map[int]*boundary
+}
+
+func (b *boundaryCollector) add(measure int, confidence float64, reason string) {
+	if measure <= 0 || measure >= b.numMeasures {
+		return
+	}
+	current, ok := b.found[measure]
+	if !ok {
+		current = &boundary{measure: measure}
+		b.found[measure] = current
+	}
+	current.confidence = 1.0 - (1.0-current.confidence)*(1.0-confidence)
+	current.reasons = append(current.reasons, reason)
+}
+
+func (b *boundaryCollector) sorted() []boundary {
+	result := make([]boundary, 0, len(b.found))
+	for _, item := range b.found {
+		result = append(result, *item)
+	}
+	slices.SortFunc(result, func(b1, b2 boundary) int {
+		return b1.measure - b2.measure
+	})
+	return result
+}
+
+// detectBoundaries guesses where sections start from barlines, key, time
+// signature and tempo changes. Long stretches without any such indicator are
+// split according to regular phrase lengths.
+func detectBoundaries(measures []musicxml.Measure) []boundary {
+	collector := boundaryCollector{numMeasures: len(measures), found: make(map[int]*boundary)}
+
+	var (
+		fifths       *int
+		timeSig      *musicxml.Timesignature
+		currentWord  string
+		currentTempo int
+	)
+	for i, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if barline := element.Barline; barline != nil {
+				target := i + 1
+				if barline.LocationAttr == "left" {
+					target = i
+				}
+				if barline.Repeat != nil {
+					collector.add(target, confidenceRepeat, "repeat")
+				}
+				if barline.Barstyle != nil {
+					switch barline.Barstyle.Value {
+					case musicxml.BarStyle(musicxml.BarStyleLightHeavy).String():
+						collector.add(target, confidenceFinalBarline, "final barline")
+					case musicxml.BarStyle(musicxml.BarStyleLightLight).String(), musicxml.BarStyle(musicxml.BarStyleHeavyLight).String(), musicxml.BarStyle(musicxml.BarStyleHeavyHeavy).String():
+						collector.add(target, confidenceDoubleBarline, "double barline")
+					}
+				}
+			}
+
+			if attr := element.Attributes; attr != nil {
+				for _, key := range attr.Key {
+					if fifths != nil && *fifths != key.Fifths {
+						collector.add(i, confidenceKeyChange, "key change")
+					}
+					fifths = &key.Fifths
+				}
+				for _, ts := range attr.Time {
+					if timeSig != nil && *timeSig != ts {
+						collector.add(i, confidenceTimeChange, "time signature change")
+					}
+					timeSig = &ts
+				}
+			}
+
+			if direction := element.Direction; direction != nil {
+				for _, dirType := range direction.Directiontype {
+					for _, words := range dirType.Words {
+						if word := tempoWord(words.Value); word != "" {
+							if currentWord != "" && word != currentWord {
+								collector.add(i, confidenceTempoChange, "tempo marking")
+							}
+							currentWord = word
+						}
+					}
+					if dirType.Metronome != nil && dirType.Metronome.Perminute != nil {
+						tempo := dirType.Metronome.Perminute.Value
+						if currentTempo != 0 && tempo != currentTempo {
+							collector.add(i, confidenceTempoChange, "metronome change")
+						}
+						currentTempo = tempo
+					}
+				}
+			}
+		}
+	}
+
+	// Split long stretches into phrases
+	starts := []int{0}
+	for _, b := range collector.sorted() {
+		starts = append(starts, b.measure)
+	}
+	starts = append(starts, len(measures))
+	for i := 0; i < len(starts)-1; i++ {
+		if starts[i+1]-starts[i] <= 2*phraseLength {
+			continue
+		}
+		for m := starts[i] + phraseLength; m < starts[i+1]; m += phraseLength {
+			if (m-starts[i])%(2*phraseLength) == 0 {
+				collector.add(m, confidencePhrase16, "16 bar phrase")
+			} else {
+				collector.add(m, confidencePhrase8, "8 bar phrase")
+			}
+		}
+	}
+	return collector.sorted()
+}
+
+func rehearsalBoundaries(measures []musicxml.Measure) []boundary {
+	var result []boundary
+	for i, measure := range measures {
+		if hasRehersalMark(measure.MusicDataElements) && i > 0 {
+			result = append(result, boundary{measure: i, confidence: 1.0, reasons: []string{"rehearsal mark"}})
+		}
+	}
+	return result
+}
+
+func sectionsFromBoundaries(boundaries []boundary, numMeasures int) []section {
+	var sections []section
+	start := 0
+	for _, b := range boundaries {
+		if b.measure > start && b.measure < numMeasures {
+			sections = append(sections, section{start: start, end: b.measure})
+			start = b.measure
+		}
+	}
+	if numMeasures > 0 {
+		sections = append(sections, section{start: start, end: numMeasures})
+	}
+	return sections
+}
+
+// sectionsWithDetection splits the measures into sections according to the
+// section detection in the options
+func sectionsWithDetection(measures []musicxml.Measure, opts *composeOptions) []section {
+	explicit := rehearsalBoundaries(measures)
+	if !usesDetectedBoundaries(explicit, opts) {
+		return sectionsFromBoundaries(explicit, len(measures))
+	}
+	detected := detectBoundaries(measures)
+	for _, b := range detected {
+		slog.Debug("Detected section boundary", "measure", b.measure, "confidence", b.confidence, "reasons", b.reasons)
+	}
+	return chooseSections(explicit, detected, len(measures), opts)
+}
+
+// usesDetectedBoundaries returns true if the section detection in the options
+// adds detected boundaries to the rehearsal marks of a piece
+func usesDetectedBoundaries(explicit []boundary, opts *composeOptions) bool {
+	switch opts.sectionDetection {
+	case SectionsFromRehearsalMarks:
+		return false
+	case SectionsDetectedWithoutRehearsalMarks:
+		return len(explicit) == 0
+	}
+	return true
+}
+
+// chooseSections splits a piece into sections at the rehearsal marks and, if
+// the section detection in the options asks for it, at the detected
+// boundaries with sufficient confidence
+func chooseSections(explicit, detected []boundary, numMeasures int, opts *composeOptions) []section {
+	if !usesDetectedBoundaries(explicit, opts) {
+		return sectionsFromBoundaries(explicit, numMeasures)
+	}
+	boundaries := slices.Clone(explicit)
+	for _, b := range detected {
+		if b.confidence >= opts.minBoundaryConfidence {
+			boundaries = append(boundaries, b)
+		}
+	}
+	slices.SortStableFunc(boundaries, func(b1, b2 boundary) int {
+		return b1.measure - b2.measure
+	})
+	return sectionsFromBoundaries(boundaries, numMeasures)
+}
@@ -0,0 +1,272 @@
+package compose
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func emptyMeasures(num int) []musicxml.Measure {
+	measures := make([]musicxml.Measure, num)
+	for i := range measures {
+		measures[i] = *musicxml.NewMeasure()
+	}
+	enumerateMeasuresInPlace(measures)
+	return measures
+}
+
+func withAttributes(attr *musicxml.Attributes) musicxml.MeasureOpt {
+	return func(m *musicxml.Measure) {
+		m.MusicDataElements = append(m.MusicDataElements, musicxml.MusicDataElement{Attributes: attr})
+	}
+}
+
+func keyAttributes(fifths int) *musicxml.Attributes {
+	return &musicxml.Attributes{Key: []musicxml.Key{{Traditionalkey: musicxml.Traditionalkey{Fifths: fifths}}}}
+}
+
+func boundaryMeasures(boundaries []boundary) []int {
+	result := make([]int, len(boundaries))
+	for i, b := range boundaries {
+		result[i] = b.measure
+	}
+	return result
+}
+
+func TestDetectBoundaries(t *testing.T) {
+	leftRepeat := musicxml.NewBarline(musicxml.WithRepeat(&musicxml.Repeat{DirectionAttr: "forward"}))
+	leftRepeat.LocationAttr = "left"
+
+	for _, test := range []struct {
+		desc   string
+		modify func(measures []musicxml.Measure)
+		want   []int
+	}{
+		{
+			desc:   "no indicators",
+			modify: func(measures []musicxml.Measure) {},
+			want:   []int{},
+		},
+		{
+			desc: "final barline",
+			modify: func(measures []musicxml.Measure) {
+				measures[3] = *musicxml.NewMeasure(musicxml.WithBarline(musicxml.NewBarline(musicxml.WithBarStyle(musicxml.BarStyleLightHeavy))))
+			},
+			want: []int{4},
+		},
+		{
+			desc: "double barline",
+			modify: func(measures []musicxml.Measure) {
+				measures[5] = *musicxml.NewMeasure(musicxml.WithBarline(musicxml.NewBarline(musicxml.WithBarStyle(musicxml.BarStyleLightLight))))
+			},
+			want: []int{6},
+		},
+		{
+			desc: "forward repeat at the left",
+			modify: func(measures []musicxml.Measure) {
+				measures[2] = *musicxml.NewMeasure(musicxml.WithBarline(leftRepeat))
+			},
+			want: []int{2},
+		},
+		{
+			desc: "key change",
+			modify: func(measures []musicxml.Measure) {
+				measures[0] = *musicxml.NewMeasure(withAttributes(keyAttributes(0)))
+				measures[4] = *musicxml.NewMeasure(withAttributes(keyAttributes(2)))
+			},
+			want: []int{4},
+		},
+		{
+			desc: "time signature change",
+			modify: func(measures []musicxml.Measure) {
+				measures[0] = *musicxml.NewMeasure(withAttributes(&musicxml.Attributes{Time: []musicxml.Timesignature{{Beats: 4, Beattype: 4}}}))
+				measures[5] = *musicxml.NewMeasure(withAttributes(&musicxml.Attributes{Time: []musicxml.Timesignature{{Beats: 3, Beattype: 4}}}))
+			},
+			want: []int{5},
+		},
+		{
+			desc: "tempo words",
+			modify: func(measures []musicxml.Measure) {
+				measures[0] = *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Allegro"))))
+				measures[3] = *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Andante con moto"))))
+			},
+			want: []int{3},
+		},
+		{
+			desc: "metronome change",
+			modify: func(measures []musicxml.Measure) {
+				measures[0] = *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithTempo(120))))
+				measures[2] = *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithTempo(120))))
+				measures[6] = *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithTempo(80))))
+			},
+			want: []int{6},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			measures := emptyMeasures(8)
+			test.modify(measures)
+			got := boundaryMeasures(detectBoundaries(measures))
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDetectBoundariesPhrases(t *testing.T) {
+	boundaries := detectBoundaries(emptyMeasures(40))
+	want := []int{8, 16, 24, 32}
+	if got := boundaryMeasures(boundaries); !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+		return
+	}
+
+	wantConfidence := []float64{confidencePhrase8, confidencePhrase16, confidencePhrase8, confidencePhrase16}
+	for i, b := range boundaries {
+		if math.Abs(b.confidence-wantConfidence[i]) > 1e-6 {
+			t.Errorf("Wanted confidence %f got %f for boundary %d", wantConfidence[i], b.confidence, b.measure)
+		}
+	}
+}
+
+func TestDetectBoundariesShortPieceNotSplitIntoPhrases(t *testing.T) {
+	if boundaries := detectBoundaries(emptyMeasures(16)); len(boundaries) != 0 {
+		t.Errorf("Wanted no boundaries got %v", boundaries)
+	}
+}
+
+func TestCombinedConfidence(t *testing.T) {
+	measures := emptyMeasures(8)
+	measures[0] = *musicxml.NewMeasure(withAttributes(keyAttributes(0)))
+	measures[3] = *musicxml.NewMeasure(musicxml.WithBarline(musicxml.NewBarline(musicxml.WithBarStyle(musicxml.BarStyleLightLight))))
+	measures[4] = *musicxml.NewMeasure(withAttributes(keyAttributes(-1)))
+
+	boundaries := detectBoundaries(measures)
+	if len(boundaries) != 1 {
+		t.Errorf("Wanted one boundary got %v", boundaries)
+		return
+	}
+
+	want := 1.0 - (1.0-confidenceDoubleBarline)*(1.0-confidenceKeyChange)
+	if math.Abs(boundaries[0].confidence-want) > 1e-6 {
+		t.Errorf("Wanted %f got %f", want, boundaries[0].confidence)
+	}
+	if len(boundaries[0].reasons) != 2 {
+		t.Errorf("Wanted two reasons got %v", boundaries[0].reasons)
+	}
+}
+
+func TestSectionsWithDetection(t *testing.T) {
+	// Piece with a rehearsal mark in measure 2 and a key change in measure 5
+	withMark := emptyMeasures(8)
+	withMark[0] = *musicxml.NewMeasure(withAttributes(keyAttributes(0)))
+	withMark[2] = *musicxml.NewMeasure(musicxml.WithRehersalMark("A"))
+	withMark[5] = *musicxml.NewMeasure(withAttributes(keyAttributes(3)))
+
+	// Same piece without the rehearsal mark
+	withoutMark := emptyMeasures(8)
+	withoutMark[0] = withMark[0]
+	withoutMark[5] = withMark[5]
+
+	for _, test := range []struct {
+		desc     string
+		measures []musicxml.Measure
+		opts     []ComposeOpt
+		want     []section
+	}{
+		{
+			desc:     "rehearsal marks only",
+			measures: withMark,
+			opts:     []ComposeOpt{WithSectionDetection(SectionsFromRehearsalMarks)},
+			want:     []section{{start: 0, end: 2}, {start: 2, end: 8}},
+		},
+		{
+			desc:     "rehearsal marks only without marks",
+			measures: withoutMark,
+			opts:     []ComposeOpt{WithSectionDetection(SectionsFromRehearsalMarks)},
+			want:     []section{{start: 0, end: 8}},
+		},
+		{
+			desc:     "auto uses rehearsal marks when present",
+			measures: withMark,
+			opts:     []ComposeOpt{WithSectionDetection(SectionsDetectedWithoutRehearsalMarks)},
+			want:     []section{{start: 0, end: 2}, {start: 2, end: 8}},
+		},
+		{
+			desc:     "auto detects without rehearsal marks",
+			measures: withoutMark,
+			opts:     []ComposeOpt{WithSectionDetection(SectionsDetectedWithoutRehearsalMarks)},
+			want:     []section{{start: 0, end: 5}, {start: 5, end: 8}},
+		},
+		{
+			desc:     "detection is off by default",
+			measures: withoutMark,
+			want:     []section{{start: 0, end: 8}},
+		},
+		{
+			desc:     "combined",
+			measures: withMark,
+			opts:     []ComposeOpt{WithSectionDetection(SectionsFromRehearsalMarksAndDetected)},
+			want:     []section{{start: 0, end: 2}, {start: 2, end: 5}, {start: 5, end: 8}},
+		},
+		{
+			desc:     "boundary below confidence threshold",
+			measures: withoutMark,
+			opts:     []ComposeOpt{WithSectionDetection(SectionsDetectedWithoutRehearsalMarks), WithMinBoundaryConfidence(0.75)},
+			want:     []section{{start: 0, end: 8}},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got := sectionsWithDetection(test.measures, newComposeOptions(test.opts...))
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSectionsFromBoundariesEmpty(t *testing.T) {
+	if sections := sectionsFromBoundaries(nil, 0); len(sections) != 0 {
+		t.Errorf("Wanted no sections got %v", sections)
+	}
+}
+
+func TestTempoWord(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		want string
+	}{
+		{text: "Allegro con brio", want: "allegro"},
+		{text: "a tempo", want: "tempo"},
+		{text: "dolce", want: ""},
+		{text: "", want: ""},
+	} {
+		if got := tempoWord(test.text); got != test.want {
+			t.Errorf("Wanted %q got %q for %q", test.want, got, test.text)
+		}
+	}
+}
+
+func TestParseSectionDetection(t *testing.T) {
+	for _, detection := range []SectionDetection{SectionsFromRehearsalMarks, SectionsDetectedWithoutRehearsalMarks, SectionsFromRehearsalMarksAndDetected} {
+		got, err := ParseSectionDetection(detection.String())
+		if err != nil {
+			t.Errorf("Unexpected error %s", err)
+		}
+		if got != detection {
+			t.Errorf("Wanted %s got %s", detection, got)
+		}
+	}
+
+	if _, err := ParseSectionDetection("unknown"); err == nil {
+		t.Errorf("Wanted error for unknown section detection")
+	}
+}
+
+func TestUnknownSectionDetectionString(t *testing.T) {
+	if s := SectionDetection(100).String(); s != "" {
+		t.Errorf("Wanted empty string got %s", s)
+	}
+}
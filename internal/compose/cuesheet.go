@@ -0,0 +1,200 @@
+package compose
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const cueSheetSuffix = "_cues"
+
+var cueSheetColumns = []string{"Cue", "Start", "End", "Scene", "Title", "Composer", "Source", "Sections", "Tempo", "Key"}
+
+// Cue is a piece played in the film
+type Cue struct {
+	Number int
+
+	// Cue ID in reel M sequence form, for example 1M2
+	ID string
+
+	Start    time.Duration
+	End      time.Duration
+	Scene    string
+	Title    string
+	Composer string
+
+	// File the piece was read from. Empty for pieces not read from a file
+	Source string
+
+	// Bars of the piece played, for example "1-8, 17-24"
+	Sections string
+
+	// Tempo in beats per minute. Zero if the piece has no music
+	Tempo int
+	Key   string
+
+	// First bars of the piece as played in the scene
+	incipit []musicxml.Measure
+}
+
+func (c *Cue) row() []string {
+	tempo := ""
+	if c.Tempo > 0 {
+		tempo = strconv.Itoa(c.Tempo)
+	}
+	return []string{
+		c.ID, formatTimestamp(c.Start), formatTimestamp(c.End), c.Scene,
+		c.Title, c.Composer, c.Source, c.Sections, tempo, c.Key,
+	}
+}
+
+// CueSheet lists the pieces of a composition in the order they are played
+type CueSheet struct {
+	Title    string
+	Cues     []Cue
+	composer string
+}
+
+func newCueSheet(title string, pieces []pieceInfo) *CueSheet {
+	sheet := CueSheet{Title: title, composer: (&selection{pieces: pieces}).composer()}
+	for i, piece := range pieces {
+		sheet.Cues = append(sheet.Cues, Cue{
+			Number:   i + 1,
+			ID:       piece.id,
+			Start:    piece.start,
+			End:      piece.end,
+			Scene:    piece.scene,
+			Title:    piece.title,
+			Composer: piece.composer,
+			Source:   piece.source,
+			Sections: sectionBars(piece.sections),
+			Tempo:    piece.tempo,
+			Key:      piece.key,
+			incipit:  piece.cue,
+		})
+	}
+	return &sheet
+}
+
+// sectionBars lists the bars of the sections, counted from one
+func sectionBars(sections []section) string {
+	bars := make([]string, len(sections))
+	for i, s := range sections {
+		bars[i] = fmt.Sprintf("%d-%d", s.start+1, s.end)
+	}
+	return strings.Join(bars, ", ")
+}
+
+// formatTimestamp formats the duration as minutes and seconds. Hours are
+// added for durations of an hour or more.
+func formatTimestamp(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, (seconds%3600)/60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// Score returns the incipits of the cues. Each incipit starts on a new system
+// and is labelled with the cue ID as a rehearsal mark.
+func (c *CueSheet) Score() *musicxml.Scorepartwise {
+	var measures []musicxml.Measure
+	for _, cue := range c.Cues {
+		incipit := firstN(cue.incipit, len(cue.incipit))
+		if len(incipit) == 0 {
+			continue
+		}
+		for j := range incipit {
+			incipit[j].MusicDataElements = clearPrint(incipit[j].MusicDataElements)
+		}
+		if len(measures) == 0 {
+			incipit[0].MusicDataElements = ensurePageBreak(incipit[0].MusicDataElements)
+		} else {
+			incipit[0].MusicDataElements = ensureLineBreak(incipit[0].MusicDataElements)
+		}
+
+		label := fmt.Sprintf("%s (%s-%s)", cue.Title, formatTimestamp(cue.Start), formatTimestamp(cue.End))
+		musicxml.AddDirectionAtBeginning(&incipit[0], musicxml.NewDirection(musicxml.WithRehearsal(cue.ID), musicxml.WithWords(label)))
+		measures = append(measures, incipit...)
+	}
+	enumerateMeasuresInPlace(measures)
+	return newScore(c.Title+" - cue sheet", c.composer, measures)
+}
+
+func escapeMarkdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Markdown returns the cue sheet as a Markdown table
+func (c *CueSheet) Markdown() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Cue sheet: %s\n\n", escapeMarkdownCell(c.Title))
+	builder.WriteString("| " + strings.Join(cueSheetColumns, " | ") + " |\n")
+	builder.WriteString(strings.Repeat("| --- ", len(cueSheetColumns)) + "|\n")
+	for _, cue := range c.Cues {
+		cells := cue.row()
+		for i := range cells {
+			cells[i] = escapeMarkdownCell(cells[i])
+		}
+		builder.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return builder.String()
+}
+
+// WriteCSV writes the cue sheet as comma separated values with a header row
+func (c *CueSheet) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(cueSheetColumns); err != nil {
+		return err
+	}
+	for _, cue := range c.Cues {
+		if err := writer.Write(cue.row()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// CueSheetBaseName returns the file name of the cue sheet without extension
+// for the score with the given file name
+func CueSheetBaseName(scoreFileName string) string {
+	return strings.TrimSuffix(scoreFileName, ".musicxml") + cueSheetSuffix
+}
+
+// WriteFiles writes the incipits as MusicXML and the table as Markdown and
+// CSV to files starting with the base name. It returns the names of the files.
+func (c *CueSheet) WriteFiles(creator musicxml.Creator, baseName string) ([]string, error) {
+	names := []string{baseName + ".musicxml", baseName + ".md", baseName + ".csv"}
+	if err := musicxml.WriteScoreToFile(creator, names[0], c.Score()); err != nil {
+		return nil, err
+	}
+	writers := []func(w io.Writer) error{
+		func(w io.Writer) error {
+			_, err := io.WriteString(w, c.Markdown())
+			return err
+		},
+		c.WriteCSV,
+	}
+	for i, write := range writers {
+		if err := writeFile(creator, names[i+1], write); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+func writeFile(creator musicxml.Creator, name string, write func(w io.Writer) error) error {
+	file, err := creator.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return write(file)
+}
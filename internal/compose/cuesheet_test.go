@@ -0,0 +1,203 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestFormatTimestamp(t *testing.T) {
+	for _, test := range []struct {
+		duration time.Duration
+		want     string
+	}{
+		{duration: 0, want: "00:00"},
+		{duration: 75 * time.Second, want: "01:15"},
+		{duration: 1500 * time.Millisecond, want: "00:02"},
+		{duration: time.Hour + 2*time.Minute + 3*time.Second, want: "1:02:03"},
+	} {
+		if got := formatTimestamp(test.duration); got != test.want {
+			t.Errorf("Wanted %s got %s for %s", test.want, got, test.duration)
+		}
+	}
+}
+
+func TestSectionBars(t *testing.T) {
+	if got := sectionBars([]section{{start: 0, end: 8}, {start: 16, end: 24}}); got != "1-8, 17-24" {
+		t.Errorf("Wanted 1-8, 17-24 got %s", got)
+	}
+	if got := sectionBars(nil); got != "" {
+		t.Errorf("Wanted no bars got %s", got)
+	}
+}
+
+func cueSheetProject() *db.Project {
+	return db.NewProject(
+		db.WithName("The Kid"),
+		db.WithRecords([]db.ProjectContentRecord{
+			{Keywords: "sad waltz", DurationSec: 20, SceneDesc: "Farewell"},
+			{Keywords: "tacet", DurationSec: 10, SceneDesc: "Silence"},
+			{Keywords: "sad march", DurationSec: 30, SceneDesc: "The parade", Reel: 2},
+		}),
+	)
+}
+
+func TestCueSheetTimings(t *testing.T) {
+	sheet := CreateComposition(sadLibrary(), cueSheetProject()).CueSheet
+	if len(sheet.Cues) != 3 {
+		t.Errorf("Wanted three cues got %v", sheet.Cues)
+		return
+	}
+
+	type timing struct {
+		number     int
+		id         string
+		start, end time.Duration
+		scene      string
+		title      string
+	}
+	want := []timing{
+		{number: 1, id: "1M1", start: 0, end: 20 * time.Second, scene: "Farewell", title: "Sad waltz"},
+		{number: 2, id: "1M2", start: 20 * time.Second, end: 30 * time.Second, scene: "Silence", title: tacetText},
+		{number: 3, id: "2M1", start: 30 * time.Second, end: time.Minute, scene: "The parade", title: "Sad march"},
+	}
+	for i, cue := range sheet.Cues {
+		got := timing{number: cue.Number, id: cue.ID, start: cue.Start, end: cue.End, scene: cue.Scene, title: cue.Title}
+		if got != want[i] {
+			t.Errorf("Wanted %+v got %+v", want[i], got)
+		}
+	}
+
+	if sheet.Cues[0].Tempo == 0 || sheet.Cues[0].Key == "" || sheet.Cues[0].Sections == "" {
+		t.Errorf("Wanted tempo, key and sections of the first cue got %+v", sheet.Cues[0])
+	}
+	if sheet.Cues[1].Tempo != 0 || sheet.Cues[1].Sections != "" {
+		t.Errorf("Wanted no tempo or sections for the tacet cue got %+v", sheet.Cues[1])
+	}
+}
+
+func rehearsalMarks(measures []musicxml.Measure) []string {
+	var marks []string
+	for _, measure := range measures {
+		for _, dirType := range directionTypes(measure) {
+			for _, mark := range dirType.Rehearsal {
+				marks = append(marks, mark.Value)
+			}
+		}
+	}
+	return marks
+}
+
+func TestCueSheetTimecodes(t *testing.T) {
+	project := cueSheetProject()
+	project.Records[0].Timecode = "01:00:00"
+	project.Records[2].Timecode = "01:01:00"
+	sheet := CreateComposition(sadLibrary(), project).CueSheet
+
+	want := []time.Duration{time.Hour, time.Hour + 20*time.Second, time.Hour + time.Minute}
+	for i, cue := range sheet.Cues {
+		if cue.Start != want[i] {
+			t.Errorf("Wanted cue %d to start at %s got %s", i+1, want[i], cue.Start)
+		}
+	}
+}
+
+func TestCueIDsAsRehearsalMarks(t *testing.T) {
+	composition := CreateComposition(sadLibrary(), cueSheetProject())
+	score, sheet := composition.Score, composition.CueSheet
+	want := []string{"1M1", "1M2", "2M1"}
+	if got := rehearsalMarks(score.Part[0].Measure); !slices.Equal(got, want) {
+		t.Errorf("Wanted cue IDs %v in the music got %v", want, got)
+	}
+	if got := rehearsalMarks(sheet.Score().Part[0].Measure); !slices.Equal(got, want) {
+		t.Errorf("Wanted cue IDs %v in the cue sheet got %v", want, got)
+	}
+
+	// The incipits are copied such that the score can be created again
+	if again := sheet.Score(); len(again.Part[0].Measure) != len(sheet.Score().Part[0].Measure) {
+		t.Errorf("Wanted the same score when created twice")
+	}
+}
+
+func TestCueSheetMarkdown(t *testing.T) {
+	sheet := CueSheet{
+		Title: "The Kid",
+		Cues:  []Cue{{Number: 1, ID: "1M1", End: 90 * time.Second, Scene: "Fight | chase", Title: "Hurry", Tempo: 120, Key: "C major"}},
+	}
+	markdown := sheet.Markdown()
+	for _, want := range []string{
+		"# Cue sheet: The Kid",
+		"| Cue | Start | End | Scene | Title | Composer | Source | Sections | Tempo | Key |",
+		"| 1M1 | 00:00 | 01:30 | Fight \\| chase | Hurry |  |  |  | 120 | C major |",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Wanted %s in %s", want, markdown)
+		}
+	}
+}
+
+func TestCueSheetCSV(t *testing.T) {
+	sheet := CueSheet{Cues: []Cue{{Number: 1, ID: "2M4", Start: 5 * time.Second, End: 10 * time.Second, Scene: "Farewell, my love", Title: tacetText}}}
+	var buffer bytes.Buffer
+	if err := sheet.WriteCSV(&buffer); err != nil {
+		t.Error(err)
+		return
+	}
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(records) != 2 || !slices.Equal(records[0], cueSheetColumns) {
+		t.Errorf("Wanted header and one row got %v", records)
+		return
+	}
+	want := []string{"2M4", "00:05", "00:10", "Farewell, my love", tacetText, "", "", "", "", ""}
+	if !slices.Equal(records[1], want) {
+		t.Errorf("Wanted %v got %v", want, records[1])
+	}
+}
+
+type memoryFile struct {
+	bytes.Buffer
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
+
+type memoryCreator struct {
+	files map[string]*memoryFile
+}
+
+func (c *memoryCreator) Create(name string) (musicxml.WriterCloser, error) {
+	c.files[name] = &memoryFile{}
+	return c.files[name], nil
+}
+
+func TestCueSheetWriteFiles(t *testing.T) {
+	sheet := CreateComposition(sadLibrary(), cueSheetProject()).CueSheet
+	creator := memoryCreator{files: make(map[string]*memoryFile)}
+	names, err := sheet.WriteFiles(&creator, CueSheetBaseName("The_Kid.musicxml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []string{"The_Kid_cues.musicxml", "The_Kid_cues.md", "The_Kid_cues.csv"}
+	if !slices.Equal(names, want) {
+		t.Errorf("Wanted %v got %v", want, names)
+	}
+	for _, name := range want {
+		if file, ok := creator.files[name]; !ok || file.Len() == 0 {
+			t.Errorf("Wanted content in %s", name)
+		}
+	}
+}
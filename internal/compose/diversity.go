@@ -0,0 +1,87 @@
+package compose
+
+import (
+	"math"
+	"slices"
+)
+
+// Factor the similarity of a piece is multiplied with for each time it has
+// been used within the diversity window
+const diversityFactor = 0.5
+
+// recentPieces keeps track of the pieces used by the most recent scenes
+// without a theme
+type recentPieces struct {
+	window int
+	scenes [][]string
+}
+
+func (r *recentPieces) add(matches []matchResult) {
+	if r.window <= 0 {
+		return
+	}
+	fingerprints := make([]string, 0, len(matches))
+	for _, match := range matches {
+		fingerprints = append(fingerprints, match.fingerprint)
+	}
+	r.scenes = append(r.scenes, fingerprints)
+	if len(r.scenes) > r.window {
+		r.scenes = r.scenes[len(r.scenes)-r.window:]
+	}
+}
+
+func (r *recentPieces) uses(fingerprint string) int {
+	if r == nil || fingerprint == "" {
+		return 0
+	}
+	num := 0
+	for _, scene := range r.scenes {
+		if slices.Contains(scene, fingerprint) {
+			num++
+		}
+	}
+	return num
+}
+
+func (r *recentPieces) penalizedSimilarity(match matchResult) float64 {
+	return float64(match.similarity) * math.Pow(diversityFactor, float64(r.uses(match.fingerprint)))
+}
+
+// rerank orders the matches by their similarity after penalizing pieces that
+// were used recently and weighting them by how well they fit the intensity
+// of the scene
+func (r *recentPieces) rerank(matches []matchResult, intensity uint) []matchResult {
+	if (r == nil || r.window <= 0) && intensity == 0 {
+		return matches
+	}
+
+	type rankedMatch struct {
+		match      matchResult
+		similarity float64
+	}
+	ranked := make([]rankedMatch, len(matches))
+	for i, match := range matches {
+		ranked[i] = rankedMatch{
+			match:      match,
+			similarity: r.penalizedSimilarity(match) * intensityFactor(&match.analysis, intensity),
+		}
+	}
+	slices.SortStableFunc(ranked, func(m1, m2 rankedMatch) int {
+		return -cmpFloat(m1.similarity, m2.similarity)
+	})
+
+	result := make([]matchResult, len(ranked))
+	for i, item := range ranked {
+		result[i] = item.match
+	}
+	return result
+}
+
+func cmpFloat(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
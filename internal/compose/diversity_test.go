@@ -0,0 +1,149 @@
+package compose
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestRecentPiecesWindow(t *testing.T) {
+	recent := recentPieces{window: 2}
+	recent.add([]matchResult{{fingerprint: "a"}})
+	recent.add([]matchResult{{fingerprint: "a"}, {fingerprint: "b"}})
+
+	if uses := recent.uses("a"); uses != 2 {
+		t.Errorf("Wanted 2 uses of a got %d", uses)
+	}
+
+	recent.add([]matchResult{{fingerprint: "c"}})
+	for fingerprint, want := range map[string]int{"a": 1, "b": 1, "c": 1, "d": 0, "": 0} {
+		if uses := recent.uses(fingerprint); uses != want {
+			t.Errorf("Wanted %d uses of %q got %d", want, fingerprint, uses)
+		}
+	}
+}
+
+func TestRecentPiecesDisabled(t *testing.T) {
+	recent := recentPieces{}
+	recent.add([]matchResult{{fingerprint: "a"}})
+	if uses := recent.uses("a"); uses != 0 {
+		t.Errorf("Wanted no uses to be tracked got %d", uses)
+	}
+}
+
+func TestRerank(t *testing.T) {
+	matches := []matchResult{{fingerprint: "a", similarity: 10}, {fingerprint: "b", similarity: 8}, {fingerprint: "c", similarity: 3}}
+
+	var nilRecent *recentPieces
+	if got := nilRecent.rerank(matches, 0); !slices.Equal(got, matches) {
+		t.Errorf("Wanted order to be kept without penalty got %v", got)
+	}
+
+	recent := recentPieces{window: 3}
+	recent.add(matches[:1])
+	got := recent.rerank(matches, 0)
+	want := []string{"b", "a", "c"}
+	for i, match := range got {
+		if match.fingerprint != want[i] {
+			t.Errorf("Wanted order %v got %v", want, got)
+			return
+		}
+	}
+}
+
+func sadLibrary() *InMemoryLibrary {
+	var scores []*musicxml.Scorepartwise
+	for _, name := range []string{"Sad waltz", "Sad song", "Sad march"} {
+		measures := make([]musicxml.Measure, 4)
+		for i := range measures {
+			measures[i] = *musicxml.NewMeasure()
+		}
+		enumerateMeasuresInPlace(measures)
+		score := musicxml.NewScorePartwise(musicxml.WithComposer(name), musicxml.WithPart(musicxml.Part{Measure: measures}))
+		score.Work = &musicxml.Work{Worktitle: name}
+		scores = append(scores, score)
+	}
+	return &InMemoryLibrary{Scores: scores}
+}
+
+func TestDiversityPenalty(t *testing.T) {
+	for _, test := range []struct {
+		desc      string
+		window    int
+		themes    []uint
+		numTitles int
+	}{
+		{desc: "disabled", window: 0, themes: []uint{0, 0, 0}, numTitles: 1},
+		{desc: "window covers all scenes", window: 3, themes: []uint{0, 0, 0}, numTitles: 3},
+		{desc: "themes are not penalized", window: 3, themes: []uint{1, 1, 1}, numTitles: 1},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var records []db.ProjectContentRecord
+			for _, theme := range test.themes {
+				records = append(records, db.ProjectContentRecord{Keywords: "sad", DurationSec: 10, Theme: theme})
+			}
+			result := pickMeasures(sadLibrary(), records, WithDiversityWindow(test.window))
+
+			titles := make(map[string]struct{})
+			for _, piece := range result.pieces {
+				titles[piece.title] = struct{}{}
+			}
+			if len(titles) != test.numTitles {
+				t.Errorf("Wanted %d different pieces got %v", test.numTitles, result.pieces)
+			}
+			if result.pieces[0].title != "Sad waltz" {
+				t.Errorf("Wanted the best match in the first scene got %s", result.pieces[0].title)
+			}
+		})
+	}
+}
+
+func TestPinnedPiecesAreNotPenalized(t *testing.T) {
+	library := sadLibrary()
+	pin := library.Content()[1].Fingerprint
+	for _, test := range []struct {
+		desc   string
+		pinned []string
+		want   []string
+	}{
+		{desc: "all scenes pinned", pinned: []string{pin, pin, pin}, want: []string{"Sad song", "Sad song", "Sad song"}},
+		{desc: "pinned scenes do not count as uses", pinned: []string{"", pin, ""}, want: []string{"Sad waltz", "Sad song", "Sad song"}},
+		{desc: "pin repeats a penalized piece", pinned: []string{"", "", pin}, want: []string{"Sad waltz", "Sad song", "Sad song"}},
+		{desc: "unknown pin is matched by keywords", pinned: []string{"unknown", "", ""}, want: []string{"Sad waltz", "Sad song", "Sad march"}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var records []db.ProjectContentRecord
+			for _, pinned := range test.pinned {
+				records = append(records, db.ProjectContentRecord{Keywords: "sad", DurationSec: 10, Pinned: pinned})
+			}
+			result := pickMeasures(library, records, WithDiversityWindow(3))
+
+			var titles []string
+			for _, piece := range result.pieces {
+				titles = append(titles, piece.title)
+			}
+			if !slices.Equal(titles, test.want) {
+				t.Errorf("Wanted pieces %v got %v", test.want, titles)
+			}
+		})
+	}
+}
+
+func TestCreateCompositionUsesProjectDiversityWindow(t *testing.T) {
+	var records []db.ProjectContentRecord
+	for range 3 {
+		records = append(records, db.ProjectContentRecord{Keywords: "sad", DurationSec: 10})
+	}
+	project := db.NewProject(db.WithName("silent film"), db.WithRecords(records), db.WithDiversityWindow(3))
+	score := CreateComposition(sadLibrary(), project).Score
+
+	text := strings.Join(musicxml.TextFields(*score), " ")
+	for _, name := range []string{"Sad waltz", "Sad song", "Sad march"} {
+		if !strings.Contains(text, name) {
+			t.Errorf("Wanted %s in the composition got %s", name, text)
+		}
+	}
+}
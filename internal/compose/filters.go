@@ -0,0 +1,57 @@
+package compose
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	modeDirective  = "mode"
+	meterDirective = "meter"
+	keyDirective   = "key"
+)
+
+// PieceFilter restricts matching to pieces with the given musical features.
+// Empty fields match every piece.
+type PieceFilter struct {
+	Mode  musicxml.Mode
+	Meter string
+	Tonic string
+}
+
+// Matches returns true if the analysed piece passes the filter
+func (f *PieceFilter) Matches(analysis *musicxml.Analysis) bool {
+	if f.Mode != "" && f.Mode != analysis.Mode {
+		return false
+	}
+	if f.Meter != "" && f.Meter != analysis.Meter() {
+		return false
+	}
+	if f.Tonic != "" && !strings.EqualFold(f.Tonic, analysis.Tonic()) {
+		return false
+	}
+	return true
+}
+
+// parseFilterDirective adds a "mode:minor", "meter:3/4" or "key:d" directive
+// to the filter. It returns false if the word is not a filter directive.
+func parseFilterDirective(filter *PieceFilter, name, value string) bool {
+	switch name {
+	case modeDirective:
+		switch mode := musicxml.Mode(value); mode {
+		case musicxml.ModeMajor, musicxml.ModeMinor:
+			filter.Mode = mode
+		default:
+			slog.Warn("Unknown mode in keywords", "value", value)
+		}
+	case meterDirective:
+		filter.Meter = value
+	case keyDirective:
+		filter.Tonic = value
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestPieceFilterMatches(t *testing.T) {
+	analysis := musicxml.Analysis{Fifths: -1, Mode: musicxml.ModeMinor, Time: musicxml.Timesignature{Beats: 3, Beattype: 4}}
+	for _, test := range []struct {
+		filter PieceFilter
+		want   bool
+		desc   string
+	}{
+		{filter: PieceFilter{}, want: true, desc: "empty filter"},
+		{filter: PieceFilter{Mode: musicxml.ModeMinor}, want: true, desc: "same mode"},
+		{filter: PieceFilter{Mode: musicxml.ModeMajor}, want: false, desc: "other mode"},
+		{filter: PieceFilter{Meter: "3/4"}, want: true, desc: "same meter"},
+		{filter: PieceFilter{Meter: "4/4"}, want: false, desc: "other meter"},
+		{filter: PieceFilter{Tonic: "d"}, want: true, desc: "same tonic"},
+		{filter: PieceFilter{Tonic: "f"}, want: false, desc: "relative major"},
+		{filter: PieceFilter{Mode: musicxml.ModeMinor, Meter: "6/8"}, want: false, desc: "one criterion fails"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := test.filter.Matches(&analysis); got != test.want {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func pieceWithMeter(name string, beats int, mode string) *musicxml.Scorepartwise {
+	measures := emptyMeasures(4)
+	measures[0].MusicDataElements = append(measures[0].MusicDataElements, musicxml.MusicDataElement{
+		Attributes: &musicxml.Attributes{
+			Key:  []musicxml.Key{{Traditionalkey: musicxml.Traditionalkey{Mode: mode}}},
+			Time: []musicxml.Timesignature{{Beats: beats, Beattype: 4}},
+		},
+	})
+	score := musicxml.NewScorePartwise(musicxml.WithComposer(name), musicxml.WithPart(musicxml.Part{Measure: measures}))
+	score.Work = &musicxml.Work{Worktitle: name}
+	return score
+}
+
+func TestFilterDirectivesRestrictSelection(t *testing.T) {
+	library := &InMemoryLibrary{
+		Scores: []*musicxml.Scorepartwise{
+			pieceWithMeter("Sad march", 4, "minor"),
+			pieceWithMeter("Sad waltz", 3, "minor"),
+			pieceWithMeter("Happy waltz", 3, "major"),
+		},
+	}
+
+	for _, test := range []struct {
+		keywords string
+		want     string
+	}{
+		{keywords: "sad march meter:3/4", want: "Sad waltz"},
+		{keywords: "waltz mode:major", want: "Happy waltz"},
+		{keywords: "march", want: "Sad march"},
+	} {
+		records := []db.ProjectContentRecord{{Keywords: test.keywords, DurationSec: 10}}
+		result := pickMeasures(library, records)
+		if len(result.pieces) != 1 || result.pieces[0].title != test.want {
+			t.Errorf("Wanted %s for %q got %v", test.want, test.keywords, result.pieces)
+		}
+	}
+}
+
+func TestFilterWithoutMatches(t *testing.T) {
+	library := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{pieceWithMeter("Sad march", 4, "minor")}}
+	if matches := library.BestMatches("sad", 2, PieceFilter{Meter: "3/4"}); len(matches) != 0 {
+		t.Errorf("Wanted no matches got %d", len(matches))
+	}
+}
+
+func TestLibraryContentDescription(t *testing.T) {
+	content := contentFromScore(pieceWithMeter("Sad waltz", 3, "minor"), "", musicxml.Analyze(pieceWithMeter("Sad waltz", 3, "minor")))
+	description := content.Description()
+	for _, column := range []string{"Sad waltz", "A minor", "3/4"} {
+		if !strings.Contains(description, column) {
+			t.Errorf("Wanted %q in %q", column, description)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	default:
+		return "warning"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type IssueKind int
+
+const (
+	IssueParseError IssueKind = iota
+	IssueNoMusic
+	IssueMissingTitle
+	IssueMissingComposer
+	IssueNoTempoMarking
+	IssueNoRehearsalMarks
+	IssueMultipleParts
+	IssueUnsupportedElement
+)
+
+func (k IssueKind) String() string {
+	var result string
+	switch k {
+	case IssueParseError:
+		result = "parse-error"
+	case IssueNoMusic:
+		result = "no-music"
+	case IssueMissingTitle:
+		result = "missing-title"
+	case IssueMissingComposer:
+		result = "missing-composer"
+	case IssueNoTempoMarking:
+		result = "no-tempo-marking"
+	case IssueNoRehearsalMarks:
+		result = "no-rehearsal-marks"
+	case IssueMultipleParts:
+		result = "multiple-parts"
+	case IssueUnsupportedElement:
+		result = "unsupported-element"
+	}
+	return result
+}
+
+func (k IssueKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Severity returns SeverityError for issues that make a piece unusable for matching
+func (k IssueKind) Severity() Severity {
+	switch k {
+	case IssueParseError, IssueNoMusic:
+		return SeverityError
+	default:
+		return SeverityWarning
+	}
+}
+
+type Issue struct {
+	Kind     IssueKind `json:"kind"`
+	Severity Severity  `json:"severity"`
+	Message  string    `json:"message"`
+}
+
+func newIssue(kind IssueKind, message string) Issue {
+	return Issue{Kind: kind, Severity: kind.Severity(), Message: message}
+}
+
+// PieceHealth summarizes problems with a single piece in a library
+type PieceHealth struct {
+	File     string  `json:"file"`
+	Title    string  `json:"title"`
+	Composer string  `json:"composer"`
+	Issues   []Issue `json:"issues"`
+}
+
+// Failing returns true if the piece has at least one issue of error severity
+func (p *PieceHealth) Failing() bool {
+	return slices.ContainsFunc(p.Issues, func(issue Issue) bool {
+		return issue.Severity == SeverityError
+	})
+}
+
+// Names of music data elements handled by the composer
+var supportedElements = map[string]struct{}{
+	"note":         {},
+	"backup":       {},
+	"forward":      {},
+	"direction":    {},
+	"attributes":   {},
+	"harmony":      {},
+	"figured-bass": {},
+	"print":        {},
+	"sound":        {},
+	"listening":    {},
+	"barline":      {},
+	"grouping":     {},
+	"link":         {},
+	"bookmark":     {},
+}
+
+// CheckScore inspects a score and reports everything that may prevent the piece
+// from being used in a compiled score. readErr is the error (if any) returned
+// when the score was read.
+func CheckScore(file string, score *musicxml.Scorepartwise, readErr error) PieceHealth {
+	health := PieceHealth{
+		File:     file,
+		Title:    title(score),
+		Composer: composer(score),
+		Issues:   []Issue{},
+	}
+
+	if readErr != nil {
+		health.Issues = append(health.Issues, newIssue(IssueParseError, readErr.Error()))
+	}
+
+	if score == nil || len(score.Part) == 0 || len(score.Part[0].Measure) == 0 {
+		health.Issues = append(health.Issues, newIssue(IssueNoMusic, "the piece has no measures"))
+		return health
+	}
+
+	if health.Title == "" {
+		health.Issues = append(health.Issues, newIssue(IssueMissingTitle, "no work title"))
+	}
+	if health.Composer == "" {
+		health.Issues = append(health.Issues, newIssue(IssueMissingComposer, "no composer credit"))
+	}
+
+	measures := score.Part[0].Measure
+	if !hasMetronome(measures) {
+		health.Issues = append(health.Issues, newIssue(IssueNoTempoMarking, fmt.Sprintf("no metronome marking, %d bpm is assumed", defaultTempo)))
+	}
+	if !slices.ContainsFunc(measures, func(m musicxml.Measure) bool { return hasRehersalMark(m.MusicDataElements) }) {
+		health.Issues = append(health.Issues, newIssue(IssueNoRehearsalMarks, "no rehearsal marks, the piece is a single section"))
+	}
+	if len(score.Part) > 1 {
+		health.Issues = append(health.Issues, newIssue(IssueMultipleParts, fmt.Sprintf("%d parts, only the first is used", len(score.Part))))
+	}
+	if unsupported := unsupportedElements(measures); len(unsupported) > 0 {
+		health.Issues = append(health.Issues, newIssue(IssueUnsupportedElement, strings.Join(unsupported, ", ")))
+	}
+	return health
+}
+
+func hasMetronome(measures []musicxml.Measure) bool {
+	for _, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if direction := element.Direction; direction != nil {
+				for _, dirType := range direction.Directiontype {
+					if dirType.Metronome != nil {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func unsupportedElements(measures []musicxml.Measure) []string {
+	found := make(map[string]struct{})
+	for _, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if _, ok := supportedElements[element.XMLName.Local]; !ok && element.XMLName.Local != "" {
+				found[element.XMLName.Local] = struct{}{}
+			}
+			if musicxml.IsRepeatJump(element.Direction) {
+				found["repeat jump"] = struct{}{}
+			}
+			if attr := element.Attributes; attr != nil {
+				for _, style := range attr.Measurestyle {
+					if style.Measurerepeat != nil || style.Beatrepeat != nil || style.Multiplerest != nil {
+						found["measure-style abbreviation"] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+
+	result := make([]string, 0, len(found))
+	for name := range found {
+		result = append(result, name)
+	}
+	slices.Sort(result)
+	return result
+}
+
+func inMemoryName(index int) string {
+	return fmt.Sprintf("in-memory #%d", index+1)
+}
+
+// WriteHealthReport writes the health of all pieces as JSON
+func WriteHealthReport(w io.Writer, pieces []PieceHealth) error {
+	numFailing := 0
+	for _, piece := range pieces {
+		if piece.Failing() {
+			numFailing++
+		}
+	}
+
+	report := struct {
+		NumPieces  int           `json:"numPieces"`
+		NumFailing int           `json:"numFailing"`
+		Pieces     []PieceHealth `json:"pieces"`
+	}{
+		NumPieces:  len(pieces),
+		NumFailing: numFailing,
+		Pieces:     pieces,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
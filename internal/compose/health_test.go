@@ -0,0 +1,149 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func issueKinds(health PieceHealth) []IssueKind {
+	kinds := make([]IssueKind, len(health.Issues))
+	for i, issue := range health.Issues {
+		kinds[i] = issue.Kind
+	}
+	return kinds
+}
+
+func TestCheckScore(t *testing.T) {
+	healthyMeasures := eightBarPiece()
+	musicxml.SetTempoAtBeginning(&healthyMeasures[0], defaultMetronome())
+	healthy := musicxml.NewScorePartwise(musicxml.WithComposer("Bach"), musicxml.WithPart(musicxml.Part{Measure: healthyMeasures}))
+	healthy.Work = &musicxml.Work{Worktitle: "Prelude"}
+
+	unsupported := *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("D.S. al Coda"))))
+	unsupported.MusicDataElements = append(unsupported.MusicDataElements, musicxml.MusicDataElement{XMLName: xml.Name{Local: "fancy"}})
+
+	for _, test := range []struct {
+		score   *musicxml.Scorepartwise
+		readErr error
+		want    []IssueKind
+		failing bool
+		desc    string
+	}{
+		{
+			score: healthy,
+			want:  []IssueKind{},
+			desc:  "Healthy piece",
+		},
+		{
+			score:   &musicxml.Scorepartwise{},
+			readErr: errors.New("XML syntax error"),
+			want:    []IssueKind{IssueParseError, IssueNoMusic},
+			failing: true,
+			desc:    "Parse error",
+		},
+		{
+			score: musicxml.NewScorePartwise(
+				musicxml.WithPart(musicxml.Part{Measure: []musicxml.Measure{unsupported}}),
+				musicxml.WithPart(musicxml.Part{Measure: []musicxml.Measure{{}}}),
+			),
+			want: []IssueKind{IssueMissingTitle, IssueMissingComposer, IssueNoTempoMarking, IssueNoRehearsalMarks, IssueMultipleParts, IssueUnsupportedElement},
+			desc: "Everything unsuitable",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			health := CheckScore("file.musicxml", test.score, test.readErr)
+			if kinds := issueKinds(health); !slices.Equal(kinds, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, kinds)
+			}
+			if health.Failing() != test.failing {
+				t.Errorf("Wanted failing=%v", test.failing)
+			}
+		})
+	}
+}
+
+func TestUnsupportedElementsAreListed(t *testing.T) {
+	measure := *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("D.C. al Fine"))))
+	measure.MusicDataElements = append(measure.MusicDataElements, musicxml.MusicDataElement{XMLName: xml.Name{Local: "fancy"}})
+	want := []string{"fancy", "repeat jump"}
+	if got := unsupportedElements([]musicxml.Measure{measure}); !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
+
+func TestStandardLibraryHasNoFailingPieces(t *testing.T) {
+	for _, health := range NewStandardLibrary().Health() {
+		if health.Failing() {
+			t.Errorf("%s is failing: %v", health.File, health.Issues)
+		}
+	}
+}
+
+func TestExcludeFailingPieces(t *testing.T) {
+	folder := t.TempDir()
+	for name, content := range map[string]string{
+		"broken.musicxml": "<score-partwise><part>",
+		"empty.musicxml":  "<score-partwise></score-partwise>",
+	} {
+		if err := os.WriteFile(filepath.Join(folder, name), []byte(content), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	included := NewLocalLibrary(folder)
+	if n := len(included.Content()); n != 2 {
+		t.Errorf("Wanted both pieces to be available got %d", n)
+	}
+
+	excluded := NewLocalLibrary(folder, WithExcludeFailing(true))
+	if n := len(excluded.Content()); n != 0 {
+		t.Errorf("Wanted no pieces available got %d", n)
+	}
+	if result := excluded.BestMatch("anything"); result.score != nil {
+		t.Errorf("Wanted no match got %v", result.score)
+	}
+	if n := len(excluded.Health()); n != 2 {
+		t.Errorf("Health report should include excluded pieces, got %d", n)
+	}
+}
+
+func TestWriteHealthReport(t *testing.T) {
+	library := InMemoryLibrary{Scores: []*musicxml.Scorepartwise{{}, musicxml.NewScorePartwise()}}
+	var buf bytes.Buffer
+	if err := WriteHealthReport(&buf, library.Health()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var report struct {
+		NumPieces  int `json:"numPieces"`
+		NumFailing int `json:"numFailing"`
+		Pieces     []struct {
+			File   string `json:"file"`
+			Issues []struct {
+				Kind     string `json:"kind"`
+				Severity string `json:"severity"`
+			} `json:"issues"`
+		} `json:"pieces"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if report.NumPieces != 2 || report.NumFailing != 2 {
+		t.Errorf("Wanted 2 failing pieces got %d/%d", report.NumFailing, report.NumPieces)
+	}
+	if issue := report.Pieces[0].Issues[0]; issue.Kind != "no-music" || issue.Severity != "error" {
+		t.Errorf("Wanted no-music error got %v", issue)
+	}
+}
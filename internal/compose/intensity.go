@@ -0,0 +1,82 @@
+package compose
+
+import (
+	"math"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+// Marked tempos and note densities (notes per beat) regarded as the calmest
+// and the most energetic
+const (
+	slowTempo          = 60.0
+	fastTempo          = 160.0
+	sparseNotesPerBeat = 0.5
+	denseNotesPerBeat  = 4.0
+	maxIntensity       = 5
+
+	// Number of additional candidates considered when biasing by intensity
+	numIntensityCandidates = 5
+)
+
+func normalizeRange(value, low, high float64) float64 {
+	return math.Max(0.0, math.Min(1.0, (value-low)/(high-low)))
+}
+
+// pieceEnergy rates a piece from 0 (calm) to 1 (energetic) from its marked
+// tempo and note density
+func pieceEnergy(analysis *musicxml.Analysis) float64 {
+	tempo := float64(analysis.Tempo)
+	if analysis.Tempo == 0 {
+		tempo = defaultTempo
+	}
+	return 0.5*normalizeRange(tempo, slowTempo, fastTempo) + 0.5*normalizeRange(analysis.NotesPerBeat, sparseNotesPerBeat, denseNotesPerBeat)
+}
+
+// intensityFactor is multiplied with the similarity of a piece. Pieces whose
+// energy fits the intensity of the scene get a factor up to 1.5 and pieces
+// that fit poorly get a factor down to 0.5. Scenes without an intensity are
+// not biased.
+func intensityFactor(analysis *musicxml.Analysis, intensity uint) float64 {
+	if intensity == 0 {
+		return 1.0
+	}
+	target := float64(min(intensity, maxIntensity)-1) / float64(maxIntensity-1)
+	return 0.5 + (1.0 - math.Abs(pieceEnergy(analysis)-target))
+}
+
+func dynamicsLevel(intensity uint) musicxml.DynamicsLevel {
+	switch min(intensity, maxIntensity) {
+	case 1:
+		return musicxml.DynamicsPp
+	case 2:
+		return musicxml.DynamicsP
+	case 3:
+		return musicxml.DynamicsMf
+	case 4:
+		return musicxml.DynamicsF
+	default:
+		return musicxml.DynamicsFf
+	}
+}
+
+// markIntensity inserts the dynamics of the scene at its start. When the
+// intensity differs from the previous scene, a hairpin leads from the last
+// of the previous measures into the new scene.
+func markIntensity(previous []musicxml.Measure, scene []musicxml.Measure, prevIntensity, intensity uint) {
+	if intensity == 0 || len(scene) == 0 {
+		return
+	}
+
+	opts := []musicxml.DirectionOpt{}
+	if prevIntensity > 0 && prevIntensity != intensity && len(previous) > 0 {
+		wedge := musicxml.WedgeType(musicxml.WedgeCrescendo)
+		if intensity < prevIntensity {
+			wedge = musicxml.WedgeDiminuendo
+		}
+		musicxml.AddDirectionAtBeginning(&previous[len(previous)-1], musicxml.NewDirection(musicxml.WithWedge(wedge)))
+		opts = append(opts, musicxml.WithWedge(musicxml.WedgeStop))
+	}
+	opts = append(opts, musicxml.WithDynamics(dynamicsLevel(intensity)))
+	musicxml.AddDirectionAtBeginning(&scene[0], musicxml.NewDirection(opts...))
+}
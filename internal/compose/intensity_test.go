@@ -0,0 +1,185 @@
+package compose
+
+import (
+	"encoding/xml"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+// pieceWithEnergy creates a piece in 4/4 with the given marked tempo and
+// number of notes in each measure
+func pieceWithEnergy(name string, tempo int, notesPerMeasure int) *musicxml.Scorepartwise {
+	measures := emptyMeasures(4)
+	musicxml.SetTimeSignatureAtBeginning(&measures[0], musicxml.Timesignature{Beats: 4, Beattype: 4})
+	musicxml.SetTempoAtBeginning(&measures[0], &musicxml.Metronome{
+		Perminute: &musicxml.Perminute{Value: tempo},
+		Beatunit:  musicxml.Beatunit{Beatunit: "quarter"},
+	})
+	for i := range measures {
+		for range notesPerMeasure {
+			measures[i].MusicDataElements = append(measures[i].MusicDataElements, musicxml.MusicDataElement{XMLName: xml.Name{Local: "note"}, Note: &musicxml.Note{}})
+		}
+	}
+	score := musicxml.NewScorePartwise(musicxml.WithComposer(name), musicxml.WithPart(musicxml.Part{Measure: measures}))
+	score.Work = &musicxml.Work{Worktitle: name}
+	return score
+}
+
+func TestPieceEnergy(t *testing.T) {
+	for _, test := range []struct {
+		piece *musicxml.Scorepartwise
+		want  float64
+		desc  string
+	}{
+		{piece: pieceWithEnergy("Calm", 50, 1), want: 0.0, desc: "slow and sparse"},
+		{piece: pieceWithEnergy("Chase", 180, 32), want: 1.0, desc: "fast and dense"},
+		{piece: pieceWithEnergy("March", 110, 1), want: 0.25, desc: "medium tempo and sparse"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			analysis := musicxml.Analyze(test.piece)
+			if got := pieceEnergy(&analysis); got != test.want {
+				t.Errorf("Wanted %f got %f", test.want, got)
+			}
+		})
+	}
+}
+
+func TestIntensityFactor(t *testing.T) {
+	calm := musicxml.Analyze(pieceWithEnergy("Calm", 50, 1))
+	for _, test := range []struct {
+		intensity uint
+		want      float64
+	}{
+		{intensity: 0, want: 1.0},
+		{intensity: 1, want: 1.5},
+		{intensity: 3, want: 1.0},
+		{intensity: 5, want: 0.5},
+		{intensity: 9, want: 0.5},
+	} {
+		if got := intensityFactor(&calm, test.intensity); got != test.want {
+			t.Errorf("Wanted %f got %f for intensity %d", test.want, got, test.intensity)
+		}
+	}
+}
+
+func TestDynamicsLevel(t *testing.T) {
+	for intensity, want := range map[uint]musicxml.DynamicsLevel{
+		1: musicxml.DynamicsPp,
+		2: musicxml.DynamicsP,
+		3: musicxml.DynamicsMf,
+		4: musicxml.DynamicsF,
+		5: musicxml.DynamicsFf,
+	} {
+		if got := dynamicsLevel(intensity); got != want {
+			t.Errorf("Wanted %s got %s for intensity %d", want, got, intensity)
+		}
+	}
+}
+
+func TestIntensityBiasesSelection(t *testing.T) {
+	library := &InMemoryLibrary{
+		Scores: []*musicxml.Scorepartwise{
+			pieceWithEnergy("Chase calm", 60, 1),
+			pieceWithEnergy("Chase wild", 160, 16),
+		},
+	}
+
+	for _, test := range []struct {
+		intensity uint
+		want      string
+	}{
+		{intensity: 1, want: "Chase calm"},
+		{intensity: 5, want: "Chase wild"},
+	} {
+		records := []db.ProjectContentRecord{{Keywords: "chase", DurationSec: 10, Intensity: test.intensity}}
+		result := pickMeasures(library, records)
+		if len(result.pieces) != 1 || result.pieces[0].title != test.want {
+			t.Errorf("Wanted %s for intensity %d got %v", test.want, test.intensity, result.pieces)
+		}
+	}
+}
+
+func directionTypes(measure musicxml.Measure) []musicxml.Directiontype {
+	var result []musicxml.Directiontype
+	for _, element := range measure.MusicDataElements {
+		if element.Direction != nil {
+			result = append(result, element.Direction.Directiontype...)
+		}
+	}
+	return result
+}
+
+func wedges(measure musicxml.Measure) []string {
+	var result []string
+	for _, dirType := range directionTypes(measure) {
+		if dirType.Wedge != nil {
+			result = append(result, dirType.Wedge.TypeAttr)
+		}
+	}
+	return result
+}
+
+func numDynamics(measure musicxml.Measure) int {
+	num := 0
+	for _, dirType := range directionTypes(measure) {
+		num += len(dirType.Dynamics)
+	}
+	return num
+}
+
+func TestMarkIntensity(t *testing.T) {
+	for _, test := range []struct {
+		prevIntensity uint
+		intensity     uint
+		wantWedge     []string
+		wantStop      []string
+		wantDynamics  int
+		desc          string
+	}{
+		{prevIntensity: 2, intensity: 4, wantWedge: []string{"crescendo"}, wantStop: []string{"stop"}, wantDynamics: 1, desc: "crescendo"},
+		{prevIntensity: 4, intensity: 2, wantWedge: []string{"diminuendo"}, wantStop: []string{"stop"}, wantDynamics: 1, desc: "diminuendo"},
+		{prevIntensity: 3, intensity: 3, wantDynamics: 1, desc: "unchanged intensity"},
+		{prevIntensity: 0, intensity: 3, wantDynamics: 1, desc: "previous scene without intensity"},
+		{prevIntensity: 3, intensity: 0, wantDynamics: 0, desc: "scene without intensity"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			previous := emptyMeasures(2)
+			scene := emptyMeasures(2)
+			markIntensity(previous, scene, test.prevIntensity, test.intensity)
+
+			if got := wedges(previous[1]); !slices.Equal(got, test.wantWedge) {
+				t.Errorf("Wanted wedges %v at the end of the previous scene got %v", test.wantWedge, got)
+			}
+			if got := wedges(scene[0]); !slices.Equal(got, test.wantStop) {
+				t.Errorf("Wanted wedges %v at the start of the scene got %v", test.wantStop, got)
+			}
+			if got := numDynamics(scene[0]); got != test.wantDynamics {
+				t.Errorf("Wanted %d dynamics got %d", test.wantDynamics, got)
+			}
+		})
+	}
+}
+
+func TestPickMeasuresMarksIntensityChanges(t *testing.T) {
+	records := []db.ProjectContentRecord{
+		{Keywords: "sad", DurationSec: 10, Intensity: 2},
+		{Keywords: "sad", DurationSec: 10, Intensity: 5},
+	}
+	result := pickMeasures(sadLibrary(), records)
+
+	numWedges := 0
+	totalDynamics := 0
+	for _, measure := range result.measures {
+		numWedges += len(wedges(measure))
+		totalDynamics += numDynamics(measure)
+	}
+	if numWedges != 2 {
+		t.Errorf("Wanted a hairpin start and stop got %d wedges", numWedges)
+	}
+	if totalDynamics != 2 {
+		t.Errorf("Wanted dynamics at the start of each scene got %d", totalDynamics)
+	}
+}
@@ -0,0 +1,77 @@
+package compose
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	intertitleDirective   = "intertitle"
+	minIntertitleDuration = 3 * time.Second
+)
+
+// intertitleDuration returns the time the audience needs to read the card
+// text at the given number of words per second
+func intertitleDuration(text string, wordsPerSecond float64) time.Duration {
+	if wordsPerSecond <= 0.0 {
+		wordsPerSecond = db.DefaultReadingSpeed
+	}
+	seconds := math.Ceil(float64(len(strings.Fields(text))) / wordsPerSecond)
+	return max(time.Duration(seconds)*time.Second, minIntertitleDuration)
+}
+
+// sceneDuration returns the duration of the scene. Intertitles without a
+// duration are given the time it takes to read the card text.
+func sceneDuration(record *db.ProjectContentRecord, keywords sceneKeywords, options *composeOptions) time.Duration {
+	if keywords.intertitle && record.DurationSec == 0 {
+		return intertitleDuration(record.SceneDesc, options.readingSpeed)
+	}
+	return time.Duration(record.DurationSec) * time.Second
+}
+
+// hasMatchingRule returns true if the keywords say how to pick the music
+func hasMatchingRule(keywords sceneKeywords) bool {
+	return keywords.text != "" || keywords.like != "" || keywords.filter != PieceFilter{}
+}
+
+// continuingIntertitles returns the durations of the intertitles following the
+// scene at index that continue its music. A card continues the music when it
+// has no matching rule, theme or timecode of its own and is on the same reel.
+// The time of the cards is added to the scene, such that the music plays on
+// under them without a new cue.
+func continuingIntertitles(records []db.ProjectContentRecord, index int, options *composeOptions) []time.Duration {
+	var durations []time.Duration
+	for _, record := range records[index+1:] {
+		keywords := parseSceneKeywords(record.Keywords)
+		continues := keywords.intertitle && !hasMatchingRule(keywords) && !isTacet(keywords, options) &&
+			record.Theme == 0 && record.Timecode == "" && record.ReelNumber() == records[index].ReelNumber()
+		if !continues {
+			break
+		}
+		durations = append(durations, sceneDuration(&record, keywords, options))
+	}
+	return durations
+}
+
+// cardMeasure returns the index of the measure played when a card shown at
+// offset into a scene of the given duration comes up
+func cardMeasure(numMeasures int, offset, duration time.Duration) int {
+	if duration <= 0 {
+		return 0
+	}
+	return min(int(float64(numMeasures)*offset.Seconds()/duration.Seconds()), numMeasures-1)
+}
+
+// setSceneText prints the scene description at the measure. The text of an
+// intertitle is printed in a box like the card itself.
+func setSceneText(measure *musicxml.Measure, desc string, intertitle bool) {
+	if intertitle {
+		musicxml.AddDirectionAtBeginning(measure, musicxml.NewDirection(musicxml.WithBoxedWords(desc)))
+		return
+	}
+	musicxml.SetSystemTextAtBeginning(measure, desc)
+}
@@ -0,0 +1,172 @@
+package compose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func boxedTexts(measure musicxml.Measure) []string {
+	var texts []string
+	for _, dirType := range directionTypes(measure) {
+		for _, words := range dirType.Words {
+			if words.EnclosureAttr != "" {
+				texts = append(texts, words.Value)
+			}
+		}
+	}
+	return texts
+}
+
+func TestIntertitleDuration(t *testing.T) {
+	for _, test := range []struct {
+		text           string
+		wordsPerSecond float64
+		want           time.Duration
+		desc           string
+	}{
+		{text: "Came the dawn", wordsPerSecond: 2.0, want: minIntertitleDuration, desc: "short card"},
+		{text: "And so the long night ended and the village woke to find him gone", wordsPerSecond: 2.0, want: 7 * time.Second, desc: "rounded up"},
+		{text: "And so the long night ended and the village woke to find him gone", wordsPerSecond: 1.0, want: 14 * time.Second, desc: "slow readers"},
+		{text: "And so the long night ended and the village woke to find him gone", wordsPerSecond: 0.0, want: 7 * time.Second, desc: "default speed"},
+		{text: "", wordsPerSecond: 2.0, want: minIntertitleDuration, desc: "no text"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := intertitleDuration(test.text, test.wordsPerSecond); got != test.want {
+				t.Errorf("Wanted %s got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSceneDuration(t *testing.T) {
+	options := newComposeOptions(WithReadingSpeed(1.0))
+	for _, test := range []struct {
+		record db.ProjectContentRecord
+		want   time.Duration
+		desc   string
+	}{
+		{record: db.ProjectContentRecord{Keywords: "intertitle", SceneDesc: "One two three four five"}, want: 5 * time.Second, desc: "intertitle timed by reading speed"},
+		{record: db.ProjectContentRecord{Keywords: "intertitle", SceneDesc: "One two three four five", DurationSec: 2}, want: 2 * time.Second, desc: "duration given"},
+		{record: db.ProjectContentRecord{Keywords: "sad", SceneDesc: "One two three four five"}, want: 0, desc: "ordinary scene"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := sceneDuration(&test.record, parseSceneKeywords(test.record.Keywords), options); got != test.want {
+				t.Errorf("Wanted %s got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestIntertitleContinuesPreviousMusic(t *testing.T) {
+	records := []db.ProjectContentRecord{
+		{Keywords: "sad march", DurationSec: 10, SceneDesc: "Farewell"},
+		{Keywords: "intertitle", SceneDesc: "Came the dawn"},
+		{Keywords: "intertitle", SceneDesc: "And the soldiers marched on"},
+	}
+	// Slow reading gives the cards time for the bars of the piece
+	result := pickMeasures(sadLibrary(), records, WithDiversityWindow(3), WithReadingSpeed(0.3))
+	if len(result.pieces) != 1 {
+		t.Fatalf("Wanted the cards to continue the piece of the scene got %d pieces", len(result.pieces))
+	}
+	if piece := result.pieces[0]; piece.end != 10*time.Second+10*time.Second+17*time.Second {
+		t.Errorf("Wanted the piece to last through both cards got %s", piece.end)
+	}
+
+	var boxedAt []int
+	for i, measure := range result.measures {
+		for _, text := range boxedTexts(measure) {
+			if text == "Farewell" {
+				t.Errorf("Wanted ordinary scene description as plain text")
+			}
+			boxedAt = append(boxedAt, i)
+		}
+		if i > 0 && rehearsalText(measure) != "" {
+			t.Errorf("Wanted no new cue under the cards got %s at measure %d", rehearsalText(measure), i+1)
+		}
+		if i+1 < len(result.measures) && endBarStyle(measure) == "light-light" {
+			t.Errorf("Wanted no scene end before the last measure got one at measure %d", i+1)
+		}
+	}
+	if len(boxedAt) != 2 || boxedAt[0] == 0 || boxedAt[1] <= boxedAt[0] {
+		t.Errorf("Wanted the card texts boxed in order after the start got measures %v", boxedAt)
+	}
+}
+
+func TestSeparateIntertitleResumesPreviousMusic(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		card db.ProjectContentRecord
+	}{
+		{desc: "timecode", card: db.ProjectContentRecord{Keywords: "intertitle", SceneDesc: "Later", Timecode: "00:00:06", DurationSec: 6, Tempo: 80}},
+		{desc: "theme", card: db.ProjectContentRecord{Keywords: "intertitle", SceneDesc: "Later", Theme: 1, DurationSec: 6, Tempo: 80}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			score := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: twoCharacterPiece()}))
+			library := InMemoryLibrary{Scores: []*musicxml.Scorepartwise{score}}
+			records := []db.ProjectContentRecord{{Keywords: "agitato", SceneDesc: "Storm", DurationSec: 6, Tempo: 80}, test.card}
+
+			// The scene plays section B only, and the card goes on with section C
+			result := pickMeasures(&library, records)
+			if len(result.pieces) != 2 {
+				t.Fatalf("Wanted a cue for the scene and for the card got %d pieces", len(result.pieces))
+			}
+			for i, want := range []string{"B", "C"} {
+				if mark := rehearsalText(result.pieces[i].cue[0]); mark != want {
+					t.Errorf("Wanted cue %d to start at rehearsal mark %s got %q", i+1, want, mark)
+				}
+			}
+		})
+	}
+}
+
+func TestCardMeasure(t *testing.T) {
+	for _, test := range []struct {
+		offset   time.Duration
+		duration time.Duration
+		want     int
+	}{
+		{offset: 0, duration: 10 * time.Second, want: 0},
+		{offset: 5 * time.Second, duration: 10 * time.Second, want: 4},
+		{offset: 10 * time.Second, duration: 10 * time.Second, want: 7},
+		{offset: 5 * time.Second, duration: 0, want: 0},
+	} {
+		if got := cardMeasure(8, test.offset, test.duration); got != test.want {
+			t.Errorf("Wanted measure %d for offset %s of %s got %d", test.want, test.offset, test.duration, got)
+		}
+	}
+}
+
+func TestIntertitleAfterTacetStartsMusic(t *testing.T) {
+	records := []db.ProjectContentRecord{
+		{Keywords: "tacet", DurationSec: 10, SceneDesc: "Silence"},
+		{Keywords: "intertitle", SceneDesc: "Sad news"},
+	}
+	result := pickMeasures(sadLibrary(), records)
+	if len(result.pieces) != 2 || result.pieces[1].title == tacetText {
+		t.Errorf("Wanted the card to get music of its own got %v", result.pieces)
+	}
+}
+
+func TestIntertitleWithKeywordsMatchesOwnMusic(t *testing.T) {
+	records := []db.ProjectContentRecord{
+		{Keywords: "sad march", DurationSec: 10, SceneDesc: "Farewell"},
+		{Keywords: "intertitle waltz", SceneDesc: "Came the dawn"},
+	}
+	result := pickMeasures(sadLibrary(), records)
+	if len(result.pieces) != 2 || result.pieces[1].title != "Sad waltz" {
+		t.Errorf("Wanted Sad waltz under the intertitle got %v", result.pieces)
+	}
+}
+
+func TestParseIntertitle(t *testing.T) {
+	keywords := parseSceneKeywords("intertitle sad")
+	if !keywords.intertitle || keywords.text != "sad" {
+		t.Errorf("Wanted intertitle with text sad got %+v", keywords)
+	}
+	if hasMatchingRule(parseSceneKeywords("intertitle")) {
+		t.Errorf("Wanted no matching rule for a bare intertitle")
+	}
+}
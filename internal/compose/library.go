@@ -1,14 +1,18 @@
 package compose
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"fmt"
+	"io"
 	"io/fs"
 	"iter"
 	"os"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	_ "embed"
@@ -43,8 +47,20 @@ type FileNameProvider interface {
 }
 
 type matchResult struct {
-	score      *musicxml.Scorepartwise
-	similarity int
+	score       *musicxml.Scorepartwise
+	fingerprint string
+	similarity  int
+	analysis    musicxml.Analysis
+
+	// Name of the file the piece was read from. Empty for pieces held in memory
+	source string
+
+	// Section the piece was ranked by. Nil for pieces without sections.
+	section *section
+
+	// Last section played in the previous scene when the piece continues
+	// under an intertitle. Nil when the piece starts with its best section.
+	resume *section
 }
 
 type StandardLibraryFileNameProvider struct {
@@ -100,12 +116,23 @@ func NewLocalLibraryFileNameProvider(directory string) *LocalFileNameProvider {
 
 type Library interface {
 	BestMatch(desc string) matchResult
+	BestMatches(desc string, n int, filter PieceFilter, opts ...ComposeOpt) []matchResult
 	Content() []LibraryContent
+	Health() []PieceHealth
+	Piece(fingerprint string) *musicxml.Scorepartwise
 }
 
 type LibraryContent struct {
-	ScoreTitle string
-	Composer   string
+	ScoreTitle  string
+	Composer    string
+	Fingerprint string
+	Analysis    musicxml.Analysis
+
+	// Name of the file the piece was read from. Empty for pieces held in memory
+	Source string
+
+	// Text fields of the piece used for text similarity
+	Text string
 }
 
 func (lc *LibraryContent) FilterValue() string {
@@ -115,28 +142,127 @@ func (lc *LibraryContent) FilterValue() string {
 func (lc *LibraryContent) Title() string {
 	return lc.ScoreTitle
 }
+
+// Description lists the composer followed by the key, meter, marked tempo,
+// pitch range, notes per beat and prevalent dynamics in columns
 func (lc *LibraryContent) Description() string {
-	return lc.Composer
+	tempo := ""
+	if lc.Analysis.Tempo > 0 {
+		tempo = fmt.Sprintf("\u2669=%d", lc.Analysis.Tempo)
+	}
+	return fmt.Sprintf("%-24s %-9s %-5s %-6s %-8s %4.1f/beat %s",
+		lc.Composer, lc.Analysis.Key(), lc.Analysis.Meter(), tempo, lc.Analysis.Range(), lc.Analysis.NotesPerBeat, lc.Analysis.Dynamics)
 }
 
 type FsLibrary struct {
-	nameProvider FileNameProvider
+	nameProvider   FileNameProvider
+	excludeFailing bool
+
+	// Populated by Load. When nil, pieces are read from the file system on demand
+	loaded []libraryEntry
+
+	// Index of each loaded piece by its fingerprint
+	byFingerprint map[string]int
+}
+
+type libraryEntry struct {
+	name        string
+	fingerprint string
+	score       *musicxml.Scorepartwise
+	health      PieceHealth
+	analysis    musicxml.Analysis
+	index       pieceIndex
 }
 
-func NewStandardLibrary() *FsLibrary {
-	return &FsLibrary{nameProvider: NewStandardLibraryFileNameProvider()}
+type FsLibraryOpt func(l *FsLibrary)
+
+// WithExcludeFailing excludes pieces that fail the health check from matching
+func WithExcludeFailing(exclude bool) FsLibraryOpt {
+	return func(l *FsLibrary) {
+		l.excludeFailing = exclude
+	}
+}
+
+func newFsLibrary(nameProvider FileNameProvider, opts ...FsLibraryOpt) *FsLibrary {
+	l := FsLibrary{nameProvider: nameProvider}
+	for _, opt := range opts {
+		opt(&l)
+	}
+	return &l
 }
 
-func NewLocalLibrary(directory string) *FsLibrary {
-	return &FsLibrary{nameProvider: NewLocalLibraryFileNameProvider(directory)}
+func NewStandardLibrary(opts ...FsLibraryOpt) *FsLibrary {
+	return newFsLibrary(NewStandardLibraryFileNameProvider(), opts...)
 }
 
-func (sl *FsLibrary) scores() iter.Seq[*musicxml.Scorepartwise] {
+func NewLocalLibrary(directory string, opts ...FsLibraryOpt) *FsLibrary {
+	return newFsLibrary(NewLocalLibraryFileNameProvider(directory), opts...)
+}
+
+func readEntry(fileSystem fs.FS, name string) libraryEntry {
+	score, data, err := readScoreFile(fileSystem, name)
+	if err != nil {
+		slog.Error("Failed to read score", "file", name, "error", err)
+	}
+	entry := libraryEntry{name: name, score: &score, health: CheckScore(name, &score, err), analysis: musicxml.Analyze(&score), index: indexPiece(&score)}
+	if data != nil {
+		entry.fingerprint = Fingerprint(data)
+	}
+	return entry
+}
+
+// readScoreFile reads the score like musicxml.ReadScore and also returns the
+// content of the file, such that the file is read only once. The content is
+// returned when the file can be read, even if it is not a valid score.
+func readScoreFile(fileSystem fs.FS, name string) (musicxml.Scorepartwise, []byte, error) {
+	data, err := fs.ReadFile(fileSystem, name)
+	if err != nil {
+		return musicxml.Scorepartwise{}, nil, err
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if strings.HasSuffix(name, ".mxl") {
+		if reader, err = musicxml.Zip2MusicXMLReader(reader); err != nil {
+			return musicxml.Scorepartwise{}, data, err
+		}
+	}
+	score, err := musicxml.ReadFromFile(reader)
+	return score, data, err
+}
+
+// allEntries iterates over every file in the library, including failing ones
+func (sl *FsLibrary) allEntries() iter.Seq[libraryEntry] {
+	if sl.loaded != nil {
+		return slices.Values(sl.loaded)
+	}
 	names := sl.nameProvider.Names()
-	return func(yield func(item *musicxml.Scorepartwise) bool) {
+	return func(yield func(item libraryEntry) bool) {
 		for _, name := range names {
-			score := musicxml.ReadFromFileName(sl.nameProvider.Fs(), name)
-			if !yield(&score) {
+			if !yield(readEntry(sl.nameProvider.Fs(), name)) {
+				break
+			}
+		}
+	}
+}
+
+// entries iterates over the files that take part in matching
+func (sl *FsLibrary) entries() iter.Seq[libraryEntry] {
+	return func(yield func(item libraryEntry) bool) {
+		for entry := range sl.allEntries() {
+			if sl.excludeFailing && entry.health.Failing() {
+				continue
+			}
+			if !yield(entry) {
+				break
+			}
+		}
+	}
+}
+
+func (sl *FsLibrary) scores() iter.Seq[*musicxml.Scorepartwise] {
+	return func(yield func(item *musicxml.Scorepartwise) bool) {
+		for entry := range sl.entries() {
+			if !yield(entry.score) {
 				break
 			}
 		}
@@ -144,62 +270,202 @@ func (sl *FsLibrary) scores() iter.Seq[*musicxml.Scorepartwise] {
 }
 
 func (sl *FsLibrary) BestMatch(desc string) matchResult {
-	texts := collectTextFields(sl.scores())
-	bestMatch := bestMatchForDesc(desc, texts)
-	name := sl.nameProvider.Names()[bestMatch.Index]
-	score := musicxml.ReadFromFileName(sl.nameProvider.Fs(), name)
-	return matchResult{
-		score:      &score,
-		similarity: bestMatch.Similarity}
+	return firstMatch(sl.BestMatches(desc, 1, PieceFilter{}))
+}
+
+// BestMatches ranks the pieces by their best matching section. The sections
+// are the ones the pieces are played in with the given options.
+func (sl *FsLibrary) BestMatches(desc string, n int, filter PieceFilter, opts ...ComposeOpt) []matchResult {
+	options := newComposeOptions(opts...)
+	var (
+		indices    []sectionIndex
+		candidates []libraryEntry
+	)
+	for entry := range sl.entries() {
+		if !filter.Matches(&entry.analysis) {
+			continue
+		}
+		indices = append(indices, entry.index.sectionIndex(entry.fingerprint, options))
+		if sl.loaded == nil {
+			// Only keep the name to avoid holding every piece in memory
+			entry.score = nil
+		}
+		candidates = append(candidates, entry)
+	}
+
+	var results []matchResult
+	for _, match := range bestSectionMatches(desc, indices, n) {
+		best := candidates[match.piece]
+		if best.score == nil {
+			score := musicxml.ReadFromFileName(sl.nameProvider.Fs(), best.name)
+			best.score = &score
+		}
+		results = append(results, matchResult{
+			score:       best.score,
+			fingerprint: best.fingerprint,
+			similarity:  match.similarity,
+			analysis:    best.analysis,
+			source:      best.name,
+			section:     indices[match.piece].section(match.section),
+		})
+	}
+	return results
+}
+
+func (sl *FsLibrary) Len() int {
+	return len(sl.nameProvider.Names())
+}
+
+// Load reads all pieces in the library in parallel. On cancellation the
+// library is left as it was before the call.
+func (sl *FsLibrary) Load(ctx context.Context, progress ProgressFunc) error {
+	names := sl.nameProvider.Names()
+	entries, err := readEntriesParallel(ctx, sl.nameProvider.Fs(), names, numLoadWorkers(), progress)
+	if err != nil {
+		return err
+	}
+	byFingerprint := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		if _, ok := byFingerprint[entry.fingerprint]; !ok && entry.fingerprint != "" {
+			byFingerprint[entry.fingerprint] = i
+		}
+	}
+	sl.loaded = entries
+	sl.byFingerprint = byFingerprint
+	return nil
 }
 
 func (sl *FsLibrary) Content() []LibraryContent {
-	return metadataFromScore(sl.scores())
+	var content []LibraryContent
+	for entry := range sl.entries() {
+		item := contentFromScore(entry.score, entry.fingerprint, entry.analysis)
+		item.Source = entry.name
+		content = append(content, item)
+	}
+	return content
+}
+
+func (sl *FsLibrary) Piece(fingerprint string) *musicxml.Scorepartwise {
+	if sl.loaded != nil {
+		if i, ok := sl.byFingerprint[fingerprint]; ok {
+			return sl.loaded[i].score
+		}
+		return nil
+	}
+	for entry := range sl.allEntries() {
+		if entry.fingerprint == fingerprint {
+			return entry.score
+		}
+	}
+	return nil
+}
+
+func (sl *FsLibrary) Health() []PieceHealth {
+	var health []PieceHealth
+	for entry := range sl.allEntries() {
+		health = append(health, entry.health)
+	}
+	return health
 }
 
 type InMemoryLibrary struct {
 	Scores []*musicxml.Scorepartwise
+
+	// Fingerprints of the scores, which are computed when first needed
+	mu           sync.Mutex
+	fingerprints map[*musicxml.Scorepartwise]string
+}
+
+// fingerprint returns the fingerprint of the score, such that a score is only
+// serialized once
+func (l *InMemoryLibrary) fingerprint(score *musicxml.Scorepartwise) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if fingerprint, ok := l.fingerprints[score]; ok {
+		return fingerprint
+	}
+	if l.fingerprints == nil {
+		l.fingerprints = make(map[*musicxml.Scorepartwise]string)
+	}
+	fingerprint := scoreFingerprint(score)
+	l.fingerprints[score] = fingerprint
+	return fingerprint
 }
 
 func (l *InMemoryLibrary) BestMatch(desc string) matchResult {
-	texts := collectTextFields(slices.Values(l.Scores))
-	result := bestMatchForDesc(desc, texts)
-	return matchResult{
-		score:      l.Scores[result.Index],
-		similarity: result.Similarity,
+	return firstMatch(l.BestMatches(desc, 1, PieceFilter{}))
+}
+
+func (l *InMemoryLibrary) BestMatches(desc string, n int, filter PieceFilter, opts ...ComposeOpt) []matchResult {
+	options := newComposeOptions(opts...)
+	var (
+		candidates []*musicxml.Scorepartwise
+		analyses   []musicxml.Analysis
+		indices    []sectionIndex
+	)
+	for _, score := range l.Scores {
+		analysis := musicxml.Analyze(score)
+		if filter.Matches(&analysis) {
+			candidates = append(candidates, score)
+			analyses = append(analyses, analysis)
+			index := indexPiece(score)
+			indices = append(indices, index.sectionIndex(l.fingerprint(score), options))
+		}
+	}
+
+	var results []matchResult
+	for _, match := range bestSectionMatches(desc, indices, n) {
+		results = append(results, matchResult{
+			score:       candidates[match.piece],
+			fingerprint: l.fingerprint(candidates[match.piece]),
+			similarity:  match.similarity,
+			analysis:    analyses[match.piece],
+			section:     indices[match.piece].section(match.section),
+		})
 	}
+	return results
 }
 
 func (l *InMemoryLibrary) Content() []LibraryContent {
-	return metadataFromScore(slices.Values(l.Scores))
+	content := make([]LibraryContent, len(l.Scores))
+	for i, score := range l.Scores {
+		content[i] = contentFromScore(score, l.fingerprint(score), musicxml.Analyze(score))
+	}
+	return content
 }
 
-func collectTextFields(scoreIter iter.Seq[*musicxml.Scorepartwise]) []string {
-	var texts []string
-	for score := range scoreIter {
-		texts = append(texts, strings.Join(musicxml.TextFields(*score), " "))
+func (l *InMemoryLibrary) Piece(fingerprint string) *musicxml.Scorepartwise {
+	for _, score := range l.Scores {
+		if l.fingerprint(score) == fingerprint {
+			return score
+		}
 	}
-	return texts
+	return nil
 }
 
-func metadataFromScore(scoreIter iter.Seq[*musicxml.Scorepartwise]) []LibraryContent {
-	var metadata []LibraryContent
-	for score := range scoreIter {
-		metadata = append(metadata, LibraryContent{
-			ScoreTitle: title(score),
-			Composer:   composer(score),
-		})
+func (l *InMemoryLibrary) Health() []PieceHealth {
+	health := make([]PieceHealth, len(l.Scores))
+	for i, score := range l.Scores {
+		health[i] = CheckScore(inMemoryName(i), score, nil)
 	}
-	return metadata
+	return health
 }
 
-func bestMatchForDesc(desc string, texts []string) Score {
-	normalizedDesc := normalize(desc)
-	normalizedTexts := make([]string, len(texts))
-	for i, text := range texts {
-		normalizedTexts[i] = normalize(text)
+func contentFromScore(score *musicxml.Scorepartwise, fingerprint string, analysis musicxml.Analysis) LibraryContent {
+	return LibraryContent{
+		ScoreTitle:  title(score),
+		Composer:    composer(score),
+		Fingerprint: fingerprint,
+		Analysis:    analysis,
+		Text:        strings.Join(musicxml.TextFields(*score), " "),
 	}
-	return orderPieces(normalizedDesc, normalizedTexts)[0]
+}
+
+func firstMatch(results []matchResult) matchResult {
+	if len(results) == 0 {
+		return matchResult{}
+	}
+	return results[0]
 }
 
 func tempoIfGiven(tempo int, measures []musicxml.Measure) *musicxml.Metronome {
@@ -261,6 +527,19 @@ func timesignature(measures []musicxml.Measure) *musicxml.Timesignature {
 	}
 }
 
+func keySignature(measures []musicxml.Measure) *musicxml.Key {
+	for _, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if attr := element.Attributes; attr != nil {
+				for _, key := range attr.Key {
+					return &key
+				}
+			}
+		}
+	}
+	return &musicxml.Key{}
+}
+
 func clearTempoMarkings(measures []musicxml.Measure) {
 	for measNo, measure := range measures {
 		for i, element := range measure.MusicDataElements {
@@ -284,13 +563,14 @@ func enumerateMeasuresInPlace(measures []musicxml.Measure) {
 type selection struct {
 	measures []musicxml.Measure
 	pieces   []pieceInfo
+	reels    []reelStart
 }
 
 func (s *selection) composer() string {
 	c := ""
 	seenComposers := make(map[string]struct{})
 	for _, piece := range s.pieces {
-		if _, ok := seenComposers[piece.composer]; ok {
+		if _, ok := seenComposers[piece.composer]; ok || piece.composer == "" {
 			continue
 		}
 		seenComposers[piece.composer] = struct{}{}
@@ -306,6 +586,18 @@ type pieceInfo struct {
 	title    string
 	composer string
 	cue      []musicxml.Measure
+
+	// Where the piece is played in the film
+	id    string
+	reel  uint
+	scene string
+	start time.Duration
+	end   time.Duration
+
+	source   string
+	sections []section
+	tempo    int
+	key      string
 }
 
 func firstN(measures []musicxml.Measure, n int) []musicxml.Measure {
@@ -338,109 +630,312 @@ func composer(score *musicxml.Scorepartwise) string {
 	return ""
 }
 
-func pickMeasures(library Library, records []db.ProjectContentRecord) selection {
+// measuresFromPiece returns the measures of a piece filling the given duration
+// together with the sections used and the tempo they are played at
+func measuresFromPiece(bm matchResult, keywords string, tempo int, duration time.Duration, vamp bool, options *composeOptions) ([]musicxml.Measure, sceneSection) {
+	piece := bm.score
+	measuresWithNoRepeats := removeRepetitions(piece.Part[0].Measure)
+	sections := manualSections(options.sectionStore, bm.fingerprint, len(measuresWithNoRepeats))
+	if len(sections) > 0 {
+		slog.Info("Using manually defined sections", "title", title(piece), "num-sections", len(sections))
+	} else {
+		sections = sectionsWithDetection(measuresWithNoRepeats, options)
+		slog.Info("Extracted sections", "title", title(piece), "num-sections", len(sections), "detection", options.sectionDetection)
+	}
+	if bm.resume != nil {
+		sections = sectionsAfter(sections, bm.resume.end)
+		slog.Info("Continuing piece", "title", title(piece), "measure", bm.resume.end)
+	} else {
+		sectionScores := matchSections(keywords, measuresWithNoRepeats, sections)
+		if bm.section != nil {
+			sectionScores = preferSection(sectionScores, sectionContaining(sections, bm.section.start), bm.similarity)
+		}
+		if len(sectionScores) > 0 {
+			slog.Info("Best matching section", "title", title(piece), "section", sectionScores[0].Index, "similarity-score", sectionScores[0].Similarity)
+		}
+		sections = preferMatchingSections(sections, sectionScores)
+	}
+	timeSignature := timesignature(measuresWithNoRepeats)
+	key := keySignature(measuresWithNoRepeats)
+	metronome := tempoIfGiven(tempo, measuresWithNoRepeats)
+	beatsInTimeSig := beatsPerMeasure(timeSignature, metronome)
+	sceneSection := sectionForScene(duration, float64(metronome.Perminute.Value), beatsInTimeSig, sections)
+
+	// Update tempo with result from scence selection
+	metronome.Perminute.Value = int(sceneSection.tempo)
+	measuresForScene := measuresForScene(measuresWithNoRepeats, sceneSection)
+	clearTempoMarkings(measuresForScene)
+	if vamp {
+		markVamp(vampSection(measuresForScene, sceneSection))
+	}
+
+	if len(measuresForScene) > 0 {
+		musicxml.SetTimeSignatureAtBeginning(&measuresForScene[0], *timeSignature)
+		musicxml.SetKeyAtBeginning(&measuresForScene[0], *key)
+		musicxml.SetTempoAtBeginning(&measuresForScene[0], metronome)
+	}
+	slog.Info("Picking piece",
+		"keywords", keywords,
+		"similarity-score", bm.similarity,
+		"title", title(piece),
+		"timeSignature", fmt.Sprintf("%d/%d", timeSignature.Beats, timeSignature.Beattype),
+		"tempo", metronome.Perminute.Value,
+	)
+	return measuresForScene, sceneSection
+}
+
+// fromStart returns the matches such that they start with their best section
+// rather than continue from where they stopped in a previous scene
+func fromStart(matches []matchResult) []matchResult {
+	result := slices.Clone(matches)
+	for i := range result {
+		result[i].resume = nil
+	}
+	return result
+}
+
+// matchesForScene returns the pieces used in a scene. A medley scene uses
+// several pieces ordered such that the keys at the joins are compatible.
+// Recently used pieces are penalized when recent is given.
+// The pieces are ranked by the sections they are played in with the options.
+func matchesForScene(library Library, keywords sceneKeywords, recent *recentPieces, intensity uint, opts []ComposeOpt) []matchResult {
+	num := max(keywords.medley, 1)
+	numCandidates := num
+	if recent != nil {
+		numCandidates += recent.window
+	}
+	if intensity > 0 {
+		numCandidates += numIntensityCandidates
+	}
+
+	var matches []matchResult
+	if keywords.reference != "" {
+		matches = similarMatches(library, keywords.reference, keywords, numCandidates)
+	} else {
+		matches = library.BestMatches(keywords.text, numCandidates, keywords.filter, opts...)
+	}
+	matches = slices.DeleteFunc(matches, func(bm matchResult) bool {
+		return bm.score == nil || len(bm.score.Part) == 0
+	})
+	matches = recent.rerank(matches, intensity)
+	return chainByKey(matches[:min(num, len(matches))])
+}
+
+// pinnedMatch returns the piece pinned to a scene. It reports false if the scene has no pin
+// or the pinned piece is not in the library. Pinned pieces are never re-ranked.
+func pinnedMatch(library Library, fingerprint string) ([]matchResult, bool) {
+	if fingerprint == "" {
+		return nil, false
+	}
+	score := library.Piece(fingerprint)
+	if score == nil || len(score.Part) == 0 {
+		slog.Warn("Pinned piece is not in the library", "fingerprint", fingerprint)
+		return nil, false
+	}
+	return []matchResult{{score: score, fingerprint: fingerprint, analysis: musicxml.Analyze(score)}}, true
+}
+
+func pickMeasures(library Library, records []db.ProjectContentRecord, opts ...ComposeOpt) selection {
+	options := newComposeOptions(opts...)
 	var measures []musicxml.Measure
 	var pieces []pieceInfo
-	scoresByTheme := make(map[uint]matchResult)
-	for _, record := range records {
-		bm, ok := scoresByTheme[record.Theme]
+	scoresByTheme := make(map[uint][]matchResult)
+	recent := recentPieces{window: options.diversityWindow}
+	var prevIntensity uint
+
+	// Pieces of the previous scene. Nil after a tacet scene.
+	var prevMatches []matchResult
+
+	// Time from the start of the film to the start of the scene
+	var elapsed time.Duration
+
+	cues := make(cueCounter)
+	var reels []reelStart
+
+	// Fingerprint of the first piece in each scene
+	scenePieces := make([]string, 0, len(records))
+
+	// Number of intertitles left that are played as part of the scene before them
+	var merged int
+	for i, record := range records {
+		if merged > 0 {
+			merged--
+			scenePieces = append(scenePieces, scenePieces[len(scenePieces)-1])
+			continue
+		}
+
+		keywords := parseSceneKeywords(record.Keywords)
+		if keywords.like != "" {
+			keywords.reference = resolveReference(library, keywords.like, scenePieces)
+		}
+		duration := sceneDuration(&record, keywords, options)
+
+		// Scenes with a timecode start at it, and the scenes after count from there
+		if timecode, ok := record.StartTime(); ok {
+			elapsed = timecode
+		}
+		start := elapsed
+		elapsed += duration
+		reel := record.ReelNumber()
+		if len(reels) == 0 || reels[len(reels)-1].number != reel {
+			reels = append(reels, reelStart{number: reel, measure: len(measures)})
+		}
+		if isTacet(keywords, options) {
+			sceneMeasures := tacetMeasures(measures, int(record.Tempo), duration)
+			cue := firstN(sceneMeasures, 1)
+			musicxml.SetSystemTextAtBeginning(&cue[0], record.SceneDesc)
+			id := cues.next(reel)
+			pieces = append(pieces, pieceInfo{title: tacetText, cue: cue, id: id, reel: reel, scene: record.SceneDesc, start: start, end: elapsed})
+			markCue(&sceneMeasures[0], id)
+
+			setSceneText(&sceneMeasures[0], record.SceneDesc, keywords.intertitle)
+			setSceneEnd(&sceneMeasures[len(sceneMeasures)-1], musicxml.BarStyleLightLight)
+			slog.Info("Picked tacet scene", "sceneDesc", record.SceneDesc, "num-measures", len(sceneMeasures))
+			measures = append(measures, sceneMeasures...)
+			scenePieces = append(scenePieces, "")
+			prevIntensity = 0
+			prevMatches = nil
+			continue
+		}
+
+		// The music continues under the intertitles following the scene
+		cards := continuingIntertitles(records, i, options)
+		merged = len(cards)
+		sceneLength := duration
+		for _, card := range cards {
+			duration += card
+		}
+		elapsed = start + duration
+
+		matches, ok := pinnedMatch(library, record.Pinned)
 		if !ok {
-			bm = library.BestMatch(record.Keywords)
+			matches, ok = scoresByTheme[record.Theme]
+		}
+		if !ok && keywords.intertitle && !hasMatchingRule(keywords) {
+			// The music of the previous scene is taken up again under the card
+			matches, ok = prevMatches, len(prevMatches) > 0
+			if !ok {
+				keywords.text = record.SceneDesc
+			}
+		}
+		if !ok && record.Theme > 0 {
+			// The diversity penalty never overrides the piece chosen for a theme
+			matches = matchesForScene(library, keywords, nil, record.Intensity, opts)
+		} else if !ok {
+			matches = matchesForScene(library, keywords, &recent, record.Intensity, opts)
+			recent.add(matches)
 		}
 
 		if record.Theme > 0 {
-			scoresByTheme[record.Theme] = bm
-		}
-		piece := bm.score
-
-		if piece != nil {
-			if len(piece.Part) > 0 {
-				measuresWithNoRepeats := removeRepetitions(piece.Part[0].Measure)
-				sections := pieceSections(measuresWithNoRepeats)
-				slog.Info("Extracted sections", "title", title(piece), "num-sections", len(sections))
-				timeSignature := timesignature(measuresWithNoRepeats)
-				metronome := tempoIfGiven(int(record.Tempo), measuresWithNoRepeats)
-				beatsInTimeSig := beatsPerMeasure(timeSignature, metronome)
-				sceneSection := sectionForScene(time.Duration(record.DurationSec)*time.Second, float64(metronome.Perminute.Value), beatsInTimeSig, sections)
-
-				// Update tempo with result from scence selection
-				metronome.Perminute.Value = int(sceneSection.tempo)
-				measuresForScene := measuresForScene(measuresWithNoRepeats, sceneSection)
-				clearTempoMarkings(measuresForScene)
-
-				if len(measuresForScene) > 0 {
-					musicxml.SetSystemTextAtBeginning(&measuresForScene[0], record.SceneDesc)
-					musicxml.SetTimeSignatureAtBeginning(&measuresForScene[0], *timeSignature)
-					musicxml.SetTempoAtBeginning(&measuresForScene[0], metronome)
-					barline := musicxml.NewBarline(musicxml.WithBarStyle(musicxml.BarStyleLightLight))
-					musicxml.SetBarlineAtEnd(&measuresForScene[len(measuresForScene)-1], barline)
-				}
-				measures = append(measures, measuresForScene...)
-				slog.Info("Picking piece",
-					"keywords", record.Keywords,
-					"sceneDesc", record.SceneDesc,
-					"similarity-score", bm.similarity,
-					"title", title(piece),
-					"timeSignature", fmt.Sprintf("%d/%d", timeSignature.Beats, timeSignature.Beattype),
-					"tempo", metronome.Perminute.Value,
-				)
-
-				pieces = append(pieces, pieceInfo{
-					title:    title(piece),
-					composer: composer(piece),
-					cue:      firstN(measuresForScene, numBarsInCueSheet),
-				},
-				)
+			scoresByTheme[record.Theme] = fromStart(matches)
+		}
+		scenePieces = append(scenePieces, firstMatch(matches).fingerprint)
+		prevMatches = matches
+
+		var sceneMeasures []musicxml.Measure
+		for j, bm := range matches {
+			vamp := keywords.vamp && j == len(matches)-1
+			pieceDuration := duration / time.Duration(len(matches))
+			pieceMeasures, used := measuresFromPiece(bm, keywords.text, int(record.Tempo), pieceDuration, vamp, options)
+			cue := firstN(pieceMeasures, numBarsInCueSheet)
+			id := cues.next(reel)
+			if len(pieceMeasures) > 0 {
+				markCue(&pieceMeasures[0], id)
+			}
+			if len(sceneMeasures) > 0 && len(pieceMeasures) > 0 {
+				musicxml.SetSystemTextAtBeginning(&pieceMeasures[0], segueText(bm.score))
+			}
+			sceneMeasures = append(sceneMeasures, pieceMeasures...)
+
+			pieces = append(pieces, pieceInfo{
+				title:    title(bm.score),
+				composer: composer(bm.score),
+				cue:      cue,
+				id:       id,
+				reel:     reel,
+				scene:    record.SceneDesc,
+				start:    start + time.Duration(j)*pieceDuration,
+				end:      start + time.Duration(j+1)*pieceDuration,
+				source:   bm.source,
+				sections: used.sections,
+				tempo:    int(used.tempo),
+				key:      bm.analysis.Key(),
+			},
+			)
+
+			// A card after the scene takes up the last piece where it stopped
+			if j == len(matches)-1 && len(used.sections) > 0 {
+				bm.resume = &used.sections[len(used.sections)-1]
+				prevMatches = []matchResult{bm}
+			}
+		}
+
+		if len(sceneMeasures) > 0 {
+			setSceneText(&sceneMeasures[0], record.SceneDesc, keywords.intertitle)
+			offset := sceneLength
+			for j, card := range cards {
+				setSceneText(&sceneMeasures[cardMeasure(len(sceneMeasures), offset, duration)], records[i+1+j].SceneDesc, true)
+				offset += card
+			}
+			setSceneEnd(&sceneMeasures[len(sceneMeasures)-1], musicxml.BarStyleLightLight)
+			if next := i + 1 + merged; keywords.vamp && next < len(records) {
+				cue := musicxml.NewDirection(musicxml.WithWords(cueTextPrefix + records[next].SceneDesc))
+				musicxml.AddDirectionAtBeginning(&sceneMeasures[len(sceneMeasures)-1], cue)
 			}
+			markIntensity(measures, sceneMeasures, prevIntensity, record.Intensity)
+			prevIntensity = record.Intensity
 		}
+		slog.Info("Picked scene", "sceneDesc", record.SceneDesc, "num-pieces", len(matches), "num-measures", len(sceneMeasures))
+		measures = append(measures, sceneMeasures...)
 	}
 	enumerateMeasuresInPlace(measures)
 
 	// Add barline at very end
-	barline := musicxml.NewBarline(musicxml.WithBarStyle(musicxml.BarStyleLightHeavy))
 	if len(measures) > 0 {
-		musicxml.SetBarlineAtEnd(&measures[len(measures)-1], barline)
+		setSceneEnd(&measures[len(measures)-1], musicxml.BarStyleLightHeavy)
 	}
 
-	removeRedundantClefs(measures)
+	// Clefs are kept at the start of each reel such that the reels can be printed separately
+	for _, segment := range reelSegments(reels, len(measures)) {
+		removeRedundantClefs(measures[segment[0]:segment[1]])
+	}
 	removeRepeatJumps(measures)
-	return selection{measures: measures, pieces: pieces}
+	return selection{measures: measures, pieces: pieces, reels: reels}
 }
 
-func CreateComposition(library Library, project *db.Project) *musicxml.Scorepartwise {
-	result := pickMeasures(library, project.Records)
-	slog.Info("Creating composition", "projectName", project.Name, "measuresCount", len(result.measures))
+// Composition is the music picked for a project
+type Composition struct {
+	// Score with the music of every reel
+	Score *musicxml.Scorepartwise
 
-	// Insert page breaks and line breaks
-	var allMeasures []musicxml.Measure
+	CueSheet *CueSheet
 
-	if len(result.measures) > 0 {
-		result.measures[0].MusicDataElements = ensurePageBreak(result.measures[0].MusicDataElements)
-	}
-	for i := 0; i < len(result.pieces); i++ {
-		if len(result.pieces[i].cue) == 0 {
-			continue
-		}
-		for j := 0; j < len(result.pieces[i].cue); j++ {
-			result.pieces[i].cue[j].MusicDataElements = clearPrint(result.pieces[i].cue[j].MusicDataElements)
-		}
-		var elements []musicxml.MusicDataElement
-		if i == 0 {
-			elements = ensurePageBreak(result.pieces[i].cue[0].MusicDataElements)
-		} else {
-			elements = ensureLineBreak(result.pieces[i].cue[0].MusicDataElements)
-		}
+	// One score per reel in the order the reels are first played
+	Reels []*musicxml.Scorepartwise
+}
 
-		result.pieces[i].cue[0].MusicDataElements = elements
+// CreateComposition picks the music for the scenes of the project. Every reel
+// starts on a new page.
+func CreateComposition(library Library, project *db.Project, opts ...ComposeOpt) *Composition {
+	opts = append([]ComposeOpt{WithDiversityWindow(project.DiversityWindow), WithReadingSpeed(project.ReadingSpeed)}, opts...)
+	result := pickMeasures(library, project.Records, opts...)
+	slog.Info("Creating composition", "projectName", project.Name, "measuresCount", len(result.measures), "numReels", len(result.reels))
+
+	reels := reelScores(project, &result)
+	for _, reel := range result.reels {
+		if reel.measure < len(result.measures) {
+			result.measures[reel.measure].MusicDataElements = ensurePageBreak(result.measures[reel.measure].MusicDataElements)
+		}
 	}
-
-	// Merge all measures
-	for _, piece := range result.pieces {
-		allMeasures = append(allMeasures, piece.cue...)
+	return &Composition{
+		Score:    newScore(project.Name, result.composer(), result.measures),
+		CueSheet: newCueSheet(project.Name, result.pieces),
+		Reels:    reels,
 	}
-	allMeasures = append(allMeasures, result.measures...)
-	enumerateMeasuresInPlace(allMeasures)
+}
 
+// newScore returns a piano score with the measures
+func newScore(workTitle, composer string, measures []musicxml.Measure) *musicxml.Scorepartwise {
 	composition := musicxml.Scorepartwise{
 		Documentattributes: musicxml.Documentattributes{
 			VersionAttr: "4.0",
@@ -450,12 +945,12 @@ func CreateComposition(library Library, project *db.Project) *musicxml.Scorepart
 				Partattributes: musicxml.Partattributes{
 					IdAttr: "P1",
 				},
-				Measure: allMeasures,
+				Measure: measures,
 			},
 		},
 		Scoreheader: musicxml.Scoreheader{
 			Work: &musicxml.Work{
-				Worktitle: project.Name,
+				Worktitle: workTitle,
 			},
 			Partlist: &musicxml.Partlist{
 				Scorepart: &musicxml.Scorepart{
@@ -479,12 +974,12 @@ func CreateComposition(library Library, project *db.Project) *musicxml.Scorepart
 				{
 					PageAttr:    1,
 					Credittype:  []string{"title"},
-					Creditwords: musicxml.TitleElement(project.Name),
+					Creditwords: musicxml.TitleElement(workTitle),
 				},
 				{
 					PageAttr:    1,
 					Credittype:  []string{"composer"},
-					Creditwords: musicxml.ComposerElement(result.composer()),
+					Creditwords: musicxml.ComposerElement(composer),
 				},
 			},
 		},
@@ -58,7 +58,7 @@ func TestCompose(t *testing.T) {
 		scores := rapid.SliceOfN(scoreGen, 1, 3).Draw(t, "scores")
 		library := &InMemoryLibrary{Scores: scores}
 		project := test.GenerateProjects(t)[0]
-		composition := CreateComposition(library, &project)
+		composition := CreateComposition(library, &project).Score
 
 		if !measuresAreEnumerated(composition.Part[0].Measure) {
 			t.Errorf("Measures are not enumerated correctly")
@@ -396,6 +396,44 @@ func TestLocalLibrary(t *testing.T) {
 	if bestMatch.score.Work.Worktitle != expect {
 		t.Errorf("Expected work title %s, got %s", expect, bestMatch.score.Work.Worktitle)
 	}
+	if bestMatch.fingerprint == "" {
+		t.Errorf("Expected the best match to have a fingerprint")
+	}
+}
+
+func TestReadEntryFingerprintsFile(t *testing.T) {
+	_, currentFile, _, _ := runtime.Caller(0)
+	testData := os.DirFS(filepath.Join(filepath.Dir(currentFile), "../../test/data"))
+	for _, name := range []string{"testScore.musicxml", "Saltarello.mxl", "missing.musicxml"} {
+		t.Run(name, func(t *testing.T) {
+			entry := readEntry(testData, name)
+			want := ""
+			if data, err := fs.ReadFile(testData, name); err == nil {
+				want = Fingerprint(data)
+			}
+			if entry.fingerprint != want {
+				t.Errorf("Wanted fingerprint %q got %q", want, entry.fingerprint)
+			}
+			if failing := entry.health.Failing(); failing != (want == "") {
+				t.Errorf("Wanted only the missing file to fail got %+v", entry.health)
+			}
+		})
+	}
+}
+
+func TestInMemoryFingerprintIsCached(t *testing.T) {
+	score := musicxml.NewScorePartwise()
+	library := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{score}}
+	want := scoreFingerprint(score)
+	if got := library.Content()[0].Fingerprint; got != want {
+		t.Errorf("Wanted %s got %s", want, got)
+	}
+	if got := library.fingerprints[score]; got != want {
+		t.Errorf("Wanted the fingerprint cached got %q", got)
+	}
+	if library.Piece(want) != score {
+		t.Errorf("Wanted the score found by its fingerprint")
+	}
 }
 
 func TestLibrarySelection(t *testing.T) {
@@ -0,0 +1,72 @@
+package compose
+
+import (
+	"context"
+	"io/fs"
+	"runtime"
+	"sync"
+)
+
+// ProgressFunc is called each time a piece has been read. done is the number
+// of pieces read so far and total is the number of pieces being read.
+type ProgressFunc func(done, total int)
+
+// Loader is implemented by libraries that must read their pieces before they
+// can be used. Load reads the pieces up front, such that later calls to
+// BestMatch and Content do not have to touch the file system.
+type Loader interface {
+	Len() int
+	Load(ctx context.Context, progress ProgressFunc) error
+}
+
+func numLoadWorkers() int {
+	return runtime.NumCPU()
+}
+
+// readEntriesParallel reads all the given files with a bounded number of workers.
+// The returned entries have the same order as names.
+func readEntriesParallel(ctx context.Context, fileSystem fs.FS, names []string, workers int, progress ProgressFunc) ([]libraryEntry, error) {
+	if progress == nil {
+		progress = func(done, total int) {}
+	}
+
+	entries := make([]libraryEntry, len(names))
+	jobs := make(chan int)
+	done := make(chan struct{}, len(names))
+
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entries[i] = readEntry(fileSystem, names[i])
+				done <- struct{}{}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range names {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	progress(0, len(names))
+	for n := 1; n <= len(names); n++ {
+		select {
+		case <-done:
+			progress(n, len(names))
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+	}
+	wg.Wait()
+	return entries, nil
+}
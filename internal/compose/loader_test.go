@@ -0,0 +1,112 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadScoresParallelKeepsOrder(t *testing.T) {
+	provider := NewStandardLibraryFileNameProvider()
+	names := provider.Names()
+
+	var reported []int
+	entries, err := readEntriesParallel(context.Background(), provider.Fs(), names, 4, func(done, total int) {
+		if total != len(names) {
+			t.Errorf("Wanted total %d got %d", len(names), total)
+		}
+		reported = append(reported, done)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(reported) != len(names)+1 || reported[len(reported)-1] != len(names) {
+		t.Errorf("Wanted progress 0..%d got %v", len(names), reported)
+	}
+
+	sequential := NewStandardLibrary()
+	i := 0
+	for score := range sequential.scores() {
+		if title(score) != title(entries[i].score) {
+			t.Errorf("Wanted %s at position %d got %s", title(score), i, title(entries[i].score))
+		}
+		i++
+	}
+}
+
+func TestReadScoresParallelCancelled(t *testing.T) {
+	provider := NewStandardLibraryFileNameProvider()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := readEntriesParallel(ctx, provider.Fs(), provider.Names(), 2, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Wanted context.Canceled got %v", err)
+	}
+}
+
+func TestLoadedLibraryBestMatch(t *testing.T) {
+	sl := NewStandardLibrary()
+	if err := sl.Load(context.Background(), nil); err != nil {
+		t.Error(err)
+		return
+	}
+
+	desc := "Andante Doloroso, No. 70"
+	if score := sl.BestMatch(desc).score; score.Work.Worktitle != desc {
+		t.Errorf("Expected work title %s, got %s", desc, score.Work.Worktitle)
+	}
+
+	if n := len(sl.Content()); n != sl.Len() {
+		t.Errorf("Wanted %d pieces got %d", sl.Len(), n)
+	}
+}
+
+func TestLoadedLibraryPieceByFingerprint(t *testing.T) {
+	sl := NewStandardLibrary()
+	if err := sl.Load(context.Background(), nil); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(sl.byFingerprint) != sl.Len() {
+		t.Errorf("Wanted %d indexed pieces got %d", sl.Len(), len(sl.byFingerprint))
+	}
+
+	// Same pieces as found by reading the files
+	unloaded := NewStandardLibrary()
+	for _, content := range unloaded.Content() {
+		if got, want := title(sl.Piece(content.Fingerprint)), title(unloaded.Piece(content.Fingerprint)); got != want {
+			t.Errorf("Wanted %s got %s", want, got)
+		}
+	}
+	if sl.Piece("unknown") != nil {
+		t.Errorf("Wanted no piece for an unknown fingerprint")
+	}
+}
+
+func TestMultiSourceLibraryLoadProgress(t *testing.T) {
+	library := NewMultiSourceLibrary(NewStandardLibrary(), &InMemoryLibrary{}, NewStandardLibrary())
+	total := library.Len()
+	if total != 2*NewStandardLibrary().Len() {
+		t.Errorf("Wanted in-memory library to not count got %d", total)
+	}
+
+	last := 0
+	err := library.Load(context.Background(), func(done, n int) {
+		if n != total {
+			t.Errorf("Wanted total %d got %d", total, n)
+		}
+		if done < last {
+			t.Errorf("Progress should not decrease: %d after %d", done, last)
+		}
+		last = done
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if last != total {
+		t.Errorf("Wanted final progress %d got %d", total, last)
+	}
+}
@@ -0,0 +1,93 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+// Fingerprint identifies a piece by the content of its file
+func Fingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func scoreFingerprint(score *musicxml.Scorepartwise) string {
+	data, err := xml.Marshal(score)
+	if err != nil {
+		slog.Error("Failed to serialize score", "error", err)
+		return ""
+	}
+	return Fingerprint(data)
+}
+
+// PlayableMeasures returns the measures of the first part with the repetitions
+// removed. Bars of manually defined sections refer to these measures.
+func PlayableMeasures(score *musicxml.Scorepartwise) []musicxml.Measure {
+	if score == nil || len(score.Part) == 0 {
+		return nil
+	}
+	return removeRepetitions(score.Part[0].Measure)
+}
+
+func rehearsalText(measure musicxml.Measure) string {
+	for _, element := range measure.MusicDataElements {
+		if direction := element.Direction; direction != nil {
+			for _, dirType := range direction.Directiontype {
+				for _, rehearsal := range dirType.Rehearsal {
+					if rehearsal.Value != "" {
+						return rehearsal.Value
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// SuggestedSections returns the sections found from rehearsal marks and
+// detected boundaries. They serve as a starting point when the sections of a
+// piece are defined manually.
+func SuggestedSections(measures []musicxml.Measure) []db.PieceSection {
+	sections := sectionsWithDetection(measures, newComposeOptions())
+	result := make([]db.PieceSection, len(sections))
+	for i, s := range sections {
+		name := rehearsalText(measures[s.start])
+		if name == "" {
+			name = fmt.Sprintf("Section %d", i+1)
+		}
+		result[i] = db.PieceSection{Name: name, FirstBar: s.start + 1, LastBar: s.end}
+	}
+	return result
+}
+
+// manualSections returns the enabled sections defined by the user. Sections
+// that do not fit the piece are skipped.
+func manualSections(store db.SectionStore, fingerprint string, numMeasures int) []section {
+	if store == nil || fingerprint == "" {
+		return nil
+	}
+	defined, err := store.LoadSections(fingerprint)
+	if err != nil {
+		slog.Error("Failed to load sections", "fingerprint", fingerprint, "error", err)
+		return nil
+	}
+
+	var sections []section
+	for _, s := range defined {
+		if s.Disabled {
+			continue
+		}
+		if s.FirstBar < 1 || s.LastBar < s.FirstBar || s.FirstBar > numMeasures {
+			slog.Warn("Skipping invalid section", "name", s.Name, "firstBar", s.FirstBar, "lastBar", s.LastBar, "numMeasures", numMeasures)
+			continue
+		}
+		sections = append(sections, section{start: s.FirstBar - 1, end: min(s.LastBar, numMeasures)})
+	}
+	return sections
+}
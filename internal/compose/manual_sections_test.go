@@ -0,0 +1,149 @@
+package compose
+
+import (
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint([]byte("abc")) != Fingerprint([]byte("abc")) {
+		t.Errorf("Wanted equal fingerprints for equal content")
+	}
+	if Fingerprint([]byte("abc")) == Fingerprint([]byte("abd")) {
+		t.Errorf("Wanted different fingerprints for different content")
+	}
+}
+
+func TestManualSections(t *testing.T) {
+	store := db.NewInMemorySectionStore()
+	store.SaveSections("piece", []db.PieceSection{
+		{Name: "A", FirstBar: 1, LastBar: 4},
+		{Name: "Disabled", FirstBar: 5, LastBar: 6, Disabled: true},
+		{Name: "Reversed", FirstBar: 6, LastBar: 5},
+		{Name: "Outside", FirstBar: 20, LastBar: 24},
+		{Name: "Too long", FirstBar: 7, LastBar: 12},
+	})
+
+	for _, test := range []struct {
+		desc        string
+		store       db.SectionStore
+		fingerprint string
+		want        []section
+	}{
+		{
+			desc:        "valid sections are used",
+			store:       store,
+			fingerprint: "piece",
+			want:        []section{{start: 0, end: 4}, {start: 6, end: 8}},
+		},
+		{
+			desc:        "no store",
+			fingerprint: "piece",
+		},
+		{
+			desc:  "no fingerprint",
+			store: store,
+		},
+		{
+			desc:        "no sections defined",
+			store:       store,
+			fingerprint: "other",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got := manualSections(test.store, test.fingerprint, 8)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSuggestedSections(t *testing.T) {
+	sections := SuggestedSections(eightBarPiece())
+	want := []db.PieceSection{
+		{Name: "Section 1", FirstBar: 1, LastBar: 1},
+		{Name: "A", FirstBar: 2, LastBar: 3},
+		{Name: "B", FirstBar: 4, LastBar: 8},
+	}
+	if !slices.Equal(sections, want) {
+		t.Errorf("Wanted %v got %v", want, sections)
+	}
+}
+
+func TestPlayableMeasuresNoParts(t *testing.T) {
+	if measures := PlayableMeasures(nil); measures != nil {
+		t.Errorf("Wanted no measures got %v", measures)
+	}
+	if measures := PlayableMeasures(musicxml.NewScorePartwise()); measures != nil {
+		t.Errorf("Wanted no measures got %v", measures)
+	}
+}
+
+func TestPickMeasuresPrefersManualSections(t *testing.T) {
+	measures := make([]musicxml.Measure, 8)
+	for i := range measures {
+		measures[i] = *musicxml.NewMeasure(musicxml.WithRehersalMark(strconv.Itoa(i + 1)))
+	}
+	enumerateMeasuresInPlace(measures)
+
+	score := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: measures}))
+	library := InMemoryLibrary{Scores: []*musicxml.Scorepartwise{score}}
+
+	store := db.NewInMemorySectionStore()
+	store.SaveSections(scoreFingerprint(score), []db.PieceSection{{Name: "Middle", FirstBar: 2, LastBar: 3}})
+
+	records := []db.ProjectContentRecord{{DurationSec: 60, Tempo: 80}}
+	result := pickMeasures(&library, records, WithManualSections(store))
+	if len(result.measures) == 0 {
+		t.Errorf("Wanted measures in the selection")
+		return
+	}
+	for _, measure := range result.measures {
+		if mark := rehearsalText(measure); mark != "2" && mark != "3" {
+			t.Errorf("Wanted only bars 2 and 3 got rehearsal mark %q", mark)
+			return
+		}
+	}
+}
+
+func TestPieceByFingerprint(t *testing.T) {
+	_, currentFile, _, _ := runtime.Caller(0)
+	testData := filepath.Join(filepath.Dir(currentFile), "../../test/data")
+
+	inMemory := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{
+		musicxml.NewScorePartwise(musicxml.WithComposer("Bach")),
+		musicxml.NewScorePartwise(musicxml.WithComposer("Chopin")),
+	}}
+
+	for _, test := range []struct {
+		desc string
+		lib  Library
+	}{
+		{desc: "in memory", lib: inMemory},
+		{desc: "local", lib: NewLocalLibrary(testData)},
+		{desc: "multi source", lib: NewMultiSourceLibrary(NewLocalLibrary(testData), inMemory)},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			for _, content := range test.lib.Content() {
+				if content.Fingerprint == "" {
+					t.Errorf("Wanted fingerprint for %s", content.ScoreTitle)
+					return
+				}
+				score := test.lib.Piece(content.Fingerprint)
+				if score == nil || title(score) != content.ScoreTitle || composer(score) != content.Composer {
+					t.Errorf("Wanted piece %v got %v", content, score)
+				}
+			}
+			if score := test.lib.Piece("unknown"); score != nil {
+				t.Errorf("Wanted no piece for unknown fingerprint")
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package compose
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	markersSuffix = "_markers"
+
+	// Frame rate used for markers when none is given
+	defaultMarkerFrameRate = 24.0
+)
+
+// markerLabel names the cue by its ID and title, followed by the tempo
+func (c *Cue) markerLabel() string {
+	label := fmt.Sprintf("%s %s", c.ID, c.Title)
+	if c.Tempo > 0 {
+		label += fmt.Sprintf(" (%d BPM)", c.Tempo)
+	}
+	return label
+}
+
+// frames returns the number of whole frames in the duration
+func frames(d time.Duration, fps float64) int64 {
+	return int64(math.Round(d.Seconds() * fps))
+}
+
+// formatTimecode formats the duration as a non drop frame timecode
+func formatTimecode(d time.Duration, fps float64) string {
+	nominal := int64(math.Round(fps))
+	count := frames(d, fps)
+	seconds := count / nominal
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", seconds/3600, (seconds/60)%60, seconds%60, count%nominal)
+}
+
+// formatSrtTime formats the duration as a SubRip time like 00:01:02,500
+func formatSrtTime(d time.Duration) string {
+	ms := d.Round(time.Millisecond).Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// WriteEDL writes the cues as a CMX3600 edit decision list with one event and
+// one locator per cue
+func (c *CueSheet) WriteEDL(w io.Writer, fps float64) error {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "TITLE: %s - cue markers\nFCM: NON-DROP FRAME\n", c.Title)
+	for i, cue := range c.Cues {
+		start, end := formatTimecode(cue.Start, fps), formatTimecode(cue.End, fps)
+		fmt.Fprintf(&builder, "\n%03d  AX       V     C        %s %s %s %s\n", i+1, start, end, start, end)
+		fmt.Fprintf(&builder, "* FROM CLIP NAME: %s %s\n", cue.ID, cue.Title)
+		fmt.Fprintf(&builder, "* LOC: %s GREEN   %s\n", start, cue.markerLabel())
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// fcpxmlTime formats a number of frames as a rational number of seconds
+type fcpxmlTime struct {
+	// Length of a frame is num/den seconds
+	num, den int64
+}
+
+func newFcpxmlTime(fps float64) fcpxmlTime {
+	nominal := math.Round(fps)
+	switch {
+	case fps == nominal:
+		return fcpxmlTime{num: 1, den: int64(nominal)}
+	case math.Abs(fps-nominal*1000/1001) < 0.01:
+		return fcpxmlTime{num: 1001, den: int64(nominal) * 1000}
+	}
+	return fcpxmlTime{num: 100, den: int64(math.Round(fps * 100))}
+}
+
+func (f fcpxmlTime) format(count int64) string {
+	if count == 0 {
+		return "0s"
+	}
+	return fmt.Sprintf("%d/%ds", count*f.num, f.den)
+}
+
+type fcpxmlMarker struct {
+	Start    string `xml:"start,attr"`
+	Duration string `xml:"duration,attr"`
+	Value    string `xml:"value,attr"`
+	Note     string `xml:"note,attr,omitempty"`
+}
+
+type fcpxmlGap struct {
+	Name     string         `xml:"name,attr"`
+	Offset   string         `xml:"offset,attr"`
+	Start    string         `xml:"start,attr"`
+	Duration string         `xml:"duration,attr"`
+	Markers  []fcpxmlMarker `xml:"marker"`
+}
+
+type fcpxmlSequence struct {
+	Format   string      `xml:"format,attr"`
+	Duration string      `xml:"duration,attr"`
+	TcStart  string      `xml:"tcStart,attr"`
+	TcFormat string      `xml:"tcFormat,attr"`
+	Gaps     []fcpxmlGap `xml:"spine>gap"`
+}
+
+type fcpxmlProject struct {
+	Name     string         `xml:"name,attr"`
+	Sequence fcpxmlSequence `xml:"sequence"`
+}
+
+type fcpxmlFormat struct {
+	ID            string `xml:"id,attr"`
+	FrameDuration string `xml:"frameDuration,attr"`
+	Width         int    `xml:"width,attr"`
+	Height        int    `xml:"height,attr"`
+}
+
+type fcpxmlEvent struct {
+	Name    string        `xml:"name,attr"`
+	Project fcpxmlProject `xml:"project"`
+}
+
+type fcpxmlDocument struct {
+	XMLName xml.Name     `xml:"fcpxml"`
+	Version string       `xml:"version,attr"`
+	Format  fcpxmlFormat `xml:"resources>format"`
+	Event   fcpxmlEvent  `xml:"library>event"`
+}
+
+// WriteFCPXML writes the cues as markers on an empty Final Cut Pro timeline
+func (c *CueSheet) WriteFCPXML(w io.Writer, fps float64) error {
+	timing := newFcpxmlTime(fps)
+	var end time.Duration
+	markers := make([]fcpxmlMarker, len(c.Cues))
+	for i, cue := range c.Cues {
+		end = max(end, cue.End)
+		markers[i] = fcpxmlMarker{
+			Start:    timing.format(frames(cue.Start, fps)),
+			Duration: timing.format(1),
+			Value:    cue.markerLabel(),
+			Note:     cue.Scene,
+		}
+	}
+	duration := timing.format(max(frames(end, fps), 1))
+
+	doc := fcpxmlDocument{
+		Version: "1.9",
+		Format:  fcpxmlFormat{ID: "r1", FrameDuration: timing.format(1), Width: 1440, Height: 1080},
+		Event: fcpxmlEvent{
+			Name: c.Title,
+			Project: fcpxmlProject{
+				Name: c.Title + " - cue markers",
+				Sequence: fcpxmlSequence{
+					Format:   "r1",
+					Duration: duration,
+					TcStart:  "0s",
+					TcFormat: "NDF",
+					Gaps:     []fcpxmlGap{{Name: "Cues", Offset: "0s", Start: "0s", Duration: duration, Markers: markers}},
+				},
+			},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE fcpxml>\n"); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteSRT writes the cues as subtitles that show the playing cue on top of
+// the film
+func (c *CueSheet) WriteSRT(w io.Writer) error {
+	var builder strings.Builder
+	for i, cue := range c.Cues {
+		fmt.Fprintf(&builder, "%d\n%s --> %s\n%s %s\n", i+1, formatSrtTime(cue.Start), formatSrtTime(cue.End), cue.ID, cue.Title)
+		if cue.Tempo > 0 {
+			fmt.Fprintf(&builder, "%d BPM\n", cue.Tempo)
+		}
+		builder.WriteString("\n")
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// MarkersBaseName returns the file name of the markers without extension for
+// the score with the given file name
+func MarkersBaseName(scoreFileName string) string {
+	return strings.TrimSuffix(scoreFileName, ".musicxml") + markersSuffix
+}
+
+// WriteMarkers writes the cues as an edit decision list, Final Cut Pro XML and
+// SubRip subtitles to files starting with the base name. Timecodes use the
+// given frame rate. It returns the names of the files.
+func (c *CueSheet) WriteMarkers(creator musicxml.Creator, baseName string, fps float64) ([]string, error) {
+	if fps <= 0 {
+		fps = defaultMarkerFrameRate
+	}
+	names := []string{baseName + ".edl", baseName + ".fcpxml", baseName + ".srt"}
+	writers := []func(w io.Writer) error{
+		func(w io.Writer) error { return c.WriteEDL(w, fps) },
+		func(w io.Writer) error { return c.WriteFCPXML(w, fps) },
+		c.WriteSRT,
+	}
+	for i, write := range writers {
+		if err := writeFile(creator, names[i], write); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,147 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/xml"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/interchange"
+)
+
+func markerSheet() *CueSheet {
+	return &CueSheet{
+		Title: "The Kid",
+		Cues: []Cue{
+			{ID: "1M1", Start: time.Hour, End: time.Hour + 20*time.Second, Title: "Waltz", Tempo: 90, Scene: "Farewell"},
+			{ID: "1M2", Start: time.Hour + 20*time.Second, End: time.Hour + 30500*time.Millisecond, Title: "Tacet", Scene: "Silence"},
+		},
+	}
+}
+
+func TestFormatTimecode(t *testing.T) {
+	for _, test := range []struct {
+		value time.Duration
+		fps   float64
+		want  string
+	}{
+		{value: time.Hour + 1500*time.Millisecond, fps: 24, want: "01:00:01:12"},
+		{value: 1500 * time.Millisecond, fps: 25, want: "00:00:01:13"},
+		{value: time.Minute, fps: 18, want: "00:01:00:00"},
+	} {
+		if got := formatTimecode(test.value, test.fps); got != test.want {
+			t.Errorf("Wanted %s got %s", test.want, got)
+		}
+	}
+}
+
+func TestMarkerLabel(t *testing.T) {
+	sheet := markerSheet()
+	want := []string{"1M1 Waltz (90 BPM)", "1M2 Tacet"}
+	for i, cue := range sheet.Cues {
+		if got := cue.markerLabel(); got != want[i] {
+			t.Errorf("Wanted %s got %s", want[i], got)
+		}
+	}
+}
+
+func TestWriteEDLReadsBack(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := markerSheet().WriteEDL(&buffer, 24); err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(buffer.String(), "* LOC: 01:00:00:00 GREEN   1M1 Waltz (90 BPM)") {
+		t.Errorf("Wanted a locator for the first cue in\n%s", buffer.String())
+	}
+
+	events, err := interchange.ReadEDL(&buffer, interchange.WithFrameRate(24))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var starts []time.Duration
+	for _, event := range events {
+		starts = append(starts, event.RecordIn)
+	}
+	if want := []time.Duration{time.Hour, time.Hour + 20*time.Second}; !slices.Equal(starts, want) {
+		t.Errorf("Wanted events at %v got %v", want, starts)
+	}
+}
+
+func TestWriteSRTReadsBack(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := markerSheet().WriteSRT(&buffer); err != nil {
+		t.Error(err)
+		return
+	}
+	cues, err := interchange.ReadSubtitles(&buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []interchange.SubtitleCue{
+		{Start: time.Hour, End: time.Hour + 20*time.Second, Text: "1M1 Waltz 90 BPM"},
+		{Start: time.Hour + 20*time.Second, End: time.Hour + 30500*time.Millisecond, Text: "1M2 Tacet"},
+	}
+	if !slices.Equal(cues, want) {
+		t.Errorf("Wanted %+v got %+v", want, cues)
+	}
+}
+
+func TestWriteFCPXML(t *testing.T) {
+	for _, test := range []struct {
+		fps           float64
+		frameDuration string
+		secondStart   string
+	}{
+		{fps: 24, frameDuration: "1/24s", secondStart: "86880/24s"},
+		{fps: 29.97, frameDuration: "1001/30000s", secondStart: "108599491/30000s"},
+	} {
+		var buffer bytes.Buffer
+		if err := markerSheet().WriteFCPXML(&buffer, test.fps); err != nil {
+			t.Error(err)
+			return
+		}
+		if !strings.HasPrefix(buffer.String(), xml.Header+"<!DOCTYPE fcpxml>") {
+			t.Errorf("Wanted XML header and doctype got %s", buffer.String())
+		}
+
+		var doc fcpxmlDocument
+		if err := xml.Unmarshal(buffer.Bytes(), &doc); err != nil {
+			t.Error(err)
+			return
+		}
+		if doc.Format.FrameDuration != test.frameDuration {
+			t.Errorf("Wanted frame duration %s got %s", test.frameDuration, doc.Format.FrameDuration)
+		}
+		markers := doc.Event.Project.Sequence.Gaps[0].Markers
+		if len(markers) != 2 || markers[0].Value != "1M1 Waltz (90 BPM)" || markers[0].Note != "Farewell" {
+			t.Errorf("Wanted a marker per cue got %+v", markers)
+			continue
+		}
+		if markers[1].Start != test.secondStart {
+			t.Errorf("Wanted second marker at %s got %s", test.secondStart, markers[1].Start)
+		}
+	}
+}
+
+func TestWriteMarkers(t *testing.T) {
+	creator := memoryCreator{files: make(map[string]*memoryFile)}
+	names, err := markerSheet().WriteMarkers(&creator, MarkersBaseName("The_Kid.musicxml"), 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []string{"The_Kid_markers.edl", "The_Kid_markers.fcpxml", "The_Kid_markers.srt"}
+	if !slices.Equal(names, want) {
+		t.Errorf("Wanted %v got %v", want, names)
+	}
+	for _, name := range want {
+		if file, ok := creator.files[name]; !ok || file.Len() == 0 {
+			t.Errorf("Wanted content in %s", name)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package compose
 
 import (
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/davidkleiven/silent-score/internal/musicxml"
@@ -25,19 +28,7 @@ func hasRehersalMark(elements []musicxml.MusicDataElement) bool {
 }
 
 func pieceSections(measures []musicxml.Measure) []section {
-	var sections []section
-	start := 0
-	for i, measure := range measures {
-		if hasRehersalMark(measure.MusicDataElements) && i > 0 {
-			sections = append(sections, section{start: start, end: i})
-			start = i
-		}
-	}
-
-	if len(measures) > 0 {
-		sections = append(sections, section{start: start, end: len(measures)})
-	}
-	return sections
+	return sectionsFromBoundaries(rehearsalBoundaries(measures), len(measures))
 }
 
 type sceneSection struct {
@@ -88,3 +79,221 @@ func measuresForScene(measures []musicxml.Measure, section sceneSection) []music
 	}
 	return result
 }
+
+// measureText is a text of a piece together with the index of its measure
+type measureText struct {
+	measure int
+	text    string
+}
+
+// textsOfMeasures collects the text of the measures. The texts are assigned to
+// the measures by the measure numbers reported by MeasureText.
+func textsOfMeasures(measures []musicxml.Measure) []measureText {
+	indexByNumber := make(map[string]int)
+	for i, measure := range measures {
+		if _, ok := indexByNumber[measure.NumberAttr]; !ok {
+			indexByNumber[measure.NumberAttr] = i
+		}
+	}
+
+	var result []measureText
+	for _, item := range musicxml.MeasureText(measures) {
+		if idx, ok := indexByNumber[strconv.Itoa(item.Number)]; ok {
+			result = append(result, measureText{measure: idx, text: item.Text})
+		}
+	}
+	return result
+}
+
+// textsInSections joins the measure texts of each section
+func textsInSections(items []measureText, sections []section) []string {
+	texts := make([]string, len(sections))
+	for _, item := range items {
+		for i, s := range sections {
+			if item.measure >= s.start && item.measure < s.end {
+				texts[i] += " " + item.text
+			}
+		}
+	}
+	return texts
+}
+
+// sectionTexts collects the text of the measures in each section
+func sectionTexts(measures []musicxml.Measure, sections []section) []string {
+	return textsInSections(textsOfMeasures(measures), sections)
+}
+
+// pieceIndex holds what is needed to split a piece into sections and to find
+// the text of each section without reading the piece again. The boundaries
+// are found once, while the sections are chosen when the piece is matched,
+// such that they follow the section settings and the manual sections in use.
+type pieceIndex struct {
+	// The credits of the piece
+	header string
+
+	numMeasures int
+	rehearsal   []boundary
+	detected    []boundary
+	texts       []measureText
+}
+
+func indexPiece(score *musicxml.Scorepartwise) pieceIndex {
+	var credits []string
+	for _, credit := range score.Credit {
+		if credit.Creditwords != nil {
+			credits = append(credits, credit.Creditwords.Value)
+		}
+	}
+
+	measures := PlayableMeasures(score)
+	return pieceIndex{
+		header:      strings.Join(credits, " "),
+		numMeasures: len(measures),
+		rehearsal:   rehearsalBoundaries(measures),
+		detected:    detectBoundaries(measures),
+		texts:       textsOfMeasures(measures),
+	}
+}
+
+// sections returns the sections the piece is played in: the manually defined
+// sections if there are any, and otherwise the sections from the section
+// detection in the options
+func (p *pieceIndex) sections(fingerprint string, options *composeOptions) []section {
+	if sections := manualSections(options.sectionStore, fingerprint, p.numMeasures); len(sections) > 0 {
+		return sections
+	}
+	return chooseSections(p.rehearsal, p.detected, p.numMeasures, options)
+}
+
+// sectionIndex returns the text of each section the piece is played in
+func (p *pieceIndex) sectionIndex(fingerprint string, options *composeOptions) sectionIndex {
+	sections := p.sections(fingerprint, options)
+	if len(sections) == 0 {
+		return sectionIndex{texts: []string{p.header}}
+	}
+	texts := textsInSections(p.texts, sections)
+	for i := range texts {
+		texts[i] = p.header + texts[i]
+	}
+	return sectionIndex{sections: sections, texts: texts}
+}
+
+// sectionIndex holds the text of each section of a piece, such that pieces
+// are ranked by the passage that best matches a scene rather than by the text
+// of the whole piece
+type sectionIndex struct {
+	sections []section
+
+	// The credits of the piece followed by the text of each section. Pieces
+	// without sections have the credits only.
+	texts []string
+}
+
+// section returns the section with the given index, or nil for pieces without
+// sections
+func (s *sectionIndex) section(i int) *section {
+	if i < 0 || i >= len(s.sections) {
+		return nil
+	}
+	return &s.sections[i]
+}
+
+// sectionMatch is a piece ranked by its best matching section
+type sectionMatch struct {
+	piece      int
+	section    int
+	similarity int
+}
+
+// bestSectionMatches returns the n pieces whose best section matches desc
+// best, each with the index of that section
+func bestSectionMatches(desc string, indices []sectionIndex, n int) []sectionMatch {
+	var (
+		texts  []string
+		owners []sectionMatch
+	)
+	for piece, index := range indices {
+		for i, text := range index.texts {
+			texts = append(texts, text)
+			owners = append(owners, sectionMatch{piece: piece, section: i})
+		}
+	}
+
+	ranked := make(map[int]bool)
+	var matches []sectionMatch
+	for _, score := range orderPieces(normalize(desc), texts) {
+		if len(matches) == n {
+			break
+		}
+		match := owners[score.Index]
+		if ranked[match.piece] {
+			continue
+		}
+		ranked[match.piece] = true
+		match.similarity = score.Similarity
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// sectionContaining returns the index of the section containing the measure,
+// or -1 if there is none
+func sectionContaining(sections []section, measure int) int {
+	return slices.IndexFunc(sections, func(s section) bool { return measure >= s.start && measure < s.end })
+}
+
+// sectionsAfter orders the sections such that the piece continues with the
+// section starting at the measure. A piece played to its end starts over.
+func sectionsAfter(sections []section, measure int) []section {
+	i := max(sectionContaining(sections, measure), 0)
+	return append(slices.Clone(sections[i:]), sections[:i]...)
+}
+
+// preferSection puts the score of the section with the given index first,
+// such that a scene starts from the section the piece was chosen for. The
+// section is given at least the similarity the piece was ranked by.
+func preferSection(scores []Score, index, similarity int) []Score {
+	i := slices.IndexFunc(scores, func(s Score) bool { return s.Index == index })
+	if i < 0 || similarity == 0 {
+		return scores
+	}
+	preferred := scores[i]
+	preferred.Similarity = max(preferred.Similarity, similarity)
+	return append([]Score{preferred}, slices.Delete(slices.Clone(scores), i, i+1)...)
+}
+
+// matchSections scores how well the text in each section matches desc. The
+// scores are ordered with the best match first.
+func matchSections(desc string, measures []musicxml.Measure, sections []section) []Score {
+	return orderPieces(normalize(desc), sectionTexts(measures, sections))
+}
+
+// preferMatchingSections orders the sections such that a scene starts with the
+// best matching section. Sections matching the description come first, and the
+// rest follow in the order of the piece starting after the best match.
+func preferMatchingSections(sections []section, scores []Score) []section {
+	if len(scores) == 0 || scores[0].Similarity == 0 {
+		return sections
+	}
+
+	result := make([]section, 0, len(sections))
+	for _, score := range scores {
+		if score.Similarity > 0 {
+			result = append(result, sections[score.Index])
+		}
+	}
+
+	matched := make(map[int]struct{})
+	for _, score := range scores {
+		if score.Similarity > 0 {
+			matched[score.Index] = struct{}{}
+		}
+	}
+	for i := range sections {
+		idx := (scores[0].Index + i) % len(sections)
+		if _, ok := matched[idx]; !ok {
+			result = append(result, sections[idx])
+		}
+	}
+	return result
+}
@@ -2,9 +2,11 @@ package compose
 
 import (
 	"math"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/davidkleiven/silent-score/internal/db"
 	"github.com/davidkleiven/silent-score/internal/musicxml"
 )
 
@@ -123,3 +125,175 @@ func TestMeasuresForSceneEmptyMeasures(t *testing.T) {
 	}
 
 }
+
+func twoCharacterPiece() []musicxml.Measure {
+	measures := []musicxml.Measure{
+		*musicxml.NewMeasure(musicxml.WithRehersalMark("A"), musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Andante tranquillo")))),
+		*musicxml.NewMeasure(),
+		*musicxml.NewMeasure(musicxml.WithRehersalMark("B"), musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Agitato")))),
+		*musicxml.NewMeasure(),
+		*musicxml.NewMeasure(musicxml.WithRehersalMark("C"), musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Misterioso")))),
+		*musicxml.NewMeasure(),
+	}
+	enumerateMeasuresInPlace(measures)
+	return measures
+}
+
+func TestSectionTexts(t *testing.T) {
+	measures := twoCharacterPiece()
+	texts := sectionTexts(measures, pieceSections(measures))
+	want := []string{" A Andante tranquillo", " B Agitato", " C Misterioso"}
+	if !slices.Equal(texts, want) {
+		t.Errorf("Wanted %q got %q", want, texts)
+	}
+}
+
+func TestMatchSections(t *testing.T) {
+	measures := twoCharacterPiece()
+	scores := matchSections("agitato", measures, pieceSections(measures))
+	if scores[0].Index != 1 || scores[0].Similarity == 0 {
+		t.Errorf("Wanted section 1 to match best got %v", scores)
+	}
+}
+
+func TestPreferMatchingSections(t *testing.T) {
+	sections := []section{{start: 0, end: 2}, {start: 2, end: 4}, {start: 4, end: 6}, {start: 6, end: 8}}
+	for _, test := range []struct {
+		desc   string
+		scores []Score
+		want   []section
+	}{
+		{
+			desc:   "no match keeps order",
+			scores: []Score{{Index: 0}, {Index: 1}, {Index: 2}, {Index: 3}},
+			want:   sections,
+		},
+		{
+			desc:   "no scores",
+			scores: nil,
+			want:   sections,
+		},
+		{
+			desc:   "start from best match",
+			scores: []Score{{Index: 2, Similarity: 5}, {Index: 0}, {Index: 1}, {Index: 3}},
+			want:   []section{sections[2], sections[3], sections[0], sections[1]},
+		},
+		{
+			desc:   "matching sections first",
+			scores: []Score{{Index: 2, Similarity: 5}, {Index: 0, Similarity: 2}, {Index: 1}, {Index: 3}},
+			want:   []section{sections[2], sections[0], sections[3], sections[1]},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got := preferMatchingSections(sections, test.scores)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPickMeasuresStartsWithMatchingSection(t *testing.T) {
+	score := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: twoCharacterPiece()}))
+	library := InMemoryLibrary{Scores: []*musicxml.Scorepartwise{score}}
+	records := []db.ProjectContentRecord{{Keywords: "agitato", DurationSec: 6, Tempo: 80}}
+
+	result := pickMeasures(&library, records)
+	if len(result.measures) == 0 {
+		t.Errorf("Wanted measures in the selection")
+		return
+	}
+	if mark := rehearsalText(result.measures[0]); mark != "B" {
+		t.Errorf("Wanted scene to start at rehearsal mark B got %q", mark)
+	}
+}
+
+func TestPieceRankedByBestSection(t *testing.T) {
+	// The text of the whole first piece matches better than the second piece,
+	// but the single section of the second piece matches better than any
+	// section of the first
+	sectioned := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: twoCharacterPiece()}))
+	single := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: []musicxml.Measure{
+		*musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Agitato misterio")))),
+	}}))
+	enumerateMeasuresInPlace(single.Part[0].Measure)
+	library := InMemoryLibrary{Scores: []*musicxml.Scorepartwise{sectioned, single}}
+
+	best := library.BestMatch("agitato misterioso")
+	if best.score != single {
+		t.Errorf("Wanted the piece with the best matching section")
+	}
+
+	best = library.BestMatch("misterioso")
+	if best.score != sectioned || best.section == nil || best.section.start != 4 {
+		t.Errorf("Wanted the section starting at measure 4 got %v", best.section)
+	}
+}
+
+func TestUnmarkedPieceRankedByPlayedSections(t *testing.T) {
+	// Piece without rehearsal marks with a key change in measure 4
+	measures := emptyMeasures(8)
+	measures[0] = *musicxml.NewMeasure(withAttributes(keyAttributes(0)), musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Dolce"))))
+	measures[4] = *musicxml.NewMeasure(withAttributes(keyAttributes(3)))
+	measures[6] = *musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Agitato"))))
+	enumerateMeasuresInPlace(measures)
+	library := InMemoryLibrary{Scores: []*musicxml.Scorepartwise{musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: measures}))}}
+
+	store := db.NewInMemorySectionStore()
+	manual := []db.PieceSection{{Name: "Intro", FirstBar: 1, LastBar: 6}, {Name: "Storm", FirstBar: 7, LastBar: 8}}
+	if err := store.SaveSections(library.Content()[0].Fingerprint, manual); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		desc  string
+		opts  []ComposeOpt
+		start int
+	}{
+		{desc: "rehearsal marks only", opts: []ComposeOpt{WithSectionDetection(SectionsFromRehearsalMarks)}, start: 0},
+		{desc: "detected sections", opts: []ComposeOpt{WithSectionDetection(SectionsDetectedWithoutRehearsalMarks)}, start: 4},
+		{desc: "manual sections before detected", opts: []ComposeOpt{WithSectionDetection(SectionsDetectedWithoutRehearsalMarks), WithManualSections(store)}, start: 6},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			best := firstMatch(library.BestMatches("agitato", 1, PieceFilter{}, test.opts...))
+			if best.section == nil || best.section.start != test.start {
+				t.Errorf("Wanted the section starting at measure %d got %v", test.start, best.section)
+			}
+		})
+	}
+}
+
+func TestPreferSection(t *testing.T) {
+	scores := []Score{{Index: 0, Similarity: 3}, {Index: 1, Similarity: 2}, {Index: 2}}
+	for _, test := range []struct {
+		desc       string
+		index      int
+		similarity int
+		want       []Score
+	}{
+		{
+			desc:       "section moved first",
+			index:      2,
+			similarity: 5,
+			want:       []Score{{Index: 2, Similarity: 5}, {Index: 0, Similarity: 3}, {Index: 1, Similarity: 2}},
+		},
+		{
+			desc:       "no similarity keeps order",
+			index:      2,
+			similarity: 0,
+			want:       scores,
+		},
+		{
+			desc:       "unknown section keeps order",
+			index:      -1,
+			similarity: 5,
+			want:       scores,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := preferSection(scores, test.index, test.similarity); !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package compose
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	medleyDirective     = "medley"
+	defaultMedleyPieces = 3
+)
+
+// sceneKeywords holds the keywords of a scene with the directives removed
+type sceneKeywords struct {
+	text string
+
+	// Number of pieces in a medley. Values below two disable medley mode
+	medley int
+
+	filter PieceFilter
+
+	// Title or scene reference given with "like:". The reference is the
+	// fingerprint of the piece it resolves to
+	like      string
+	reference string
+
+	// The scene has no music
+	tacet bool
+
+	// The final section of the scene is repeated until the next cue
+	vamp bool
+
+	// The scene is an intertitle card with the scene description as text
+	intertitle bool
+}
+
+// parseSceneKeywords extracts directives from the keywords. A scene opts into
+// medley mode with "medley" or "medley:N", where N is the number of pieces.
+// Pieces are restricted by musical features with "mode:minor", "meter:3/4"
+// and "key:d". "like:scene4" or like:"Bon Vivant" asks for pieces similar to
+// the piece of scene 4 or the piece with the given title. "tacet" gives a
+// scene without music and "vamp" repeats the final section until the next cue.
+// "intertitle" marks a title card.
+func parseSceneKeywords(keywords string) sceneKeywords {
+	var (
+		result sceneKeywords
+		words  []string
+	)
+	for _, word := range splitKeywords(keywords) {
+		name, value, hasValue := strings.Cut(strings.ToLower(word), ":")
+		if hasValue && parseFilterDirective(&result.filter, name, value) {
+			continue
+		}
+		if hasValue && name == likeDirective {
+			_, result.like, _ = strings.Cut(word, ":")
+			continue
+		}
+		if !hasValue && name == tacetDirective {
+			result.tacet = true
+			continue
+		}
+		if !hasValue && name == vampDirective {
+			result.vamp = true
+			continue
+		}
+		if !hasValue && name == intertitleDirective {
+			result.intertitle = true
+			continue
+		}
+		if name != medleyDirective {
+			words = append(words, word)
+			continue
+		}
+
+		result.medley = defaultMedleyPieces
+		if hasValue {
+			num, err := strconv.Atoi(value)
+			if err != nil || num < 1 {
+				slog.Warn("Invalid number of pieces in medley", "value", value)
+				continue
+			}
+			result.medley = num
+		}
+	}
+	result.text = strings.Join(words, " ")
+	return result
+}
+
+func pieceFifths(score *musicxml.Scorepartwise) int {
+	if score == nil || len(score.Part) == 0 {
+		return 0
+	}
+	return keySignature(score.Part[0].Measure).Fifths
+}
+
+// keyDistance is the number of steps between two keys on the circle of fifths
+func keyDistance(fifths1, fifths2 int) int {
+	d := (fifths1 - fifths2) % 12
+	if d < 0 {
+		d += 12
+	}
+	return min(d, 12-d)
+}
+
+// chainByKey orders the pieces of a medley. The best match comes first, and
+// each following piece is the one closest in key to the previous piece. Ties
+// are resolved by the order of the matches.
+func chainByKey(matches []matchResult) []matchResult {
+	if len(matches) < 2 {
+		return matches
+	}
+
+	remaining := append([]matchResult{}, matches[1:]...)
+	result := []matchResult{matches[0]}
+	for len(remaining) > 0 {
+		current := pieceFifths(result[len(result)-1].score)
+		best := 0
+		for i, candidate := range remaining {
+			if keyDistance(current, pieceFifths(candidate.score)) < keyDistance(current, pieceFifths(remaining[best].score)) {
+				best = i
+			}
+		}
+		result = append(result, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return result
+}
+
+func segueText(score *musicxml.Scorepartwise) string {
+	if t := title(score); t != "" {
+		return "segue: " + t
+	}
+	return "segue"
+}
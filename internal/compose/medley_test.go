@@ -0,0 +1,168 @@
+package compose
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestParseSceneKeywords(t *testing.T) {
+	for _, test := range []struct {
+		keywords string
+		want     sceneKeywords
+	}{
+		{keywords: "agitato hurry", want: sceneKeywords{text: "agitato hurry"}},
+		{keywords: "medley agitato", want: sceneKeywords{text: "agitato", medley: defaultMedleyPieces}},
+		{keywords: "agitato Medley:5", want: sceneKeywords{text: "agitato", medley: 5}},
+		{keywords: "medley:x agitato", want: sceneKeywords{text: "agitato", medley: defaultMedleyPieces}},
+		{keywords: "medley:0", want: sceneKeywords{medley: defaultMedleyPieces}},
+		{keywords: "", want: sceneKeywords{}},
+		{keywords: "sad mode:Minor meter:3/4", want: sceneKeywords{text: "sad", filter: PieceFilter{Mode: musicxml.ModeMinor, Meter: "3/4"}}},
+		{keywords: "key:Eb medley:2 chase", want: sceneKeywords{text: "chase", medley: 2, filter: PieceFilter{Tonic: "eb"}}},
+		{keywords: "mode:dorian sad", want: sceneKeywords{text: "sad"}},
+		{keywords: "tempo:fast", want: sceneKeywords{text: "tempo:fast"}},
+	} {
+		if got := parseSceneKeywords(test.keywords); got != test.want {
+			t.Errorf("Wanted %+v got %+v for %q", test.want, got, test.keywords)
+		}
+	}
+}
+
+func TestKeyDistance(t *testing.T) {
+	for _, test := range []struct {
+		fifths1, fifths2, want int
+	}{
+		{fifths1: 0, fifths2: 0, want: 0},
+		{fifths1: 0, fifths2: 1, want: 1},
+		{fifths1: -1, fifths2: 2, want: 3},
+		{fifths1: 6, fifths2: -6, want: 0},
+		{fifths1: -7, fifths2: 5, want: 0},
+		{fifths1: 0, fifths2: 6, want: 6},
+	} {
+		if got := keyDistance(test.fifths1, test.fifths2); got != test.want {
+			t.Errorf("Wanted %d got %d for %+v", test.want, got, test)
+		}
+	}
+}
+
+func pieceInKey(composerName string, fifths int, numMeasures int) *musicxml.Scorepartwise {
+	measures := make([]musicxml.Measure, numMeasures)
+	for i := range measures {
+		measures[i] = *musicxml.NewMeasure()
+	}
+	measures[0].MusicDataElements = append(measures[0].MusicDataElements, musicxml.MusicDataElement{
+		Attributes: &musicxml.Attributes{Key: []musicxml.Key{{Traditionalkey: musicxml.Traditionalkey{Fifths: fifths}}}},
+	})
+	enumerateMeasuresInPlace(measures)
+	score := musicxml.NewScorePartwise(musicxml.WithComposer(composerName), musicxml.WithPart(musicxml.Part{Measure: measures}))
+	score.Work = &musicxml.Work{Worktitle: composerName + " chase"}
+	return score
+}
+
+func TestChainByKey(t *testing.T) {
+	matches := []matchResult{
+		{score: pieceInKey("C major", 0, 1)},
+		{score: pieceInKey("F sharp major", 6, 1)},
+		{score: pieceInKey("A major", 3, 1)},
+		{score: pieceInKey("G major", 1, 1)},
+	}
+	var got []string
+	for _, match := range chainByKey(matches) {
+		got = append(got, composer(match.score))
+	}
+	want := []string{"C major", "G major", "A major", "F sharp major"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
+
+func TestBestMatches(t *testing.T) {
+	lib1 := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{pieceInKey("Bach", 0, 1), pieceInKey("Chopin", 0, 1)}}
+	lib2 := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{pieceInKey("Bachmann", 0, 1)}}
+	multi := NewMultiSourceLibrary(lib1, lib2)
+
+	var got []string
+	for _, match := range multi.BestMatches("bach", 2, PieceFilter{}) {
+		got = append(got, composer(match.score))
+	}
+	want := []string{"Bach", "Bachmann"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+
+	if matches := lib1.BestMatches("bach", 10, PieceFilter{}); len(matches) != 2 {
+		t.Errorf("Wanted all pieces when asking for more than the library holds got %d", len(matches))
+	}
+	if matches := (&InMemoryLibrary{}).BestMatches("bach", 2, PieceFilter{}); len(matches) != 0 {
+		t.Errorf("Wanted no matches from empty library got %d", len(matches))
+	}
+}
+
+// tempoMarksAndDuration counts the tempo marks and sums the duration of
+// measures in 4/4 with quarter note beats
+func tempoMarksAndDuration(measures []musicxml.Measure) (int, time.Duration) {
+	num := 0
+	tempo := 0
+	var duration time.Duration
+	for _, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if element.Direction != nil {
+				for _, dirType := range element.Direction.Directiontype {
+					if dirType.Metronome != nil {
+						num++
+						tempo = dirType.Metronome.Perminute.Value
+					}
+				}
+			}
+		}
+		duration += time.Duration(4.0 / float64(tempo) * float64(time.Minute))
+	}
+	return num, duration
+}
+
+func TestMedleyScene(t *testing.T) {
+	library := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{
+		pieceInKey("Bach", 0, 8),
+		pieceInKey("Chopin", 1, 8),
+		pieceInKey("Liszt", 6, 8),
+		pieceInKey("Satie", 0, 8),
+	}}
+	records := []db.ProjectContentRecord{{Keywords: "medley:3 chase", DurationSec: 120, Tempo: 80}}
+
+	result := pickMeasures(library, records)
+	if len(result.pieces) != 3 {
+		t.Errorf("Wanted three pieces in the cue sheet got %d", len(result.pieces))
+		return
+	}
+
+	texts := musicxml.MeasureText(result.measures)
+	numSegues := 0
+	for _, text := range texts {
+		if strings.HasPrefix(text.Text, "segue") {
+			numSegues++
+		}
+	}
+	if numSegues != 2 {
+		t.Errorf("Wanted two segue markings got %v", texts)
+	}
+	num, duration := tempoMarksAndDuration(result.measures)
+	if num != 3 {
+		t.Errorf("Wanted three tempo marks got %d", num)
+	}
+	if duration < 115*time.Second || duration > 125*time.Second {
+		t.Errorf("Wanted the medley to last about two minutes got %s", duration)
+	}
+}
+
+func TestSegueText(t *testing.T) {
+	if text := segueText(musicxml.NewScorePartwise()); text != "segue" {
+		t.Errorf("Wanted 'segue' got %s", text)
+	}
+	if text := segueText(pieceInKey("Bach", 0, 1)); text != "segue: Bach chase" {
+		t.Errorf("Wanted 'segue: Bach chase' got %s", text)
+	}
+}
@@ -1,22 +1,37 @@
 package compose
 
-import "github.com/davidkleiven/silent-score/internal/musicxml"
+import (
+	"context"
+	"slices"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
 
 type MultiSourceLibrary struct {
 	libraries []Library
 }
 
 func (m *MultiSourceLibrary) BestMatch(desc string) matchResult {
-	var best *musicxml.Scorepartwise
-	bestScore := 0
+	var best matchResult
 	for _, lib := range m.libraries {
 		result := lib.BestMatch(desc)
-		if best == nil || result.similarity > bestScore {
-			best = result.score
-			bestScore = result.similarity
+		if best.score == nil || result.similarity > best.similarity {
+			best = result
 		}
 	}
-	return matchResult{score: best, similarity: bestScore}
+	return best
+}
+
+// BestMatches returns the n best matches across all libraries
+func (m *MultiSourceLibrary) BestMatches(desc string, n int, filter PieceFilter, opts ...ComposeOpt) []matchResult {
+	var results []matchResult
+	for _, lib := range m.libraries {
+		results = append(results, lib.BestMatches(desc, n, filter, opts...)...)
+	}
+	slices.SortStableFunc(results, func(r1, r2 matchResult) int {
+		return r2.similarity - r1.similarity
+	})
+	return results[:min(n, len(results))]
 }
 
 func NewMultiSourceLibrary(libraries ...Library) *MultiSourceLibrary {
@@ -32,3 +47,56 @@ func (m *MultiSourceLibrary) Content() []LibraryContent {
 	}
 	return content
 }
+
+func (m *MultiSourceLibrary) Health() []PieceHealth {
+	var health []PieceHealth
+	for _, lib := range m.libraries {
+		health = append(health, lib.Health()...)
+	}
+	return health
+}
+
+func (m *MultiSourceLibrary) Piece(fingerprint string) *musicxml.Scorepartwise {
+	for _, lib := range m.libraries {
+		if score := lib.Piece(fingerprint); score != nil {
+			return score
+		}
+	}
+	return nil
+}
+
+func (m *MultiSourceLibrary) Len() int {
+	total := 0
+	for _, lib := range m.libraries {
+		if loader, ok := lib.(Loader); ok {
+			total += loader.Len()
+		}
+	}
+	return total
+}
+
+// Load loads all underlying libraries that need loading. Progress is reported
+// for the libraries combined.
+func (m *MultiSourceLibrary) Load(ctx context.Context, progress ProgressFunc) error {
+	if progress == nil {
+		progress = func(done, total int) {}
+	}
+	total := m.Len()
+	offset := 0
+	for _, lib := range m.libraries {
+		loader, ok := lib.(Loader)
+		if !ok {
+			continue
+		}
+		libTotal := 0
+		err := loader.Load(ctx, func(done, n int) {
+			libTotal = n
+			progress(offset+done, total)
+		})
+		if err != nil {
+			return err
+		}
+		offset += libTotal
+	}
+	return nil
+}
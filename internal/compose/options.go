@@ -0,0 +1,111 @@
+package compose
+
+import (
+	"fmt"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+// SectionDetection controls how a piece is split into sections
+type SectionDetection int
+
+const (
+	// Only split at rehearsal marks
+	SectionsFromRehearsalMarks SectionDetection = iota
+
+	// Use detected boundaries for pieces without rehearsal marks
+	SectionsDetectedWithoutRehearsalMarks
+
+	// Use detected boundaries in addition to rehearsal marks
+	SectionsFromRehearsalMarksAndDetected
+)
+
+func (s SectionDetection) String() string {
+	var result string
+	switch s {
+	case SectionsFromRehearsalMarks:
+		result = "rehearsal"
+	case SectionsDetectedWithoutRehearsalMarks:
+		result = "auto"
+	case SectionsFromRehearsalMarksAndDetected:
+		result = "combined"
+	}
+	return result
+}
+
+func ParseSectionDetection(value string) (SectionDetection, error) {
+	for _, detection := range []SectionDetection{SectionsFromRehearsalMarks, SectionsDetectedWithoutRehearsalMarks, SectionsFromRehearsalMarksAndDetected} {
+		if detection.String() == value {
+			return detection, nil
+		}
+	}
+	return SectionsFromRehearsalMarks, fmt.Errorf("unknown section detection %q", value)
+}
+
+type composeOptions struct {
+	sectionDetection      SectionDetection
+	minBoundaryConfidence float64
+	sectionStore          db.SectionStore
+	diversityWindow       int
+	tacetForEmptyKeywords bool
+	readingSpeed          float64
+}
+
+type ComposeOpt func(o *composeOptions)
+
+func WithSectionDetection(detection SectionDetection) ComposeOpt {
+	return func(o *composeOptions) {
+		o.sectionDetection = detection
+	}
+}
+
+// WithMinBoundaryConfidence sets the confidence a detected boundary must have
+// to be used as a section boundary
+func WithMinBoundaryConfidence(confidence float64) ComposeOpt {
+	return func(o *composeOptions) {
+		o.minBoundaryConfidence = confidence
+	}
+}
+
+// WithManualSections uses the sections defined by the user for pieces that
+// have them
+func WithManualSections(store db.SectionStore) ComposeOpt {
+	return func(o *composeOptions) {
+		o.sectionStore = store
+	}
+}
+
+// WithDiversityWindow penalizes pieces already used in the given number of
+// preceding scenes without a theme
+func WithDiversityWindow(window int) ComposeOpt {
+	return func(o *composeOptions) {
+		o.diversityWindow = window
+	}
+}
+
+// WithTacetForEmptyKeywords gives scenes with empty keywords no music
+func WithTacetForEmptyKeywords(tacet bool) ComposeOpt {
+	return func(o *composeOptions) {
+		o.tacetForEmptyKeywords = tacet
+	}
+}
+
+// WithReadingSpeed sets the number of words per second used to time
+// intertitles without a duration
+func WithReadingSpeed(wordsPerSecond float64) ComposeOpt {
+	return func(o *composeOptions) {
+		o.readingSpeed = wordsPerSecond
+	}
+}
+
+func newComposeOptions(opts ...ComposeOpt) *composeOptions {
+	o := composeOptions{
+		sectionDetection:      SectionsFromRehearsalMarks,
+		minBoundaryConfidence: 0.4,
+		readingSpeed:          db.DefaultReadingSpeed,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &o
+}
@@ -0,0 +1,84 @@
+package compose
+
+import (
+	"fmt"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+// cueID returns the cue ID in reel M sequence form, for example 2M3 for the
+// third cue of reel 2
+func cueID(reel, sequence uint) string {
+	return fmt.Sprintf("%dM%d", reel, sequence)
+}
+
+// cueCounter numbers the cues within each reel
+type cueCounter map[uint]uint
+
+func (c cueCounter) next(reel uint) string {
+	c[reel]++
+	return cueID(reel, c[reel])
+}
+
+// markCue prints the cue ID as a rehearsal mark at the measure
+func markCue(measure *musicxml.Measure, id string) {
+	musicxml.AddDirectionAtBeginning(measure, musicxml.NewDirection(musicxml.WithRehearsal(id)))
+}
+
+// reelStart is the index of the first measure of a reel
+type reelStart struct {
+	number  uint
+	measure int
+}
+
+// reelSegments returns the first and one past the last measure of each reel
+func reelSegments(reels []reelStart, numMeasures int) [][2]int {
+	segments := make([][2]int, len(reels))
+	for i, reel := range reels {
+		end := numMeasures
+		if i+1 < len(reels) {
+			end = reels[i+1].measure
+		}
+		segments[i] = [2]int{reel.measure, end}
+	}
+	return segments
+}
+
+// reelScores returns one score per reel. The measures are copied and
+// numbered from one in each score. Scenes of a reel that are interrupted by
+// other reels are collected in the score of their reel, in the order of the
+// reels' first scenes.
+func reelScores(project *db.Project, result *selection) []*musicxml.Scorepartwise {
+	var numbers []uint
+	reelMeasures := make(map[uint][]musicxml.Measure)
+	for i, segment := range reelSegments(result.reels, len(result.measures)) {
+		measures := firstN(result.measures[segment[0]:segment[1]], segment[1]-segment[0])
+		if len(measures) == 0 {
+			continue
+		}
+		number := result.reels[i].number
+		if _, seen := reelMeasures[number]; !seen {
+			numbers = append(numbers, number)
+		}
+		reelMeasures[number] = append(reelMeasures[number], measures...)
+	}
+
+	var scores []*musicxml.Scorepartwise
+	for _, number := range numbers {
+		measures := reelMeasures[number]
+		measures[0].MusicDataElements = ensurePageBreak(measures[0].MusicDataElements)
+		setSceneEnd(&measures[len(measures)-1], musicxml.BarStyleLightHeavy)
+		enumerateMeasuresInPlace(measures)
+
+		reelPieces := selection{}
+		for _, piece := range result.pieces {
+			if piece.reel == number {
+				reelPieces.pieces = append(reelPieces.pieces, piece)
+			}
+		}
+		title := fmt.Sprintf("%s - %s", project.Name, project.ReelTitle(number))
+		scores = append(scores, newScore(title, reelPieces.composer(), measures))
+	}
+	return scores
+}
@@ -0,0 +1,129 @@
+package compose
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestCueCounter(t *testing.T) {
+	cues := make(cueCounter)
+	var got []string
+	for _, reel := range []uint{1, 1, 2, 1, 3, 2} {
+		got = append(got, cues.next(reel))
+	}
+	want := []string{"1M1", "1M2", "2M1", "1M3", "3M1", "2M2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
+
+func TestReelSegments(t *testing.T) {
+	got := reelSegments([]reelStart{{number: 1, measure: 0}, {number: 2, measure: 4}, {number: 3, measure: 10}}, 12)
+	want := [][2]int{{0, 4}, {4, 10}, {10, 12}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
+
+func hasNewPage(measure musicxml.Measure) bool {
+	for _, element := range measure.MusicDataElements {
+		if element.Print != nil && element.Print.NewpageAttr == "yes" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestScorePerReel(t *testing.T) {
+	project := db.NewProject(
+		db.WithName("The Kid"),
+		db.WithRecords([]db.ProjectContentRecord{
+			{Keywords: "sad waltz", DurationSec: 20, SceneDesc: "Farewell"},
+			{Keywords: "sad march", DurationSec: 20, SceneDesc: "The parade", Reel: 2},
+			{Keywords: "sad song", DurationSec: 20, SceneDesc: "Reunion", Reel: 2},
+		}),
+	)
+	project.Reels = []db.Reel{{Number: 2, Title: "The chase"}}
+	composition := CreateComposition(sadLibrary(), project)
+	if len(composition.Reels) != 2 {
+		t.Errorf("Wanted two reels got %d", len(composition.Reels))
+		return
+	}
+
+	wantTitles := []string{"The Kid - Reel 1", "The Kid - The chase"}
+	wantCues := [][]string{{"1M1"}, {"2M1", "2M2"}}
+	numMeasures := 0
+	for i, reel := range composition.Reels {
+		measures := reel.Part[0].Measure
+		numMeasures += len(measures)
+		if reel.Work.Worktitle != wantTitles[i] {
+			t.Errorf("Wanted title %s got %s", wantTitles[i], reel.Work.Worktitle)
+		}
+		if got := rehearsalMarks(measures); !slices.Equal(got, wantCues[i]) {
+			t.Errorf("Wanted cues %v got %v", wantCues[i], got)
+		}
+		if measures[0].NumberAttr != "1" || !hasNewPage(measures[0]) {
+			t.Errorf("Wanted reel to start with measure 1 on a new page")
+		}
+		if style := endBarStyle(measures[len(measures)-1]); style != musicxml.BarStyle(musicxml.BarStyleLightHeavy).String() {
+			t.Errorf("Wanted final barline at the end of the reel got %s", style)
+		}
+	}
+
+	combined := composition.Score.Part[0].Measure
+	if numMeasures != len(combined) {
+		t.Errorf("Wanted %d measures in the reels got %d", len(combined), numMeasures)
+	}
+	if first := len(composition.Reels[0].Part[0].Measure); !hasNewPage(combined[first]) {
+		t.Errorf("Wanted the second reel to start on a new page in the combined score")
+	}
+}
+
+func TestInterruptedReelIsOneScore(t *testing.T) {
+	project := db.NewProject(
+		db.WithName("The Kid"),
+		db.WithRecords([]db.ProjectContentRecord{
+			{Keywords: "sad waltz", DurationSec: 20, SceneDesc: "Farewell"},
+			{Keywords: "sad march", DurationSec: 20, SceneDesc: "The parade", Reel: 2},
+			{Keywords: "sad song", DurationSec: 20, SceneDesc: "Reunion", Reel: 1},
+		}),
+	)
+	composition := CreateComposition(sadLibrary(), project)
+	if len(composition.Reels) != 2 {
+		t.Errorf("Wanted two reels got %d", len(composition.Reels))
+		return
+	}
+
+	wantTitles := []string{"The Kid - Reel 1", "The Kid - Reel 2"}
+	wantCues := [][]string{{"1M1", "1M2"}, {"2M1"}}
+	numMeasures := 0
+	for i, reel := range composition.Reels {
+		measures := reel.Part[0].Measure
+		numMeasures += len(measures)
+		if reel.Work.Worktitle != wantTitles[i] {
+			t.Errorf("Wanted title %s got %s", wantTitles[i], reel.Work.Worktitle)
+		}
+		if got := rehearsalMarks(measures); !slices.Equal(got, wantCues[i]) {
+			t.Errorf("Wanted cues %v got %v", wantCues[i], got)
+		}
+		if last := measures[len(measures)-1].NumberAttr; last != fmt.Sprint(len(measures)) {
+			t.Errorf("Wanted measures numbered 1 to %d got last %s", len(measures), last)
+		}
+	}
+	if combined := len(composition.Score.Part[0].Measure); numMeasures != combined {
+		t.Errorf("Wanted %d measures in the reels got %d", combined, numMeasures)
+	}
+}
+
+func endBarStyle(measure musicxml.Measure) string {
+	for _, element := range measure.MusicDataElements {
+		if b := element.Barline; b != nil && b.LocationAttr != "left" && b.Barstyle != nil {
+			return b.Barstyle.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package compose
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	likeDirective = "like"
+
+	// Prefix of a like directive that refers to the piece used in a scene,
+	// for example "like:scene4"
+	sceneReferencePrefix = "scene"
+
+	// Differences in tempo and note density at which pieces are regarded as
+	// completely different
+	maxTempoDifference   = 60.0
+	maxDensityDifference = 2.0
+
+	// The similarity between pieces is scaled to the range of the text similarity
+	pieceSimilarityScale = 100.0
+)
+
+// pieceSimilarity rates how similar two pieces are from 0 to 1. Meter,
+// mode, marked tempo, note density and the overlap of their text are
+// weighted equally.
+func pieceSimilarity(piece1, piece2 *LibraryContent) float64 {
+	var similarity float64
+	if piece1.Analysis.Meter() == piece2.Analysis.Meter() {
+		similarity += 1.0
+	}
+	if piece1.Analysis.Mode == piece2.Analysis.Mode {
+		similarity += 1.0
+	}
+	similarity += 1.0 - normalizeRange(math.Abs(markedTempo(&piece1.Analysis)-markedTempo(&piece2.Analysis)), 0.0, maxTempoDifference)
+	similarity += 1.0 - normalizeRange(math.Abs(piece1.Analysis.NotesPerBeat-piece2.Analysis.NotesPerBeat), 0.0, maxDensityDifference)
+	similarity += jaccard(ngram(normalize(piece1.Text), 3), ngram(normalize(piece2.Text), 3))
+	return similarity / 5.0
+}
+
+func markedTempo(analysis *musicxml.Analysis) float64 {
+	if analysis.Tempo == 0 {
+		return defaultTempo
+	}
+	return float64(analysis.Tempo)
+}
+
+type similarPiece struct {
+	content    LibraryContent
+	similarity float64
+}
+
+// rankSimilar orders the pieces passing the filter by their similarity to the
+// reference. The reference itself is left out.
+func rankSimilar(pieces []LibraryContent, reference *LibraryContent, filter PieceFilter) []similarPiece {
+	var result []similarPiece
+	for _, content := range pieces {
+		if content.Fingerprint == reference.Fingerprint || !filter.Matches(&content.Analysis) {
+			continue
+		}
+		result = append(result, similarPiece{content: content, similarity: pieceSimilarity(reference, &content)})
+	}
+	slices.SortStableFunc(result, func(p1, p2 similarPiece) int {
+		return -cmpFloat(p1.similarity, p2.similarity)
+	})
+	return result
+}
+
+// SimilarPieces returns the n pieces in the library that are most similar to
+// the piece with the given fingerprint
+func SimilarPieces(library Library, fingerprint string, n int) []LibraryContent {
+	pieces := library.Content()
+	index := slices.IndexFunc(pieces, func(content LibraryContent) bool { return content.Fingerprint == fingerprint })
+	if index < 0 {
+		return []LibraryContent{}
+	}
+
+	ranked := rankSimilar(pieces, &pieces[index], PieceFilter{})
+	result := make([]LibraryContent, 0, min(n, len(ranked)))
+	for _, piece := range ranked[:min(n, len(ranked))] {
+		result = append(result, piece.content)
+	}
+	return result
+}
+
+// similarMatches returns the n pieces most similar to the reference piece.
+// Other keywords of the scene are added to the text of the reference.
+func similarMatches(library Library, reference string, keywords sceneKeywords, n int) []matchResult {
+	pieces := library.Content()
+	index := slices.IndexFunc(pieces, func(content LibraryContent) bool { return content.Fingerprint == reference })
+	if index < 0 {
+		return []matchResult{}
+	}
+	referenceContent := pieces[index]
+	referenceContent.Text = strings.Join([]string{referenceContent.Text, keywords.text}, " ")
+
+	var results []matchResult
+	for _, piece := range rankSimilar(pieces, &referenceContent, keywords.filter) {
+		if len(results) >= n {
+			break
+		}
+		results = append(results, matchResult{
+			score:       library.Piece(piece.content.Fingerprint),
+			fingerprint: piece.content.Fingerprint,
+			similarity:  int(pieceSimilarityScale * piece.similarity),
+			analysis:    piece.content.Analysis,
+			source:      piece.content.Source,
+		})
+	}
+	return results
+}
+
+// resolveReference returns the fingerprint of the piece a like directive
+// refers to. "sceneN" refers to the first piece of scene N among the scenes
+// picked so far. Anything else is taken as the title of a piece.
+func resolveReference(library Library, like string, scenePieces []string) string {
+	if sceneText, ok := strings.CutPrefix(strings.ToLower(like), sceneReferencePrefix); ok {
+		if num, err := strconv.Atoi(sceneText); err == nil {
+			if num < 1 || num > len(scenePieces) || scenePieces[num-1] == "" {
+				slog.Warn("Like refers to a scene without a piece", "scene", num)
+				return ""
+			}
+			return scenePieces[num-1]
+		}
+	}
+
+	for _, content := range library.Content() {
+		if strings.EqualFold(content.ScoreTitle, like) {
+			return content.Fingerprint
+		}
+	}
+	match := library.BestMatch(like)
+	if match.score == nil || match.similarity == 0 {
+		slog.Warn("No piece matches like", "value", like)
+		return ""
+	}
+	return match.fingerprint
+}
+
+// splitKeywords splits the keywords on white space. Text within double quotes
+// is kept together and the quotes are removed.
+func splitKeywords(keywords string) []string {
+	var (
+		words   []string
+		current strings.Builder
+		quoted  bool
+	)
+	for _, c := range keywords {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ' ' || c == '\t' || c == '\n'):
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+	return words
+}
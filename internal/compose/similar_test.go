@@ -0,0 +1,141 @@
+package compose
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func TestPieceSimilarity(t *testing.T) {
+	waltz := LibraryContent{Text: "Sad waltz", Analysis: musicxml.Analysis{Mode: musicxml.ModeMinor, Time: musicxml.Timesignature{Beats: 3, Beattype: 4}, Tempo: 90, NotesPerBeat: 1.0}}
+
+	for _, test := range []struct {
+		other LibraryContent
+		want  float64
+		desc  string
+	}{
+		{other: waltz, want: 1.0, desc: "identical"},
+		{
+			other: LibraryContent{Text: "Sad waltz", Analysis: musicxml.Analysis{Mode: musicxml.ModeMajor, Time: musicxml.Timesignature{Beats: 3, Beattype: 4}, Tempo: 90, NotesPerBeat: 1.0}},
+			want:  0.8,
+			desc:  "other mode",
+		},
+		{
+			other: LibraryContent{Text: "Sad waltz", Analysis: musicxml.Analysis{Mode: musicxml.ModeMinor, Time: musicxml.Timesignature{Beats: 3, Beattype: 4}, Tempo: 120, NotesPerBeat: 2.0}},
+			want:  0.8,
+			desc:  "half as similar tempo and density",
+		},
+		{
+			other: LibraryContent{Text: "xyz", Analysis: musicxml.Analysis{Mode: musicxml.ModeMajor, Time: musicxml.Timesignature{Beats: 4, Beattype: 4}, Tempo: 200, NotesPerBeat: 4.0}},
+			want:  0.0,
+			desc:  "nothing in common",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := pieceSimilarity(&waltz, &test.other); math.Abs(got-test.want) > 1e-6 {
+				t.Errorf("Wanted %f got %f", test.want, got)
+			}
+		})
+	}
+}
+
+func similarityLibrary() *InMemoryLibrary {
+	return &InMemoryLibrary{
+		Scores: []*musicxml.Scorepartwise{
+			pieceWithMeter("Bon Vivant", 3, "major"),
+			pieceWithMeter("Storm", 4, "minor"),
+			pieceWithMeter("Bon Voyage", 3, "major"),
+			pieceWithMeter("Vivant waltz", 3, "minor"),
+		},
+	}
+}
+
+func titles(content []LibraryContent) []string {
+	result := make([]string, len(content))
+	for i, c := range content {
+		result[i] = c.ScoreTitle
+	}
+	return result
+}
+
+func TestSimilarPieces(t *testing.T) {
+	library := similarityLibrary()
+	reference := contentFromScore(library.Scores[0], scoreFingerprint(library.Scores[0]), musicxml.Analyze(library.Scores[0]))
+
+	got := titles(SimilarPieces(library, reference.Fingerprint, 2))
+	want := []string{"Bon Voyage", "Vivant waltz"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+
+	if got := SimilarPieces(library, "unknown", 2); len(got) != 0 {
+		t.Errorf("Wanted no pieces for unknown fingerprint got %v", got)
+	}
+}
+
+func TestSplitKeywords(t *testing.T) {
+	for _, test := range []struct {
+		keywords string
+		want     []string
+	}{
+		{keywords: "sad  waltz", want: []string{"sad", "waltz"}},
+		{keywords: `like:"Bon Vivant" sad`, want: []string{"like:Bon Vivant", "sad"}},
+		{keywords: `"open quote`, want: []string{"open quote"}},
+		{keywords: "", want: nil},
+	} {
+		if got := splitKeywords(test.keywords); !slices.Equal(got, test.want) {
+			t.Errorf("Wanted %q got %q for %q", test.want, got, test.keywords)
+		}
+	}
+}
+
+func TestParseLikeDirective(t *testing.T) {
+	got := parseSceneKeywords(`chase like:"Bon Vivant"`)
+	if got.like != "Bon Vivant" || got.text != "chase" {
+		t.Errorf("Wanted like Bon Vivant and text chase got %+v", got)
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	library := similarityLibrary()
+	storm := scoreFingerprint(library.Scores[1])
+	for _, test := range []struct {
+		like string
+		want string
+		desc string
+	}{
+		{like: "scene2", want: storm, desc: "earlier scene"},
+		{like: "Scene3", want: "", desc: "scene not picked yet"},
+		{like: "scene0", want: "", desc: "no scene zero"},
+		{like: "storm", want: storm, desc: "title"},
+		{like: "stor", want: storm, desc: "part of title"},
+		{like: "qq", want: "", desc: "unknown title"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := resolveReference(library, test.like, []string{"", storm}); got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestLikeSceneInComposition(t *testing.T) {
+	records := []db.ProjectContentRecord{
+		{Keywords: "bon vivant", DurationSec: 10},
+		{Keywords: "like:scene1", DurationSec: 10},
+		{Keywords: "like:scene1 mode:minor", DurationSec: 10},
+	}
+	result := pickMeasures(similarityLibrary(), records)
+
+	got := make([]string, len(result.pieces))
+	for i, piece := range result.pieces {
+		got[i] = piece.title
+	}
+	want := []string{"Bon Vivant", "Bon Voyage", "Vivant waltz"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
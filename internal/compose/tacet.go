@@ -0,0 +1,117 @@
+package compose
+
+import (
+	"encoding/xml"
+	"math"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	tacetDirective = "tacet"
+	tacetText      = "Tacet"
+)
+
+// isTacet returns true if the scene should have no music. A scene is tacet
+// when its keywords contain "tacet", or when its keywords are empty and the
+// composer is told to treat such scenes as tacet.
+func isTacet(keywords sceneKeywords, options *composeOptions) bool {
+	if keywords.tacet {
+		return true
+	}
+	empty := keywords.text == "" && keywords.like == "" && keywords.filter == PieceFilter{}
+	return empty && options.tacetForEmptyKeywords
+}
+
+// lastTimeSignature returns the time signature in effect at the end of the measures
+func lastTimeSignature(measures []musicxml.Measure) *musicxml.Timesignature {
+	for i := len(measures) - 1; i >= 0; i-- {
+		if hasTimeSignature(&measures[i]) {
+			return timesignature(measures[i : i+1])
+		}
+	}
+	return timesignature(nil)
+}
+
+func hasTimeSignature(measure *musicxml.Measure) bool {
+	for _, element := range measure.MusicDataElements {
+		if element.Attributes != nil && len(element.Attributes.Time) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lastMetronome returns a copy of the metronome mark in effect at the end of the measures
+func lastMetronome(measures []musicxml.Measure) *musicxml.Metronome {
+	for i := len(measures) - 1; i >= 0; i-- {
+		elements := measures[i].MusicDataElements
+		for j := len(elements) - 1; j >= 0; j-- {
+			if elements[j].Direction == nil {
+				continue
+			}
+			for _, dirType := range elements[j].Direction.Directiontype {
+				if m := dirType.Metronome; m != nil && m.Perminute != nil {
+					return &musicxml.Metronome{Beatunit: m.Beatunit, Perminute: &musicxml.Perminute{Value: m.Perminute.Value}}
+				}
+			}
+		}
+	}
+	return defaultMetronome()
+}
+
+// lastDivisions returns the number of divisions per quarter note in effect at
+// the end of the measures. Zero if the measures do not set it.
+func lastDivisions(measures []musicxml.Measure) float64 {
+	for i := len(measures) - 1; i >= 0; i-- {
+		elements := measures[i].MusicDataElements
+		for j := len(elements) - 1; j >= 0; j-- {
+			if attr := elements[j].Attributes; attr != nil && attr.Divisions > 0 {
+				return attr.Divisions
+			}
+		}
+	}
+	return 0.0
+}
+
+// tacetMeasures returns whole-measure rests lasting for the duration in the
+// meter and tempo at the end of the previous measures. A tempo above zero
+// overrides the previous tempo.
+func tacetMeasures(previous []musicxml.Measure, tempo int, duration time.Duration) []musicxml.Measure {
+	timeSignature := lastTimeSignature(previous)
+	metronome := lastMetronome(previous)
+	if tempo > 0 {
+		metronome.Perminute.Value = tempo
+	}
+
+	divisions := lastDivisions(previous)
+	setDivisions := divisions == 0.0
+	if setDivisions {
+		divisions = 1.0
+	}
+
+	beats := max(beatsPerMeasure(timeSignature, metronome), 1)
+	secondsPerMeasure := 60.0 * float64(beats) / float64(max(metronome.Perminute.Value, 1))
+	numMeasures := max(int(math.Round(duration.Seconds()/secondsPerMeasure)), 1)
+
+	restDuration := divisions * 4.0 * float64(timeSignature.Beats) / float64(max(timeSignature.Beattype, 1))
+	measures := make([]musicxml.Measure, numMeasures)
+	for i := range measures {
+		rest := musicxml.Note{
+			Fullnote: musicxml.Fullnote{Rest: &musicxml.Rest{MeasureAttr: "yes"}},
+			Duration: musicxml.Duration{Duration: restDuration},
+		}
+		measures[i] = *musicxml.NewMeasure()
+		measures[i].MusicDataElements = append(measures[i].MusicDataElements, musicxml.MusicDataElement{XMLName: xml.Name{Local: "note"}, Note: &rest})
+	}
+
+	musicxml.SetTimeSignatureAtBeginning(&measures[0], *timeSignature)
+	if setDivisions {
+		// The attributes are inserted before the rest
+		measures[0].MusicDataElements[0].Attributes.Divisions = divisions
+	}
+	musicxml.SetTempoAtBeginning(&measures[0], metronome)
+	musicxml.AddDirectionAtBeginning(&measures[0], musicxml.NewDirection(musicxml.WithWords(tacetText)))
+	return measures
+}
@@ -0,0 +1,132 @@
+package compose
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func wholeMeasureRests(measures []musicxml.Measure) []float64 {
+	var durations []float64
+	for _, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if note := element.Note; note != nil && note.Rest != nil && note.Rest.MeasureAttr == "yes" {
+				durations = append(durations, note.Duration.Duration)
+			}
+		}
+	}
+	return durations
+}
+
+func TestTacetMeasures(t *testing.T) {
+	previous := emptyMeasures(2)
+	previous[0].MusicDataElements = append(previous[0].MusicDataElements, musicxml.MusicDataElement{
+		Attributes: &musicxml.Attributes{Divisions: 4, Time: []musicxml.Timesignature{{Beats: 3, Beattype: 4}}},
+	})
+	musicxml.SetTempoAtBeginning(&previous[1], &musicxml.Metronome{Perminute: &musicxml.Perminute{Value: 90}, Beatunit: musicxml.Beatunit{Beatunit: "quarter"}})
+
+	for _, test := range []struct {
+		tempo    int
+		duration time.Duration
+		want     []float64
+		desc     string
+	}{
+		// 3 beats at 90 bpm last 2 seconds
+		{duration: 10 * time.Second, want: []float64{12, 12, 12, 12, 12}, desc: "previous meter and tempo"},
+		{tempo: 45, duration: 8 * time.Second, want: []float64{12, 12}, desc: "tempo given for the scene"},
+		{duration: 0, want: []float64{12}, desc: "at least one measure"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			measures := tacetMeasures(previous, test.tempo, test.duration)
+			if got := wholeMeasureRests(measures); !slices.Equal(got, test.want) {
+				t.Errorf("Wanted rests %v got %v", test.want, got)
+			}
+			if timeSig := timesignature(measures); timeSig.Beats != 3 || timeSig.Beattype != 4 {
+				t.Errorf("Wanted 3/4 got %+v", timeSig)
+			}
+			if text := measureTexts(measures[0]); !slices.Contains(text, tacetText) {
+				t.Errorf("Wanted %s in %v", tacetText, text)
+			}
+		})
+	}
+}
+
+func measureTexts(measure musicxml.Measure) []string {
+	var texts []string
+	for _, dirType := range directionTypes(measure) {
+		for _, words := range dirType.Words {
+			texts = append(texts, words.Value)
+		}
+	}
+	return texts
+}
+
+func TestTacetMeasuresWithoutPrevious(t *testing.T) {
+	measures := tacetMeasures(nil, 0, 6*time.Second)
+	if lastDivisions(measures) != 1.0 {
+		t.Errorf("Wanted divisions to be set got %f", lastDivisions(measures))
+	}
+	if got := wholeMeasureRests(measures); len(got) != 2 || got[0] != 4.0 {
+		t.Errorf("Wanted two 4/4 measures of rest got %v", got)
+	}
+}
+
+func TestIsTacet(t *testing.T) {
+	for _, test := range []struct {
+		keywords   string
+		emptyTacet bool
+		want       bool
+	}{
+		{keywords: "tacet", want: true},
+		{keywords: "Tacet prologue", want: true},
+		{keywords: "tacet:3", want: false},
+		{keywords: "", want: false},
+		{keywords: "  ", emptyTacet: true, want: true},
+		{keywords: "mode:minor", emptyTacet: true, want: false},
+		{keywords: "chase", emptyTacet: true, want: false},
+	} {
+		options := newComposeOptions(WithTacetForEmptyKeywords(test.emptyTacet))
+		if got := isTacet(parseSceneKeywords(test.keywords), options); got != test.want {
+			t.Errorf("Wanted %v got %v for %q", test.want, got, test.keywords)
+		}
+	}
+}
+
+func TestTacetSceneInComposition(t *testing.T) {
+	records := []db.ProjectContentRecord{
+		{Keywords: "sad", DurationSec: 10, SceneDesc: "Farewell"},
+		{Keywords: "tacet", DurationSec: 10, SceneDesc: "Spoken prologue"},
+		{Keywords: "sad", DurationSec: 10, SceneDesc: "Reunion"},
+	}
+	result := pickMeasures(sadLibrary(), records)
+
+	if len(result.pieces) != 3 || result.pieces[1].title != tacetText {
+		t.Errorf("Wanted the tacet scene in the cue sheet got %v", result.pieces)
+		return
+	}
+	if text := strings.Join(measureTexts(result.pieces[1].cue[0]), " "); !strings.Contains(text, "Spoken prologue") {
+		t.Errorf("Wanted scene description in the cue got %s", text)
+	}
+
+	numTacet := 0
+	for _, measure := range result.measures {
+		if slices.Contains(measureTexts(measure), tacetText) {
+			numTacet++
+			if !slices.Contains(measureTexts(measure), "Spoken prologue") {
+				t.Errorf("Wanted scene description with the tacet mark got %v", measureTexts(measure))
+			}
+		}
+	}
+	if numTacet != 1 {
+		t.Errorf("Wanted one tacet mark got %d", numTacet)
+	}
+
+	sel := selection{pieces: result.pieces}
+	if composers := sel.composer(); strings.Contains(composers, ", ,") || strings.HasSuffix(composers, ", ") {
+		t.Errorf("Tacet scenes should not add composers got %q", composers)
+	}
+}
@@ -0,0 +1,56 @@
+package compose
+
+import (
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const (
+	vampDirective = "vamp"
+	vampText      = "repeat ad lib."
+	cueTextPrefix = "cue: "
+)
+
+// markVamp wraps the measures in repeat barlines with an open "repeat ad lib."
+// direction, such that they are repeated until the next cue. The measures are
+// still counted once when timing the scene.
+func markVamp(measures []musicxml.Measure) {
+	if len(measures) == 0 {
+		return
+	}
+	forward := musicxml.NewBarline(musicxml.WithRepeat(&musicxml.Repeat{DirectionAttr: "forward"}))
+	musicxml.SetBarlineAtBeginning(&measures[0], forward)
+	musicxml.AddDirectionAtBeginning(&measures[0], musicxml.NewDirection(musicxml.WithWords(vampText)))
+
+	backward := musicxml.NewBarline(
+		musicxml.WithBarStyle(musicxml.BarStyleLightHeavy),
+		musicxml.WithRepeat(&musicxml.Repeat{DirectionAttr: "backward"}),
+	)
+	musicxml.SetBarlineAtEnd(&measures[len(measures)-1], backward)
+}
+
+// vampSection returns the measures of the last section in the scene
+func vampSection(measures []musicxml.Measure, section sceneSection) []musicxml.Measure {
+	if len(section.sections) == 0 {
+		return nil
+	}
+	last := section.sections[len(section.sections)-1]
+	return measures[max(len(measures)-(last.end-last.start), 0):]
+}
+
+// endsWithRepeat returns true if the measure ends with a backward repeat
+func endsWithRepeat(measure *musicxml.Measure) bool {
+	for _, element := range measure.MusicDataElements {
+		if b := element.Barline; b != nil && b.LocationAttr != "left" && b.Repeat != nil && b.Repeat.DirectionAttr == "backward" {
+			return true
+		}
+	}
+	return false
+}
+
+// setSceneEnd sets the barline at the end of a scene unless the scene ends
+// with a vamp
+func setSceneEnd(measure *musicxml.Measure, style musicxml.BarStyle) {
+	if !endsWithRepeat(measure) {
+		musicxml.SetBarlineAtEnd(measure, musicxml.NewBarline(musicxml.WithBarStyle(style)))
+	}
+}
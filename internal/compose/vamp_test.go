@@ -0,0 +1,102 @@
+package compose
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func repeatDirections(measures []musicxml.Measure) map[int]string {
+	result := make(map[int]string)
+	for i, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if b := element.Barline; b != nil && b.Repeat != nil {
+				result[i] = b.Repeat.DirectionAttr
+			}
+		}
+	}
+	return result
+}
+
+func TestMarkVamp(t *testing.T) {
+	measures := emptyMeasures(3)
+	markVamp(measures)
+
+	want := map[int]string{0: "forward", 2: "backward"}
+	if got := repeatDirections(measures); len(got) != len(want) || got[0] != want[0] || got[2] != want[2] {
+		t.Errorf("Wanted repeats %v got %v", want, got)
+	}
+	if !slices.Contains(measureTexts(measures[0]), vampText) {
+		t.Errorf("Wanted %q at the start of the vamp got %v", vampText, measureTexts(measures[0]))
+	}
+	if !endsWithRepeat(&measures[2]) {
+		t.Errorf("Wanted the last measure to end with a repeat")
+	}
+}
+
+func TestMarkVampSingleMeasure(t *testing.T) {
+	measures := emptyMeasures(1)
+	markVamp(measures)
+	markVamp(nil)
+
+	numRepeats := 0
+	for _, element := range measures[0].MusicDataElements {
+		if element.Barline != nil && element.Barline.Repeat != nil {
+			numRepeats++
+		}
+	}
+	if numRepeats != 2 {
+		t.Errorf("Wanted forward and backward repeat in the same measure got %d", numRepeats)
+	}
+}
+
+func TestVampSection(t *testing.T) {
+	measures := emptyMeasures(8)
+	section := sceneSection{sections: []section{{start: 0, end: 3}, {start: 3, end: 8}}}
+	if got := vampSection(measures, section); len(got) != 5 {
+		t.Errorf("Wanted the five measures of the last section got %d", len(got))
+	}
+	if got := vampSection(measures, sceneSection{}); got != nil {
+		t.Errorf("Wanted no measures without sections got %d", len(got))
+	}
+}
+
+func TestVampSceneInComposition(t *testing.T) {
+	score := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: eightBarPiece()}))
+	library := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{score}}
+
+	records := []db.ProjectContentRecord{
+		{Keywords: "waltz vamp", DurationSec: 23, SceneDesc: "Waiting"},
+		{Keywords: "waltz", DurationSec: 23, SceneDesc: "The train arrives"},
+	}
+	withVamp := pickMeasures(library, records)
+
+	records[0].Keywords = "waltz"
+	withoutVamp := pickMeasures(library, records)
+
+	if len(withVamp.measures) != len(withoutVamp.measures) {
+		t.Errorf("Wanted the vamp to be counted once. Got %d measures with vamp and %d without", len(withVamp.measures), len(withoutVamp.measures))
+		return
+	}
+
+	want := map[int]string{3: "forward", 7: "backward"}
+	got := repeatDirections(withVamp.measures)
+	if len(got) != len(want) || got[3] != want[3] || got[7] != want[7] {
+		t.Errorf("Wanted repeats %v got %v", want, got)
+	}
+	if !slices.Contains(measureTexts(withVamp.measures[7]), cueTextPrefix+"The train arrives") {
+		t.Errorf("Wanted cue for the next scene got %v", measureTexts(withVamp.measures[7]))
+	}
+}
+
+func TestVampInLastScene(t *testing.T) {
+	score := musicxml.NewScorePartwise(musicxml.WithPart(musicxml.Part{Measure: eightBarPiece()}))
+	library := &InMemoryLibrary{Scores: []*musicxml.Scorepartwise{score}}
+
+	result := pickMeasures(library, []db.ProjectContentRecord{{Keywords: "vamp", DurationSec: 23}})
+	if last := result.measures[len(result.measures)-1]; !endsWithRepeat(&last) {
+		t.Errorf("Wanted the final barline to keep the repeat")
+	}
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidClock = errors.New("must be seconds or a clock time like 1:30")
+
+// ParseClock parses seconds or a clock time like 1:30 or 01:02:03.5
+func ParseClock(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, ErrInvalidClock
+	}
+
+	var seconds float64
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		last := i == len(parts)-1
+		if err != nil || number < 0 || (!last && number != float64(int(number))) || (i > 0 && number >= 60) {
+			return 0, ErrInvalidClock
+		}
+		seconds = 60*seconds + number
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// FormatClock formats the duration as hours, minutes and seconds with
+// milliseconds when there are any, for example 01:00:10.5
+func FormatClock(d time.Duration) string {
+	ms := d.Round(time.Millisecond).Milliseconds()
+	clock := fmt.Sprintf("%02d:%02d:%02d", ms/3600000, ms/60000%60, ms/1000%60)
+	if fraction := ms % 1000; fraction != 0 {
+		clock += strings.TrimRight(fmt.Sprintf(".%03d", fraction), "0")
+	}
+	return clock
+}
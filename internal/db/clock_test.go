@@ -0,0 +1,64 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  time.Duration
+		err   error
+	}{
+		{value: "45", want: 45 * time.Second},
+		{value: "1:30", want: 90 * time.Second},
+		{value: "01:02:03.5", want: time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{value: "1:60", err: ErrInvalidClock},
+		{value: "1.5:30", err: ErrInvalidClock},
+		{value: "-3", err: ErrInvalidClock},
+		{value: "1:2:3:4", err: ErrInvalidClock},
+	} {
+		got, err := ParseClock(test.value)
+		if err != test.err || got != test.want {
+			t.Errorf("Wanted %s (%v) got %s (%v) for %s", test.want, test.err, got, err, test.value)
+		}
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	for _, test := range []struct {
+		value time.Duration
+		want  string
+	}{
+		{value: 0, want: "00:00:00"},
+		{value: time.Hour + 10*time.Second + 500*time.Millisecond, want: "01:00:10.5"},
+		{value: 90*time.Second + 41*time.Millisecond, want: "00:01:30.041"},
+	} {
+		got := FormatClock(test.value)
+		if got != test.want {
+			t.Errorf("Wanted %s got %s", test.want, got)
+		}
+		if parsed, err := ParseClock(got); err != nil || parsed != test.value {
+			t.Errorf("Wanted %s to parse back to %s got %s (%v)", got, test.value, parsed, err)
+		}
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	for _, test := range []struct {
+		timecode string
+		want     time.Duration
+		ok       bool
+	}{
+		{timecode: "", ok: false},
+		{timecode: "01:00:10", want: time.Hour + 10*time.Second, ok: true},
+		{timecode: "soon", ok: false},
+	} {
+		record := ProjectContentRecord{Timecode: test.timecode}
+		got, ok := record.StartTime()
+		if got != test.want || ok != test.ok {
+			t.Errorf("Wanted %s (%v) got %s (%v) for %q", test.want, test.ok, got, ok, test.timecode)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ProjectCopier is implemented by stores that copy a project in one
+// transaction
+type ProjectCopier interface {
+	Copy(id uint, opts ...ProjectOpts) (*Project, error)
+}
+
+// Copy returns a copy of the project with the same scenes, reels and settings.
+// The scenes keep their themes, pinned pieces and other fields. The options are applied to the copy, which must at least be given a new
+// name before it is stored. Its scenes get their own IDs when stored.
+func (p *Project) Copy(opts ...ProjectOpts) *Project {
+	copied := NewProject(
+		WithName(p.Name),
+		WithDiversityWindow(p.DiversityWindow),
+		WithReadingSpeed(p.ReadingSpeed),
+		AsTemplate(p.Template),
+	)
+	for _, record := range sortedByScene(p.Records) {
+		record.ID, record.ProjectID = 0, 0
+		copied.Records = append(copied.Records, record)
+	}
+	for _, reel := range p.Reels {
+		reel.ProjectID = 0
+		copied.Reels = append(copied.Reels, reel)
+	}
+	copied.ChangeSummary = fmt.Sprintf("Copied from %s", p.Name)
+	for _, opt := range opts {
+		opt(copied)
+	}
+	return copied
+}
+
+// CopyProject stores a copy of the project with the given ID. Stores that are
+// not a ProjectCopier load the project and save the copy.
+func CopyProject(store ProjectStore, id uint, opts ...ProjectOpts) (*Project, error) {
+	var (
+		copied *Project
+		err    error
+	)
+	if copier, ok := store.(ProjectCopier); ok {
+		copied, err = copier.Copy(id, opts...)
+	} else {
+		copied, err = loadAndCopy(store, id, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	copied.ChangeSummary = ""
+	return copied, nil
+}
+
+func loadAndCopy(store ProjectStore, id uint, opts ...ProjectOpts) (*Project, error) {
+	projects, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(projects, func(p Project) bool { return p.Id == id })
+	if i < 0 {
+		return nil, ErrProjectNotFound
+	}
+	copied := projects[i].Copy(opts...)
+	return copied, store.Save(copied)
+}
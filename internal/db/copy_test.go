@@ -0,0 +1,97 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCopyProject(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			records := scenes("Main title", "Chase", "Romance", "Finale")
+			records[1].Theme = 2
+			records[3].Reel = 2
+			records[2].Pinned = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+			original := NewProject(WithName("comedy"), WithRecords(records), WithDiversityWindow(5), WithReadingSpeed(3))
+			original.SyncReels()
+			original.Reels[1].Title = "Second reel"
+			if err := test.store.Save(original); err != nil {
+				t.Fatal(err)
+			}
+
+			template, err := CopyProject(test.store, original.Id, WithName("two-reeler"), AsTemplate(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			copied, err := CopyProject(test.store, template.Id, WithName("new comedy"), AsTemplate(false))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			projects, err := test.store.Load()
+			if err != nil || len(projects) != 3 {
+				t.Fatalf("Wanted three projects got %d (%v)", len(projects), err)
+			}
+			if !projects[1].Template || projects[2].Template || projects[0].Template {
+				t.Errorf("Wanted only the second project to be a template")
+			}
+
+			stored := projects[2]
+			if stored.Id != copied.Id || stored.Name != "new comedy" || stored.DiversityWindow != 5 || stored.ReadingSpeed != 3 {
+				t.Errorf("Wanted the settings to be copied got %+v", stored)
+			}
+			if len(stored.Records) != 4 || stored.Records[1].Theme != 2 || stored.Records[3].SceneDesc != "Finale" || stored.Records[2].Pinned != records[2].Pinned {
+				t.Errorf("Wanted the scenes to be copied got %+v", stored.Records)
+			}
+			for i, record := range stored.Records {
+				if record.ID == original.Records[i].ID || record.ProjectID != copied.Id {
+					t.Errorf("Wanted scene %d to be a new record got %+v", i, record)
+				}
+			}
+			if stored.ReelTitle(2) != "Second reel" {
+				t.Errorf("Wanted the reel titles to be copied got %v", stored.Reels)
+			}
+			if len(projects[0].Records) != 4 {
+				t.Errorf("Wanted the original to keep its scenes got %d", len(projects[0].Records))
+			}
+		})
+	}
+}
+
+func TestCopyKeepsPinnedPieces(t *testing.T) {
+	records := scenes("Main title", "Chase")
+	records[0].ID, records[0].Pinned = 4, "abc"
+	original := NewProject(WithName("comedy"), WithRecords(records))
+
+	copied := original.Copy(WithName("new comedy"))
+	if copied.Records[0].Pinned != "abc" || copied.Records[0].ID != 0 || copied.Records[1].Pinned != "" {
+		t.Errorf("Wanted the pinned piece copied to a new scene got %+v", copied.Records)
+	}
+}
+
+func TestCopyMissingProject(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := CopyProject(test.store, 42, WithName("copy")); !errors.Is(err, ErrProjectNotFound) {
+				t.Errorf("Wanted ErrProjectNotFound got %v", err)
+			}
+		})
+	}
+}
+
+func TestFailedCopyStoresNothing(t *testing.T) {
+	store := namedGormStore(t)
+	original := NewProject(WithName("comedy"), WithRecords(scenes("a", "b")))
+	if err := store.Save(original); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyProject(store, original.Id); err == nil {
+		t.Errorf("Wanted an error when the copy has the name of the original")
+	}
+
+	var count int64
+	store.Database.Model(&ProjectContentRecord{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Wanted only the scenes of the original got %d", count)
+	}
+}
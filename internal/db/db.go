@@ -1,25 +1,40 @@
 package db
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/davidkleiven/silent-score/internal/utils"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
+// GormConnection opens the SQLite database. Changes go to a write-ahead log,
+// such that other instances can read while one of them writes. Transactions
+// take the write lock when they begin, and wait for up to busyTimeout when
+// another instance holds it.
 func GormConnection(name string) (*gorm.DB, error) {
-	return gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	separator := "?"
+	if strings.Contains(name, "?") {
+		separator = "&"
+	}
+	dsn := fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate&_foreign_keys=on", name, separator, busyTimeout.Milliseconds())
+	return gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
 }
 
-func AutoMigrate(con *gorm.DB) error {
-	return utils.ReturnFirstError(
-		func() error { return con.Exec("PRAGMA foreign_keys = ON", nil).Error },
-		func() error { return con.AutoMigrate(&Project{}, &ProjectContentRecord{}, &ConfiguredLibraries{}) },
-	)
-}
+// Time to wait for other instances to finish writing to the database
+const busyTimeout = 5 * time.Second
+
+var (
+	ErrConflict        = errors.New("the project was changed elsewhere since it was loaded")
+	ErrProjectNotFound = errors.New("project not found")
+)
 
 type Project struct {
 	Id        uint   `gorm:"primarykey,autoincrement,unique"`
@@ -27,6 +42,28 @@ type Project struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Records   []ProjectContentRecord `gorm:"constraint:OnDelete:CASCADE"`
+	Reels     []Reel                 `gorm:"constraint:OnDelete:CASCADE"`
+
+	// Number of preceding scenes without a theme in which a piece is
+	// penalized when it has already been used. Zero disables the penalty.
+	DiversityWindow int
+
+	// Words per second the audience reads intertitles at. Used to time
+	// intertitles without a duration.
+	ReadingSpeed float64
+
+	// Number of times the project has been saved. A save is refused when the
+	// stored project has another version, since it was then changed by someone
+	// else after it was loaded.
+	Version uint `gorm:"default:0"`
+
+	// Templates are copied to start new projects with the same scenes and
+	// settings
+	Template bool `gorm:"default:false"`
+
+	// Summary of the snapshot stored by the next save. When empty, the
+	// changes to the records are summarized.
+	ChangeSummary string `gorm:"-"`
 }
 
 // Satisfy bubble.Item interface
@@ -34,6 +71,11 @@ func (p *Project) FilterValue() string {
 	return p.Name
 }
 
+const (
+	DefaultDiversityWindow = 3
+	DefaultReadingSpeed    = 2.0
+)
+
 type ProjectOpts func(p *Project)
 
 func WithName(name string) ProjectOpts {
@@ -48,10 +90,30 @@ func WithRecords(records []ProjectContentRecord) ProjectOpts {
 	}
 }
 
+func WithDiversityWindow(window int) ProjectOpts {
+	return func(p *Project) {
+		p.DiversityWindow = window
+	}
+}
+
+func WithReadingSpeed(wordsPerSecond float64) ProjectOpts {
+	return func(p *Project) {
+		p.ReadingSpeed = wordsPerSecond
+	}
+}
+
+func AsTemplate(template bool) ProjectOpts {
+	return func(p *Project) {
+		p.Template = template
+	}
+}
+
 func NewProject(opts ...ProjectOpts) *Project {
 	p := Project{
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+		DiversityWindow: DefaultDiversityWindow,
+		ReadingSpeed:    DefaultReadingSpeed,
 	}
 
 	for _, opt := range opts {
@@ -61,13 +123,87 @@ func NewProject(opts ...ProjectOpts) *Project {
 }
 
 type ProjectContentRecord struct {
-	ProjectID   uint
-	Scene       uint
+	// Identifies the scene across saves, such that it can be referred to
+	// when it is moved or the scenes around it change
+	ID uint `gorm:"primarykey,autoincrement"`
+
+	ProjectID uint `gorm:"index:idx_record_position"`
+
+	// Position of the scene in the project, counted from zero
+	Scene uint `gorm:"index:idx_record_position"`
+
 	SceneDesc   string `gorm:"default:''"`
 	DurationSec int    `gorm:"default:0"`
 	Keywords    string `gorm:"default:''"`
 	Tempo       uint   `gorm:"default:0"`
 	Theme       uint   `gorm:"default:0"`
+
+	// How dramatic the scene is from 1 (calm) to 5 (very dramatic). Zero means not set
+	Intensity uint `gorm:"default:0"`
+
+	// Number of the reel the scene belongs to. Zero means the first reel
+	Reel uint `gorm:"default:0"`
+
+	// Where the scene starts on the timeline of the film, as a clock time like
+	// 01:00:10.5. Empty when the start follows from the scenes before it.
+	Timecode string `gorm:"default:''"`
+
+	// Fingerprint of the piece always played in the scene. Empty when the
+	// piece is matched by the keywords.
+	Pinned string `gorm:"default:''"`
+}
+
+// ReelNumber returns the number of the reel the scene belongs to
+func (r *ProjectContentRecord) ReelNumber() uint {
+	return max(r.Reel, 1)
+}
+
+// StartTime returns the time the scene starts at on the timeline of the film.
+// It returns false when the scene has no valid timecode.
+func (r *ProjectContentRecord) StartTime() (time.Duration, bool) {
+	if r.Timecode == "" {
+		return 0, false
+	}
+	start, err := ParseClock(r.Timecode)
+	return start, err == nil
+}
+
+// Reel is a reel of the film. The scenes of a reel refer to it by its number,
+// which is unique within the project.
+type Reel struct {
+	ProjectID uint   `gorm:"primarykey;autoIncrement:false"`
+	Number    uint   `gorm:"primarykey;autoIncrement:false"`
+	Title     string `gorm:"default:''"`
+}
+
+// ReelTitle returns the title of the reel with the given number. Reels
+// without a title are named by their number.
+func (p *Project) ReelTitle(number uint) string {
+	for _, reel := range p.Reels {
+		if reel.Number == number && reel.Title != "" {
+			return reel.Title
+		}
+	}
+	return fmt.Sprintf("Reel %d", number)
+}
+
+// SyncReels adds a reel for every reel used by the scenes and removes reels
+// without scenes. The titles of existing reels are kept.
+func (p *Project) SyncReels() {
+	var reels []Reel
+	for _, record := range p.Records {
+		number := record.ReelNumber()
+		if slices.ContainsFunc(reels, func(r Reel) bool { return r.Number == number }) {
+			continue
+		}
+		reel := Reel{ProjectID: p.Id, Number: number}
+		if i := slices.IndexFunc(p.Reels, func(r Reel) bool { return r.Number == number }); i >= 0 {
+			reel.Title = p.Reels[i].Title
+		}
+		reels = append(reels, reel)
+	}
+	slices.SortFunc(reels, func(r1, r2 Reel) int { return cmp.Compare(r1.Number, r2.Number) })
+	p.Reels = reels
 }
 
 type ConfiguredLibraries struct {
@@ -80,37 +216,203 @@ func (c *ConfiguredLibraries) FilterValue() string {
 	return c.Path
 }
 
+// Number of rows inserted per statement. Keeps large projects, such as those
+// imported from cut lists, below the limit on SQL variables in a statement.
+const createBatchSize = 500
+
 type GormStore struct {
 	Database *gorm.DB
 }
 
 func (g *GormStore) Save(p *Project) error {
+	version := p.Version
+	err := g.Database.Transaction(func(tx *gorm.DB) error {
+		return saveProject(tx, p)
+	})
+	if err != nil {
+		p.Version = version
+	}
+	return err
+}
+
+func saveProject(tx *gorm.DB, p *Project) error {
+	if err := checkVersion(tx, p); err != nil {
+		return err
+	}
+	p.Version++
 	p.UpdatedAt = time.Now()
-	return g.Database.Transaction(func(tx *gorm.DB) error {
-		var deleteRecords []ProjectContentRecord
-		if err := tx.Delete(&deleteRecords, "project_id = ?", p.Id).Error; err != nil {
+
+	var deleteReels []Reel
+	if err := tx.Delete(&deleteReels, "project_id = ?", p.Id).Error; err != nil {
+		return err
+	}
+
+	err := tx.Omit("Records").Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "updated_at", "diversity_window", "reading_speed", "version", "template"}),
+		},
+	).Create(p).Error
+	if err != nil {
+		return err
+	}
+	if err := saveRecords(tx, p); err != nil {
+		return err
+	}
+	return saveSnapshot(tx, p)
+}
+
+// Copy stores a copy of the project with the given ID in one transaction, such
+// that the copy is either stored completely or not at all
+func (g *GormStore) Copy(id uint, opts ...ProjectOpts) (*Project, error) {
+	var project *Project
+	err := g.Database.Transaction(func(tx *gorm.DB) error {
+		var original Project
+		err := tx.Preload("Records", func(db *gorm.DB) *gorm.DB {
+			return db.Order("scene, id")
+		}).Preload("Reels", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number")
+		}).First(&original, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProjectNotFound
+		}
+		if err != nil {
 			return err
 		}
+		project = original.Copy(opts...)
+		return saveProject(tx, project)
+	})
+	return project, err
+}
+
+// checkVersion returns ErrConflict when the project is stored with another
+// version than the one it has
+func checkVersion(tx *gorm.DB, p *Project) error {
+	var versions []uint
+	if err := tx.Model(&Project{}).Where("id = ?", p.Id).Pluck("version", &versions).Error; err != nil {
+		return err
+	}
+	if len(versions) > 0 && versions[0] != p.Version {
+		return ErrConflict
+	}
+	return nil
+}
+
+// saveRecords inserts, updates and deletes the rows of the project such that
+// they match its records. Unchanged rows are left as they are. New records
+// are given an ID, which is set on the records of the project.
+func saveRecords(tx *gorm.DB, p *Project) error {
+	var rows []ProjectContentRecord
+	if err := tx.Where("project_id = ?", p.Id).Find(&rows).Error; err != nil {
+		return err
+	}
+	stored := make(map[uint]ProjectContentRecord, len(rows))
+	for _, row := range rows {
+		stored[row.ID] = row
+	}
 
-		return tx.Clauses(
-			clause.OnConflict{
-				Columns:   []clause.Column{{Name: "id"}},
-				DoUpdates: clause.AssignmentColumns([]string{"name", "updated_at"}),
-			},
-		).Create(p).Error
+	err := claimRecordIDs(p, func(id uint) bool { _, ok := stored[id]; return ok }, func(ids []uint) ([]uint, error) {
+		var taken []uint
+		for chunk := range slices.Chunk(ids, createBatchSize) {
+			var found []uint
+			if err := tx.Model(&ProjectContentRecord{}).Where("id IN ?", chunk).Pluck("id", &found).Error; err != nil {
+				return nil, err
+			}
+			taken = append(taken, found...)
+		}
+		return taken, nil
 	})
+	if err != nil {
+		return err
+	}
+
+	// Records with an ID are inserted separately, as a batch can not mix
+	// rows with and without an ID
+	var withID, withoutID []int
+	for i, record := range p.Records {
+		row, ok := stored[record.ID]
+		switch {
+		case ok:
+			delete(stored, record.ID)
+			if row != record {
+				if err := tx.Save(&record).Error; err != nil {
+					return err
+				}
+			}
+		case record.ID != 0:
+			withID = append(withID, i)
+		default:
+			withoutID = append(withoutID, i)
+		}
+	}
 
+	removed := slices.Collect(maps.Keys(stored))
+	for chunk := range slices.Chunk(removed, createBatchSize) {
+		if err := tx.Delete(&ProjectContentRecord{}, chunk).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, indices := range [][]int{withID, withoutID} {
+		if len(indices) == 0 {
+			continue
+		}
+		created := make([]ProjectContentRecord, len(indices))
+		for i, index := range indices {
+			created[i] = p.Records[index]
+		}
+		if err := tx.Session(&gorm.Session{CreateBatchSize: createBatchSize}).Create(&created).Error; err != nil {
+			return err
+		}
+		for i, index := range indices {
+			p.Records[index].ID = created[i].ID
+		}
+	}
+	return nil
+}
+
+// saveSnapshot stores a snapshot of the records when they differ from the
+// latest snapshot of the project
+func saveSnapshot(tx *gorm.DB, p *Project) error {
+	var latest []Snapshot
+	if err := tx.Where("project_id = ?", p.Id).Order("id desc").Limit(1).Find(&latest).Error; err != nil {
+		return err
+	}
+	var previous *Snapshot
+	if len(latest) > 0 {
+		previous = &latest[0]
+	}
+
+	snapshot, changed, err := nextSnapshot(p, previous)
+	if err != nil || !changed {
+		return err
+	}
+	return tx.Create(&snapshot).Error
 }
 
 func (g *GormStore) Delete(id uint) error {
-	var project Project
-	tx := g.Database.Delete(&project, id)
-	return tx.Error
+	return g.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", id).Delete(&Snapshot{}).Error; err != nil {
+			return err
+		}
+		var project Project
+		return tx.Delete(&project, id).Error
+	})
+}
+
+func (g *GormStore) History(projectID uint) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	tx := g.Database.Where("project_id = ?", projectID).Order("id desc").Find(&snapshots)
+	return snapshots, tx.Error
 }
 
 func (g *GormStore) Load() ([]Project, error) {
 	var projects []Project
-	tx := g.Database.Model(&Project{}).Preload("Records").Find(&projects)
+	tx := g.Database.Model(&Project{}).Preload("Records", func(db *gorm.DB) *gorm.DB {
+		return db.Order("scene, id")
+	}).Preload("Reels", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number")
+	}).Order("id").Find(&projects)
 	return projects, tx.Error
 }
 
@@ -138,3 +440,22 @@ func (g *GormStore) ListLibraries() ([]ConfiguredLibraries, error) {
 	tx := g.Database.Find(&libs)
 	return libs, tx.Error
 }
+
+func (g *GormStore) SaveSections(fingerprint string, sections []PieceSection) error {
+	return g.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("fingerprint = ?", fingerprint).Delete(&PieceSection{}).Error; err != nil {
+			return err
+		}
+		if len(sections) == 0 {
+			return nil
+		}
+		items := prepareSections(fingerprint, sections)
+		return tx.Create(&items).Error
+	})
+}
+
+func (g *GormStore) LoadSections(fingerprint string) ([]PieceSection, error) {
+	var sections []PieceSection
+	tx := g.Database.Where("fingerprint = ?", fingerprint).Order("position").Find(&sections)
+	return sections, tx.Error
+}
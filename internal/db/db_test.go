@@ -1,25 +1,25 @@
 package db
 
 import (
-	"os"
+	"errors"
+	"path/filepath"
 	"slices"
 	"testing"
 )
 
-func namedGormStore(name string) *GormStore {
-	db, err := GormConnection(name)
+func namedGormStore(t *testing.T) *GormStore {
+	db, err := GormConnection(filepath.Join(t.TempDir(), "test.db"))
 	if err != nil {
 		panic(err)
 	}
-	if err := AutoMigrate(db); err != nil {
+	if _, err := Migrate(db); err != nil {
 		panic(err)
 	}
 	return &GormStore{Database: db}
 }
 
 func TestCreateProject(t *testing.T) {
-	tests := storeTests(t.Name())
-	defer os.Remove(t.Name())
+	tests := storeTests(t)
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
@@ -57,8 +57,7 @@ func TestCreateProject(t *testing.T) {
 }
 
 func TestUpdateExistingProject(t *testing.T) {
-	store := namedGormStore(t.Name())
-	defer os.Remove(t.Name())
+	store := namedGormStore(t)
 
 	project := NewProject(WithName("my-project"))
 	store.Save(project)
@@ -67,6 +66,7 @@ func TestUpdateExistingProject(t *testing.T) {
 		Id:        1,
 		Name:      "my-project2",
 		CreatedAt: project.CreatedAt,
+		Version:   project.Version,
 	}
 	store.Save(&otherProject)
 
@@ -85,8 +85,7 @@ func TestUpdateExistingProject(t *testing.T) {
 }
 
 func TestDeleteProject(t *testing.T) {
-	tests := storeTests(t.Name())
-	defer os.Remove(t.Name())
+	tests := storeTests(t)
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			project := NewProject(WithName("my-project"))
@@ -116,8 +115,7 @@ func TestDeleteProject(t *testing.T) {
 }
 
 func TestErrorOnDuplicateName(t *testing.T) {
-	tests := storeTests(t.Name())
-	defer os.Remove(t.Name())
+	tests := storeTests(t)
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			project := NewProject(WithName("my-project"))
@@ -135,8 +133,7 @@ func TestErrorOnDuplicateName(t *testing.T) {
 }
 
 func TestProjectWithRecordsRoundTrip(t *testing.T) {
-	tests := storeTests(t.Name())
-	defer os.Remove(t.Name())
+	tests := storeTests(t)
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 
@@ -172,8 +169,7 @@ func TestProjectWithRecordsRoundTrip(t *testing.T) {
 }
 
 func TestUpdateRecords(t *testing.T) {
-	tests := storeTests(t.Name())
-	defer os.Remove(t.Name())
+	tests := storeTests(t)
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			records := []ProjectContentRecord{
@@ -237,8 +233,7 @@ func TestGormCascadeDelete(t *testing.T) {
 		},
 	}
 	p := NewProject(WithName("my-project"), WithRecords(records))
-	store := namedGormStore(t.Name())
-	defer os.Remove(t.Name())
+	store := namedGormStore(t)
 	if err := store.Save(p); err != nil {
 		t.Error(err)
 	}
@@ -270,16 +265,50 @@ type storeTest struct {
 	desc  string
 }
 
-func storeTests(dbName string) []storeTest {
+func storeTests(t *testing.T) []storeTest {
 	return []storeTest{
 		{
-			store: namedGormStore(dbName),
+			store: namedGormStore(t),
 			desc:  "gorm store",
 		},
 		{
 			store: NewInMemoryProjectStore(),
 			desc:  "in memory store",
 		},
+		{
+			store: NewFileStore(t.TempDir(), FormatYAML),
+			desc:  "yaml file store",
+		},
+		{
+			store: NewFileStore(t.TempDir(), FormatJSON),
+			desc:  "json file store",
+		},
+	}
+}
+
+func TestPinnedPieceRoundTrip(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			records := scenes("Opening", "Chase")
+			records[1].Pinned = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+			project := NewProject(WithName("my-project"), WithRecords(records))
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := test.store.Load()
+			if err != nil || len(projects) != 1 || len(projects[0].Records) != 2 {
+				t.Errorf("Wanted the project with two scenes got %+v (%v)", projects, err)
+				return
+			}
+			if got := projects[0].Records[1].Pinned; got != records[1].Pinned {
+				t.Errorf("Wanted pinned piece %s got %q", records[1].Pinned, got)
+			}
+			if got := projects[0].Records[0].Pinned; got != "" {
+				t.Errorf("Wanted no pinned piece got %q", got)
+			}
+		})
 	}
 }
 
@@ -288,10 +317,10 @@ type configuredLibraryTest struct {
 	desc  string
 }
 
-func configuredLibraryTests(dbName string) []configuredLibraryTest {
+func configuredLibraryTests(t *testing.T) []configuredLibraryTest {
 	return []configuredLibraryTest{
 		{
-			store: namedGormStore(dbName),
+			store: namedGormStore(t),
 			desc:  "gorm store",
 		},
 		{
@@ -302,9 +331,8 @@ func configuredLibraryTests(dbName string) []configuredLibraryTest {
 }
 
 func TestConfiguredLibraries(t *testing.T) {
-	defer os.Remove(t.Name())
 
-	for _, test := range configuredLibraryTests(t.Name()) {
+	for _, test := range configuredLibraryTests(t) {
 		t.Run(test.desc, func(t *testing.T) {
 
 			if err := test.store.AddLibrary("/path/to/library1"); err != nil {
@@ -343,9 +371,8 @@ func TestConfiguredLibraries(t *testing.T) {
 }
 
 func TestConfiguredLibrariesDuplicate(t *testing.T) {
-	defer os.Remove(t.Name())
 
-	for _, test := range configuredLibraryTests(t.Name()) {
+	for _, test := range configuredLibraryTests(t) {
 		t.Run(test.desc, func(t *testing.T) {
 			if err := test.store.AddLibrary("/path/to/library1"); err != nil {
 				t.Error(err)
@@ -369,3 +396,505 @@ func TestConfiguredLibrariesDuplicate(t *testing.T) {
 		})
 	}
 }
+
+type sectionStoreTest struct {
+	store SectionStore
+	desc  string
+}
+
+func sectionStoreTests(t *testing.T) []sectionStoreTest {
+	return []sectionStoreTest{
+		{
+			store: namedGormStore(t),
+			desc:  "gorm store",
+		},
+		{
+			store: NewInMemorySectionStore(),
+			desc:  "in memory store",
+		},
+	}
+}
+
+func TestSectionsRoundTrip(t *testing.T) {
+
+	for _, test := range sectionStoreTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			sections := []PieceSection{
+				{Name: "Intro", FirstBar: 1, LastBar: 16},
+				{Name: "Trio", FirstBar: 49, LastBar: 64, Disabled: true},
+				{Name: "Middle", FirstBar: 17, LastBar: 32},
+			}
+			if err := test.store.SaveSections("abc", sections); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := test.store.SaveSections("other", sections[:1]); err != nil {
+				t.Error(err)
+				return
+			}
+
+			loaded, err := test.store.LoadSections("abc")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(loaded) != len(sections) {
+				t.Errorf("Wanted %d sections got %d", len(sections), len(loaded))
+				return
+			}
+			for i, section := range loaded {
+				want := sections[i]
+				if section.Name != want.Name || section.FirstBar != want.FirstBar || section.LastBar != want.LastBar || section.Disabled != want.Disabled {
+					t.Errorf("Wanted %+v got %+v", want, section)
+				}
+				if section.Fingerprint != "abc" || section.Position != i {
+					t.Errorf("Wanted fingerprint abc and position %d got %s and %d", i, section.Fingerprint, section.Position)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveSectionsReplacesExisting(t *testing.T) {
+
+	for _, test := range sectionStoreTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			sections := []PieceSection{{Name: "A", FirstBar: 1, LastBar: 8}, {Name: "B", FirstBar: 9, LastBar: 16}}
+			if err := test.store.SaveSections("abc", sections); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := test.store.SaveSections("abc", sections[1:]); err != nil {
+				t.Error(err)
+				return
+			}
+			loaded, err := test.store.LoadSections("abc")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(loaded) != 1 || loaded[0].Name != "B" {
+				t.Errorf("Wanted only section B got %+v", loaded)
+				return
+			}
+
+			if err := test.store.SaveSections("abc", nil); err != nil {
+				t.Error(err)
+				return
+			}
+			loaded, err = test.store.LoadSections("abc")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(loaded) != 0 {
+				t.Errorf("Wanted no sections got %+v", loaded)
+			}
+		})
+	}
+}
+
+func TestDiversityWindowRoundTrip(t *testing.T) {
+
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(WithName("my-project"))
+			if project.DiversityWindow != DefaultDiversityWindow {
+				t.Errorf("Wanted default diversity window %d got %d", DefaultDiversityWindow, project.DiversityWindow)
+			}
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			project.DiversityWindow = 0
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := test.store.Load()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(projects) != 1 || projects[0].DiversityWindow != 0 {
+				t.Errorf("Wanted one project with diversity window 0 got %+v", projects)
+			}
+		})
+	}
+}
+
+func TestReadingSpeedRoundTrip(t *testing.T) {
+
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(WithName("my-project"))
+			if project.ReadingSpeed != DefaultReadingSpeed {
+				t.Errorf("Wanted default reading speed %f got %f", DefaultReadingSpeed, project.ReadingSpeed)
+			}
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			project.ReadingSpeed = 3.5
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := test.store.Load()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(projects) != 1 || projects[0].ReadingSpeed != 3.5 {
+				t.Errorf("Wanted one project with reading speed 3.5 got %+v", projects)
+			}
+		})
+	}
+}
+
+func TestSyncReels(t *testing.T) {
+	project := NewProject(
+		WithRecords([]ProjectContentRecord{{Reel: 2}, {Reel: 0}, {Reel: 2}, {Reel: 1}}),
+	)
+	project.Reels = []Reel{{Number: 2, Title: "The chase"}, {Number: 3, Title: "Unused"}}
+	project.SyncReels()
+
+	want := []Reel{{Number: 1}, {Number: 2, Title: "The chase"}}
+	if !slices.Equal(project.Reels, want) {
+		t.Errorf("Wanted %v got %v", want, project.Reels)
+	}
+	if title := project.ReelTitle(1); title != "Reel 1" {
+		t.Errorf("Wanted Reel 1 got %s", title)
+	}
+	if title := project.ReelTitle(2); title != "The chase" {
+		t.Errorf("Wanted The chase got %s", title)
+	}
+}
+
+func TestReelsRoundTrip(t *testing.T) {
+
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(
+				WithName("my-project"),
+				WithRecords([]ProjectContentRecord{{Scene: 0, Reel: 1}, {Scene: 1, Reel: 2}, {Scene: 2, Reel: 3}}),
+			)
+			project.Reels = []Reel{{Number: 3, Title: "Finale"}}
+			project.SyncReels()
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			project.Records = project.Records[1:]
+			project.SyncReels()
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := test.store.Load()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(projects) != 1 {
+				t.Errorf("Wanted one project got %d", len(projects))
+				return
+			}
+
+			var numbers []uint
+			for _, reel := range projects[0].Reels {
+				numbers = append(numbers, reel.Number)
+			}
+			if !slices.Equal(numbers, []uint{2, 3}) {
+				t.Errorf("Wanted reels 2 and 3 got %v", projects[0].Reels)
+			}
+			if title := projects[0].ReelTitle(3); title != "Finale" {
+				t.Errorf("Wanted Finale got %s", title)
+			}
+			for _, record := range projects[0].Records {
+				if record.Reel < 2 {
+					t.Errorf("Wanted scenes in reel 2 and 3 got %+v", record)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveManyRecords(t *testing.T) {
+	store := namedGormStore(t)
+
+	records := make([]ProjectContentRecord, 5000)
+	for i := range records {
+		records[i] = ProjectContentRecord{Scene: uint(i), SceneDesc: "shot", DurationSec: 2}
+	}
+	if err := store.Save(NewProject(WithName("edl"), WithRecords(records))); err != nil {
+		t.Error(err)
+		return
+	}
+
+	projects, err := store.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(projects) != 1 || len(projects[0].Records) != len(records) {
+		t.Errorf("Wanted one project with %d records", len(records))
+	}
+}
+
+func TestSaveChangeInManyRecords(t *testing.T) {
+	store := namedGormStore(t)
+
+	records := make([]ProjectContentRecord, 5000)
+	for i := range records {
+		records[i] = ProjectContentRecord{Scene: uint(i), SceneDesc: "shot", DurationSec: 2}
+	}
+	project := NewProject(WithName("edl"), WithRecords(records))
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+
+	project.Records[2500].SceneDesc = "Close-up"
+	project.Records = slices.Delete(project.Records, 10, 11)
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+
+	snapshots, err := store.History(project.Id)
+	if err != nil || len(snapshots) != 2 || snapshots[0].Summary != "Scenes 1 removed, 1 changed" {
+		t.Errorf("Wanted a snapshot of the changes got %d snapshots (%v)", len(snapshots), err)
+	}
+}
+
+func sceneIDs(records []ProjectContentRecord) map[string]uint {
+	ids := make(map[string]uint)
+	for _, record := range records {
+		ids[record.SceneDesc] = record.ID
+	}
+	return ids
+}
+
+func TestRecordIDsAreStable(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(WithName("my-project"), WithRecords(scenes("a", "b", "c")))
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+			ids := sceneIDs(project.Records)
+			if len(ids) != 3 || ids["a"] == 0 || ids["a"] == ids["b"] || ids["b"] == ids["c"] {
+				t.Errorf("Wanted distinct IDs got %v", ids)
+				return
+			}
+
+			// Move c first, change b, delete a and add d
+			b, c := project.Records[1], project.Records[2]
+			b.Tempo = 120
+			c.Scene, b.Scene = 0, 1
+			project.Records = []ProjectContentRecord{c, b, {Scene: 2, SceneDesc: "d"}}
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := test.store.Load()
+			if err != nil || len(projects) != 1 {
+				t.Errorf("Wanted one project got %d (%v)", len(projects), err)
+				return
+			}
+			var order []string
+			for _, record := range projects[0].Records {
+				order = append(order, record.SceneDesc)
+			}
+			if !slices.Equal(order, []string{"c", "b", "d"}) {
+				t.Errorf("Wanted scenes c, b, d got %v", order)
+			}
+
+			loaded := sceneIDs(projects[0].Records)
+			if loaded["b"] != ids["b"] || loaded["c"] != ids["c"] {
+				t.Errorf("Wanted IDs of b and c to be kept got %v and %v", ids, loaded)
+			}
+			if loaded["d"] == 0 || loaded["d"] != project.Records[2].ID {
+				t.Errorf("Wanted the new scene to get an ID got %d", project.Records[2].ID)
+			}
+			if projects[0].Records[1].Tempo != 120 {
+				t.Errorf("Wanted the changed scene to be updated got %+v", projects[0].Records[1])
+			}
+		})
+	}
+}
+
+func TestRecordsLoadedInSceneOrder(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			records := scenes("a", "b", "c", "d")
+			slices.Reverse(records)
+			if err := test.store.Save(NewProject(WithName("my-project"), WithRecords(records))); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := test.store.Load()
+			if err != nil || len(projects) != 1 {
+				t.Errorf("Wanted one project got %d (%v)", len(projects), err)
+				return
+			}
+			for i, record := range projects[0].Records {
+				if record.Scene != uint(i) {
+					t.Errorf("Wanted scene %d at position %d got %d", i, i, record.Scene)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordIDsOfOtherProjectsAreNotTaken(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			original := NewProject(WithName("original"), WithRecords(scenes("a", "b")))
+			if err := test.store.Save(original); err != nil {
+				t.Error(err)
+				return
+			}
+			copied := NewProject(WithName("copy"), WithRecords(slices.Clone(original.Records)))
+			if err := test.store.Save(copied); err != nil {
+				t.Error(err)
+				return
+			}
+
+			for i := range copied.Records {
+				if copied.Records[i].ID == original.Records[i].ID || copied.Records[i].ProjectID != copied.Id {
+					t.Errorf("Wanted a new record in the copy got %+v", copied.Records[i])
+				}
+			}
+			projects, err := test.store.Load()
+			if err != nil || len(projects) != 2 || len(projects[0].Records) != 2 || len(projects[1].Records) != 2 {
+				t.Errorf("Wanted two projects with two scenes got %+v (%v)", projects, err)
+			}
+		})
+	}
+}
+
+func TestDeletedRecordKeepsIDWhenRestored(t *testing.T) {
+	for _, test := range storeTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(WithName("my-project"), WithRecords(scenes("a", "b")))
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+			saved := slices.Clone(project.Records)
+
+			project.Records = project.Records[:1]
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+			project.Records = slices.Clone(saved)
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if project.Records[1].ID != saved[1].ID {
+				t.Errorf("Wanted ID %d got %d", saved[1].ID, project.Records[1].ID)
+			}
+		})
+	}
+}
+
+func TestSaveRefusesStaleProject(t *testing.T) {
+	for _, test := range []storeTest{
+		{store: namedGormStore(t), desc: "gorm store"},
+		{store: NewInMemoryProjectStore(), desc: "in memory store"},
+		{store: NewFileStore(t.TempDir(), FormatYAML), desc: "file store"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(WithName("my-project"), WithRecords(scenes("a", "b")))
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+			projects, err := test.store.Load()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			stale := projects[0]
+
+			project.Records = scenes("mine")
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+			if project.Version != 2 {
+				t.Errorf("Wanted version 2 got %d", project.Version)
+			}
+
+			stale.Records = scenes("theirs")
+			if err := test.store.Save(&stale); !errors.Is(err, ErrConflict) {
+				t.Errorf("Wanted ErrConflict got %v", err)
+			}
+			if stale.Version != 1 {
+				t.Errorf("Wanted the version to be kept after a conflict got %d", stale.Version)
+			}
+
+			projects, err = test.store.Load()
+			if err != nil || len(projects[0].Records) != 1 || projects[0].Records[0].SceneDesc != "mine" {
+				t.Errorf("Wanted the first save to be kept got %+v (%v)", projects, err)
+			}
+
+			// Saving with the stored version overwrites the changes
+			stale.Version = projects[0].Version
+			if err := test.store.Save(&stale); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTwoConnectionsToOneDatabase(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "shared.db")
+	var stores []*GormStore
+	for range 2 {
+		database, err := GormConnection(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Migrate(database); err != nil {
+			t.Fatal(err)
+		}
+		stores = append(stores, &GormStore{Database: database})
+	}
+
+	var mode string
+	if err := stores[0].Database.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil || mode != "wal" {
+		t.Errorf("Wanted journal mode wal got %s (%v)", mode, err)
+	}
+
+	project := NewProject(WithName("my-project"), WithRecords(scenes("a")))
+	if err := stores[0].Save(project); err != nil {
+		t.Fatal(err)
+	}
+	projects, err := stores[1].Load()
+	if err != nil || len(projects) != 1 {
+		t.Fatalf("Wanted the project to be seen by the other connection got %d (%v)", len(projects), err)
+	}
+
+	project.Records = scenes("a", "b")
+	if err := stores[0].Save(project); err != nil {
+		t.Error(err)
+	}
+	if err := stores[1].Save(&projects[0]); !errors.Is(err, ErrConflict) {
+		t.Errorf("Wanted ErrConflict got %v", err)
+	}
+}
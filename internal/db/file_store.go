@@ -0,0 +1,569 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileVersion is the version of the project file format written by
+// FileStore. Files of newer versions are not read.
+const ProjectFileVersion = 1
+
+var (
+	ErrUnsupportedFileVersion = errors.New("project file was written by a newer version")
+	ErrProjectNameExists      = errors.New("name already exists")
+	ErrUnknownFileFormat      = errors.New("project files must be yaml or json")
+	ErrProjectDirLocked       = errors.New("the project directory is locked by another save")
+	ErrDuplicateProjectID     = errors.New("several project files have the same id")
+)
+
+const (
+	// lockFileName is the file that marks the project directory as locked
+	lockFileName = ".lock"
+
+	// Locks older than this are left behind by a crash and are taken over
+	staleLockAge = time.Minute
+
+	defaultLockTimeout = 5 * time.Second
+	lockRetryInterval  = 10 * time.Millisecond
+)
+
+// ProjectFileFormat is the format projects are written in by FileStore
+type ProjectFileFormat int
+
+const (
+	FormatYAML ProjectFileFormat = iota
+	FormatJSON
+)
+
+func (f ProjectFileFormat) String() string {
+	if f == FormatJSON {
+		return "json"
+	}
+	return "yaml"
+}
+
+func (f ProjectFileFormat) extension() string {
+	if f == FormatJSON {
+		return ".json"
+	}
+	return ".yaml"
+}
+
+// ParseProjectFileFormat returns the format with the given name
+func ParseProjectFileFormat(name string) (ProjectFileFormat, error) {
+	switch strings.ToLower(name) {
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatYAML, ErrUnknownFileFormat
+}
+
+// sceneFile is a scene as written in a project file. Scenes are numbered by
+// their position in the file.
+type sceneFile struct {
+	ID          uint   `yaml:"id,omitempty" json:"id,omitempty"`
+	Description string `yaml:"description" json:"description"`
+	Keywords    string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+	Duration    int    `yaml:"duration,omitempty" json:"duration,omitempty"`
+	Tempo       uint   `yaml:"tempo,omitempty" json:"tempo,omitempty"`
+	Theme       uint   `yaml:"theme,omitempty" json:"theme,omitempty"`
+	Intensity   uint   `yaml:"intensity,omitempty" json:"intensity,omitempty"`
+	Reel        uint   `yaml:"reel,omitempty" json:"reel,omitempty"`
+	Timecode    string `yaml:"timecode,omitempty" json:"timecode,omitempty"`
+	Pinned      string `yaml:"pinned,omitempty" json:"pinned,omitempty"`
+}
+
+type reelFile struct {
+	Number uint   `yaml:"number" json:"number"`
+	Title  string `yaml:"title,omitempty" json:"title,omitempty"`
+}
+
+// projectFile is a project as written to disk
+type projectFile struct {
+	Version         int         `yaml:"version" json:"version"`
+	Revision        uint        `yaml:"revision,omitempty" json:"revision,omitempty"`
+	ID              uint        `yaml:"id" json:"id"`
+	Name            string      `yaml:"name" json:"name"`
+	CreatedAt       time.Time   `yaml:"created_at" json:"created_at"`
+	UpdatedAt       time.Time   `yaml:"updated_at" json:"updated_at"`
+	DiversityWindow int         `yaml:"diversity_window" json:"diversity_window"`
+	ReadingSpeed    float64     `yaml:"reading_speed" json:"reading_speed"`
+	Template        bool        `yaml:"template,omitempty" json:"template,omitempty"`
+	Reels           []reelFile  `yaml:"reels,omitempty" json:"reels,omitempty"`
+	Scenes          []sceneFile `yaml:"scenes" json:"scenes"`
+}
+
+// scenesFromRecords returns the records ordered by scene as written to files
+func scenesFromRecords(records []ProjectContentRecord) []sceneFile {
+	records = sortedByScene(records)
+	scenes := make([]sceneFile, len(records))
+	for i, record := range records {
+		scenes[i] = sceneFile{
+			ID:          record.ID,
+			Description: record.SceneDesc,
+			Keywords:    record.Keywords,
+			Duration:    record.DurationSec,
+			Tempo:       record.Tempo,
+			Theme:       record.Theme,
+			Intensity:   record.Intensity,
+			Reel:        record.Reel,
+			Timecode:    record.Timecode,
+			Pinned:      record.Pinned,
+		}
+	}
+	return scenes
+}
+
+// recordsFromScenes numbers the scenes by their position
+func recordsFromScenes(projectID uint, scenes []sceneFile) []ProjectContentRecord {
+	records := make([]ProjectContentRecord, len(scenes))
+	for i, scene := range scenes {
+		records[i] = ProjectContentRecord{
+			ID:          scene.ID,
+			ProjectID:   projectID,
+			Scene:       uint(i),
+			SceneDesc:   scene.Description,
+			DurationSec: scene.Duration,
+			Keywords:    scene.Keywords,
+			Tempo:       scene.Tempo,
+			Theme:       scene.Theme,
+			Intensity:   scene.Intensity,
+			Reel:        scene.Reel,
+			Timecode:    scene.Timecode,
+			Pinned:      scene.Pinned,
+		}
+	}
+	return records
+}
+
+func newProjectFile(p *Project) projectFile {
+	file := projectFile{
+		Version:         ProjectFileVersion,
+		Revision:        p.Version,
+		ID:              p.Id,
+		Name:            p.Name,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		DiversityWindow: p.DiversityWindow,
+		ReadingSpeed:    p.ReadingSpeed,
+		Template:        p.Template,
+		Scenes:          scenesFromRecords(p.Records),
+	}
+	for _, reel := range p.Reels {
+		file.Reels = append(file.Reels, reelFile{Number: reel.Number, Title: reel.Title})
+	}
+	return file
+}
+
+func (f *projectFile) project() Project {
+	p := Project{
+		Id:              f.ID,
+		Name:            f.Name,
+		CreatedAt:       f.CreatedAt,
+		UpdatedAt:       f.UpdatedAt,
+		DiversityWindow: f.DiversityWindow,
+		ReadingSpeed:    f.ReadingSpeed,
+		Template:        f.Template,
+		Version:         f.Revision,
+		Records:         recordsFromScenes(f.ID, f.Scenes),
+	}
+	for _, reel := range f.Reels {
+		p.Reels = append(p.Reels, Reel{ProjectID: f.ID, Number: reel.Number, Title: reel.Title})
+	}
+	return p
+}
+
+// FileStore keeps every project in its own YAML or JSON file in a directory,
+// such that projects can be edited by hand and kept in version control
+type FileStore struct {
+	Dir    string
+	Format ProjectFileFormat
+
+	// Checksum of each project file as last loaded or written by the store.
+	// A file with another checksum was changed by someone else.
+	mu        sync.Mutex
+	checksums map[uint]string
+
+	// How long a save waits for the lock of the directory. Zero means the
+	// default timeout.
+	lockTimeout time.Duration
+}
+
+func NewFileStore(dir string, format ProjectFileFormat) *FileStore {
+	return &FileStore{Dir: dir, Format: format}
+}
+
+// storedProject is a project read from the file at path
+type storedProject struct {
+	project  Project
+	path     string
+	checksum string
+
+	// The id of the project is used by another file as well. Such projects
+	// are not loaded, and their id is not given to new projects.
+	duplicate bool
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// remember records the checksum of the file of the project as last seen by the store
+func (fs *FileStore) remember(id uint, sum string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.checksums == nil {
+		fs.checksums = make(map[uint]string)
+	}
+	fs.checksums[id] = sum
+}
+
+func (fs *FileStore) forget(id uint) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	delete(fs.checksums, id)
+}
+
+// changedElsewhere returns true if the file of the project no longer is the
+// one last loaded or written by the store
+func (fs *FileStore) changedElsewhere(s *storedProject) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	sum, ok := fs.checksums[s.project.Id]
+	return ok && sum != s.checksum
+}
+
+// lock takes the lock of the project directory, such that no other program
+// writes a project between the check for conflicts and the write. The
+// returned function releases the lock.
+func (fs *FileStore) lock() (func(), error) {
+	timeout := fs.lockTimeout
+	if timeout == 0 {
+		timeout = defaultLockTimeout
+	}
+	path := filepath.Join(fs.Dir, lockFileName)
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			slog.Warn("Removing stale lock of the project directory", "path", path, "modified", info.ModTime())
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrProjectDirLocked
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileName returns the name of the file the project is written to. Projects
+// are named after their name, or their name and id if the name is taken.
+func (fs *FileStore) fileName(p *Project, stored []storedProject) string {
+	base := strings.Trim(unsafeFileNameChars.ReplaceAllString(p.Name, "_"), "_.")
+	if base == "" {
+		base = "project"
+	}
+	name := filepath.Join(fs.Dir, base+fs.Format.extension())
+	for _, s := range stored {
+		if s.project.Id == p.Id && s.path == name {
+			return name
+		}
+	}
+	// The name may also be taken by a file that could not be read
+	if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+		return name
+	}
+	return filepath.Join(fs.Dir, fmt.Sprintf("%s-%d%s", base, p.Id, fs.Format.extension()))
+}
+
+func isProjectFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func parseProjectFile(path string, content []byte) (Project, error) {
+	var file projectFile
+	var err error
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(content, &file)
+	} else {
+		err = yaml.Unmarshal(content, &file)
+	}
+	if err != nil {
+		return Project{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.Version > ProjectFileVersion {
+		return Project{}, fmt.Errorf("%s: %w", path, ErrUnsupportedFileVersion)
+	}
+	return file.project(), nil
+}
+
+func (fs *FileStore) loadStored() ([]storedProject, error) {
+	entries, err := os.ReadDir(fs.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []storedProject
+	for _, entry := range entries {
+		if entry.IsDir() || !isProjectFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(fs.Dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		project, err := parseProjectFile(path, content)
+		if err != nil {
+			slog.Warn("Skipping unreadable project file", "path", path, "error", err)
+			continue
+		}
+		stored = append(stored, storedProject{project: project, path: path, checksum: checksum(content)})
+	}
+	slices.SortFunc(stored, func(s1, s2 storedProject) int { return cmp.Compare(s1.project.Id, s2.project.Id) })
+
+	for i := 1; i < len(stored); i++ {
+		if stored[i].project.Id == stored[i-1].project.Id {
+			slog.Error("Skipping project files with the same id", "id", stored[i].project.Id, "path", stored[i-1].path, "other", stored[i].path)
+			stored[i].duplicate = true
+			stored[i-1].duplicate = true
+		}
+	}
+	return stored, nil
+}
+
+func (fs *FileStore) encode(p *Project) ([]byte, error) {
+	file := newProjectFile(p)
+	if fs.Format == FormatJSON {
+		content, err := json.MarshalIndent(file, "", "  ")
+		return append(content, '\n'), err
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return nil, err
+	}
+	err := encoder.Close()
+	return buffer.Bytes(), err
+}
+
+// writeFileAtomic writes the content to a temporary file in the same directory
+// and renames it to the path. A crash leaves either the old or the new file.
+func writeFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func (fs *FileStore) Save(p *Project) error {
+	if err := os.MkdirAll(fs.Dir, 0o755); err != nil {
+		return err
+	}
+	unlock, err := fs.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := fs.loadStored()
+	if err != nil {
+		return err
+	}
+
+	var previous string
+	var maxId uint
+	for _, s := range stored {
+		if s.project.Id != p.Id && s.project.Name == p.Name {
+			return ErrProjectNameExists
+		}
+		if s.project.Id == p.Id && s.duplicate {
+			return fmt.Errorf("%w: %d", ErrDuplicateProjectID, p.Id)
+		}
+		if s.project.Id == p.Id {
+			if s.project.Version != p.Version || fs.changedElsewhere(&s) {
+				return ErrConflict
+			}
+			previous = s.path
+		}
+		maxId = max(maxId, s.project.Id)
+	}
+	if p.Id == 0 {
+		p.Id = maxId + 1
+	}
+	p.UpdatedAt = time.Now()
+	if err := assignRecordIDs(p, stored); err != nil {
+		return err
+	}
+
+	p.Version++
+	path := fs.fileName(p, stored)
+	content, err := fs.encode(p)
+	if err == nil && previous != "" && previous != path {
+		// The file is renamed when the project is. It is moved before it is
+		// written, such that there is never more than one file of the project.
+		err = os.Rename(previous, path)
+	}
+	if err == nil {
+		err = writeFileAtomic(path, content)
+	}
+	if err != nil {
+		p.Version--
+		return err
+	}
+	fs.remember(p.Id, checksum(content))
+	return nil
+}
+
+// assignRecordIDs gives the new records of the project an ID that is not used
+// in any project file
+func assignRecordIDs(p *Project, stored []storedProject) error {
+	owners := make(map[uint]uint)
+	var lastID uint
+	for _, s := range stored {
+		for _, record := range s.project.Records {
+			owners[record.ID] = s.project.Id
+			lastID = max(lastID, record.ID)
+		}
+	}
+
+	owned := func(id uint) bool {
+		owner, ok := owners[id]
+		return ok && owner == p.Id
+	}
+	taken := func(ids []uint) ([]uint, error) {
+		var found []uint
+		for _, id := range ids {
+			if _, ok := owners[id]; ok {
+				found = append(found, id)
+			}
+		}
+		return found, nil
+	}
+	if err := claimRecordIDs(p, owned, taken); err != nil {
+		return err
+	}
+
+	for _, record := range p.Records {
+		lastID = max(lastID, record.ID)
+	}
+	for i := range p.Records {
+		if p.Records[i].ID == 0 {
+			lastID++
+			p.Records[i].ID = lastID
+		}
+	}
+	return nil
+}
+
+func (fs *FileStore) Delete(id uint) error {
+	unlock, err := fs.lock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := fs.loadStored()
+	if err != nil {
+		return err
+	}
+	for _, s := range stored {
+		if s.project.Id == id && !s.duplicate {
+			fs.forget(id)
+			return os.Remove(s.path)
+		}
+	}
+	return nil
+}
+
+func (fs *FileStore) Load() ([]Project, error) {
+	stored, err := fs.loadStored()
+	if err != nil {
+		return nil, err
+	}
+	var projects []Project
+	for _, s := range stored {
+		if s.duplicate {
+			continue
+		}
+		projects = append(projects, s.project)
+		fs.remember(s.project.Id, s.checksum)
+	}
+	return projects, nil
+}
+
+// SplitStore keeps projects in one store and the libraries and sections in
+// another
+type SplitStore struct {
+	ProjectStore
+	LibraryList
+	SectionStore
+}
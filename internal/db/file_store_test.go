@@ -0,0 +1,341 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fullProject() *Project {
+	project := NewProject(
+		WithName("The Kid"),
+		WithDiversityWindow(4),
+		WithReadingSpeed(1.5),
+		WithRecords([]ProjectContentRecord{
+			{Scene: 1, SceneDesc: "Chase: the police arrive", Keywords: "galop", DurationSec: 45, Tempo: 140, Theme: 2, Intensity: 5, Reel: 2, Timecode: "01:00:30"},
+			{Scene: 0, SceneDesc: "Opening", Keywords: "waltz sad", DurationSec: 30},
+		}),
+	)
+	project.Reels = []Reel{{Number: 1}, {Number: 2, Title: "The chase"}}
+	project.CreatedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return project
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	for _, format := range []ProjectFileFormat{FormatYAML, FormatJSON} {
+		t.Run(format.String(), func(t *testing.T) {
+			store := NewFileStore(t.TempDir(), format)
+			project := fullProject()
+			if err := store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := store.Load()
+			if err != nil || len(projects) != 1 {
+				t.Errorf("Wanted one project got %d (%v)", len(projects), err)
+				return
+			}
+			got := projects[0]
+			if got.Name != project.Name || got.Id != project.Id || got.DiversityWindow != 4 || got.ReadingSpeed != 1.5 {
+				t.Errorf("Wanted metadata of %+v got %+v", project, got)
+			}
+			if !got.CreatedAt.Equal(project.CreatedAt) || !got.UpdatedAt.Equal(project.UpdatedAt) {
+				t.Errorf("Wanted times %s and %s got %s and %s", project.CreatedAt, project.UpdatedAt, got.CreatedAt, got.UpdatedAt)
+			}
+			// Records get IDs in the order they are given
+			want := []ProjectContentRecord{
+				{ID: 2, ProjectID: project.Id, Scene: 0, SceneDesc: "Opening", Keywords: "waltz sad", DurationSec: 30},
+				{ID: 1, ProjectID: project.Id, Scene: 1, SceneDesc: "Chase: the police arrive", Keywords: "galop", DurationSec: 45, Tempo: 140, Theme: 2, Intensity: 5, Reel: 2, Timecode: "01:00:30"},
+			}
+			if !slices.Equal(got.Records, want) {
+				t.Errorf("Wanted scenes %+v got %+v", want, got.Records)
+			}
+			if got.ReelTitle(2) != "The chase" || len(got.Reels) != 2 {
+				t.Errorf("Wanted two reels got %+v", got.Reels)
+			}
+		})
+	}
+}
+
+func TestFileStoreFileNames(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, FormatYAML)
+	project := NewProject(WithName("The Kid / 1921"))
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "The_Kid_1921.yaml")); err != nil {
+		t.Errorf("Wanted file named after the project: %v", err)
+	}
+
+	other := NewProject(WithName("The Kid 1921"))
+	if err := store.Save(other); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "The_Kid_1921-2.yaml")); err != nil {
+		t.Errorf("Wanted id added to a taken file name: %v", err)
+	}
+
+	project.Name = "Sunrise"
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"Sunrise.yaml", "The_Kid_1921-2.yaml"}; !slices.Equal(names, want) {
+		t.Errorf("Wanted files %v after rename got %v", want, names)
+	}
+}
+
+func TestFileStoreDuplicateName(t *testing.T) {
+	store := NewFileStore(t.TempDir(), FormatJSON)
+	if err := store.Save(NewProject(WithName("p"))); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := store.Save(NewProject(WithName("p"))); err != ErrProjectNameExists {
+		t.Errorf("Wanted %v got %v", ErrProjectNameExists, err)
+	}
+}
+
+func TestFileStoreIgnoresTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, FormatYAML)
+	if err := store.Save(NewProject(WithName("p"))); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Left behind by a crash during a save
+	if err := os.WriteFile(filepath.Join(dir, ".p.yaml.123.tmp"), []byte("version: 1\nname: [unfinished"), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	projects, err := store.Load()
+	if err != nil || len(projects) != 1 {
+		t.Errorf("Wanted the saved project only got %d (%v)", len(projects), err)
+	}
+}
+
+func TestFileStoreLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, FormatYAML)
+	project := fullProject()
+	for range 3 {
+		if err := store.Save(project); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Wanted only the project file got %d files", len(entries))
+	}
+}
+
+func TestFileStoreReadsHandWrittenFile(t *testing.T) {
+	dir := t.TempDir()
+	content := "version: 1\nid: 3\nname: Nosferatu\nscenes:\n  - description: Opening\n    keywords: mysterious\n    duration: 40\n  - description: Castle\n"
+	if err := os.WriteFile(filepath.Join(dir, "nosferatu.yml"), []byte(content), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	projects, err := NewFileStore(dir, FormatYAML).Load()
+	if err != nil || len(projects) != 1 {
+		t.Errorf("Wanted one project got %d (%v)", len(projects), err)
+		return
+	}
+	if projects[0].Id != 3 || len(projects[0].Records) != 2 || projects[0].Records[1].Scene != 1 {
+		t.Errorf("Wanted project 3 with two numbered scenes got %+v", projects[0])
+	}
+}
+
+func TestFileStoreSkipsUnreadableFiles(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		content string
+	}{
+		{desc: "newer version", content: `{"version": 99, "id": 2, "name": "p"}`},
+		{desc: "malformed", content: `{"version": 1, "name": `},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			store := NewFileStore(dir, FormatJSON)
+			if err := store.Save(NewProject(WithName("Sunrise"))); err != nil {
+				t.Error(err)
+				return
+			}
+			path := filepath.Join(dir, "p.json")
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Error(err)
+				return
+			}
+
+			projects, err := store.Load()
+			if err != nil || len(projects) != 1 || projects[0].Name != "Sunrise" {
+				t.Errorf("Wanted the readable project only got %+v (%v)", projects, err)
+				return
+			}
+
+			// The unreadable file is not overwritten by a project of the same name
+			if err := store.Save(NewProject(WithName("p"))); err != nil {
+				t.Error(err)
+				return
+			}
+			if content, err := os.ReadFile(path); err != nil || string(content) != test.content {
+				t.Errorf("Wanted the unreadable file to be kept got %s (%v)", content, err)
+			}
+		})
+	}
+}
+
+func TestFileStoreSkipsDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, FormatYAML)
+	project := NewProject(WithName("p"))
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "p.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, "copy.yaml"), []byte(strings.Replace(string(content), "name: p", "name: copy", 1)), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	projects, err := store.Load()
+	if err != nil || len(projects) != 0 {
+		t.Errorf("Wanted projects with the same id to be skipped got %+v (%v)", projects, err)
+	}
+	if err := store.Save(project); !errors.Is(err, ErrDuplicateProjectID) {
+		t.Errorf("Wanted %v got %v", ErrDuplicateProjectID, err)
+	}
+
+	other := NewProject(WithName("other"))
+	if err := store.Save(other); err != nil || other.Id != project.Id+1 {
+		t.Errorf("Wanted a new id for a new project got %d (%v)", other.Id, err)
+	}
+}
+
+func TestFileStoreWritesVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewFileStore(dir, FormatYAML).Save(NewProject(WithName("p"))); err != nil {
+		t.Error(err)
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "p.yaml"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.HasPrefix(string(content), "version: 1\nrevision: 1\n") {
+		t.Errorf("Wanted the file to start with the version and revision got %s", content)
+	}
+}
+
+func TestFileStoreDetectsHandEdits(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, FormatYAML)
+	project := NewProject(WithName("p"), WithRecords([]ProjectContentRecord{{SceneDesc: "Opening", Keywords: "waltz"}}))
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Edited by hand without changing the revision
+	path := filepath.Join(dir, "p.yaml")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(content), "waltz", "march", 1)), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	project.Records[0].Keywords = "galop"
+	if err := store.Save(project); !errors.Is(err, ErrConflict) {
+		t.Errorf("Wanted %v got %v", ErrConflict, err)
+		return
+	}
+
+	projects, err := store.Load()
+	if err != nil || len(projects) != 1 || projects[0].Records[0].Keywords != "march" {
+		t.Errorf("Wanted the edited project got %+v (%v)", projects, err)
+		return
+	}
+	if err := store.Save(&projects[0]); err != nil {
+		t.Errorf("Wanted the reloaded project to be saved got %v", err)
+	}
+}
+
+func TestFileStoreLock(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		age  time.Duration
+		want error
+	}{
+		{desc: "held by another save", age: 0, want: ErrProjectDirLocked},
+		{desc: "left behind by a crash", age: 2 * staleLockAge, want: nil},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			lock := filepath.Join(dir, lockFileName)
+			if err := os.WriteFile(lock, nil, 0o644); err != nil {
+				t.Error(err)
+				return
+			}
+			modified := time.Now().Add(-test.age)
+			if err := os.Chtimes(lock, modified, modified); err != nil {
+				t.Error(err)
+				return
+			}
+
+			store := NewFileStore(dir, FormatYAML)
+			store.lockTimeout = 50 * time.Millisecond
+			if err := store.Save(NewProject(WithName("p"))); !errors.Is(err, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, err)
+			}
+			if _, err := os.Stat(lock); (err == nil) != (test.want != nil) {
+				t.Errorf("Wanted the lock to be kept only while another save holds it got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseProjectFileFormat(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want ProjectFileFormat
+		err  error
+	}{
+		{name: "yaml", want: FormatYAML},
+		{name: "JSON", want: FormatJSON},
+		{name: "toml", want: FormatYAML, err: ErrUnknownFileFormat},
+	} {
+		got, err := ParseProjectFileFormat(test.name)
+		if got != test.want || err != test.err {
+			t.Errorf("Wanted %s (%v) got %s (%v)", test.want, test.err, got, err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Snapshot is the record set of a project as it was after a save
+type Snapshot struct {
+	ID        uint `gorm:"primarykey,autoincrement"`
+	ProjectID uint `gorm:"index"`
+	CreatedAt time.Time
+
+	// Describes what changed since the previous snapshot
+	Summary string `gorm:"default:''"`
+
+	// The scenes encoded as JSON in the same way as in project files
+	Content string
+
+	// Number of scenes in the snapshot, such that it can be shown without
+	// decoding the content
+	SceneCount int `gorm:"default:0"`
+}
+
+// HistoryStore is implemented by stores that keep a snapshot of the records
+// every time a project is saved
+type HistoryStore interface {
+	// History returns the snapshots of the project with the newest first
+	History(projectID uint) ([]Snapshot, error)
+}
+
+// Records returns the scenes of the snapshot
+func (s *Snapshot) Records() ([]ProjectContentRecord, error) {
+	var scenes []sceneFile
+	if err := json.Unmarshal([]byte(s.Content), &scenes); err != nil {
+		return nil, err
+	}
+	return recordsFromScenes(s.ProjectID, scenes), nil
+}
+
+func encodeRecords(records []ProjectContentRecord) (string, error) {
+	content, err := json.Marshal(scenesFromRecords(records))
+	return string(content), err
+}
+
+// nextSnapshot returns a snapshot of the records of the project, or false
+// when they are the same as in the latest snapshot
+func nextSnapshot(p *Project, latest *Snapshot) (Snapshot, bool, error) {
+	content, err := encodeRecords(p.Records)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+
+	var previous []ProjectContentRecord
+	if latest != nil {
+		if latest.Content == content {
+			return Snapshot{}, false, nil
+		}
+		if previous, err = latest.Records(); err != nil {
+			return Snapshot{}, false, err
+		}
+	}
+
+	// Records that only got new IDs are not a change
+	changes := DiffRecords(previous, p.Records)
+	if latest != nil && len(changes) == 0 {
+		return Snapshot{}, false, nil
+	}
+	summary := p.ChangeSummary
+	if summary == "" {
+		summary = SummarizeChanges(changes)
+	}
+	snapshot := Snapshot{ProjectID: p.Id, CreatedAt: time.Now(), Summary: summary, SceneCount: len(p.Records), Content: content}
+	return snapshot, true, nil
+}
+
+type ChangeKind int
+
+const (
+	SceneAdded ChangeKind = iota
+	SceneRemoved
+	SceneChanged
+)
+
+// SceneChange is a difference between two record sets. Old is unset for
+// added scenes and New is unset for removed scenes.
+type SceneChange struct {
+	Kind ChangeKind
+	Old  ProjectContentRecord
+	New  ProjectContentRecord
+}
+
+// sceneNumber is the number of the scene as shown to the user
+func (c *SceneChange) sceneNumber() uint {
+	if c.Kind == SceneRemoved {
+		return c.Old.Scene + 1
+	}
+	return c.New.Scene + 1
+}
+
+// changedFields lists the fields that differ between the old and the new scene
+func (c *SceneChange) changedFields() []string {
+	var fields []string
+	add := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+	add("description", c.Old.SceneDesc != c.New.SceneDesc)
+	add("tempo", c.Old.Tempo != c.New.Tempo)
+	add("keywords", c.Old.Keywords != c.New.Keywords)
+	add("theme", c.Old.Theme != c.New.Theme)
+	add("duration", c.Old.DurationSec != c.New.DurationSec)
+	add("intensity", c.Old.Intensity != c.New.Intensity)
+	add("reel", c.Old.Reel != c.New.Reel)
+	add("start", c.Old.Timecode != c.New.Timecode)
+	add("pinned piece", c.Old.Pinned != c.New.Pinned)
+	return fields
+}
+
+func (c *SceneChange) String() string {
+	switch c.Kind {
+	case SceneAdded:
+		return fmt.Sprintf("+ scene %d: %q", c.sceneNumber(), c.New.SceneDesc)
+	case SceneRemoved:
+		return fmt.Sprintf("- scene %d: %q", c.sceneNumber(), c.Old.SceneDesc)
+	}
+	return fmt.Sprintf("~ scene %d: %q changed %s", c.sceneNumber(), c.New.SceneDesc, strings.Join(c.changedFields(), ", "))
+}
+
+// sameScene returns true if the records describe the same scene, regardless
+// of their position
+func sameScene(r1, r2 *ProjectContentRecord) bool {
+	return r1.SceneDesc == r2.SceneDesc && r1.DurationSec == r2.DurationSec && r1.Keywords == r2.Keywords &&
+		r1.Tempo == r2.Tempo && r1.Theme == r2.Theme && r1.Intensity == r2.Intensity && r1.Reel == r2.Reel &&
+		r1.Timecode == r2.Timecode && r1.Pinned == r2.Pinned
+}
+
+func sortedByScene(records []ProjectContentRecord) []ProjectContentRecord {
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(r1, r2 ProjectContentRecord) int { return cmp.Compare(r1.Scene, r2.Scene) })
+	return records
+}
+
+// DiffRecords returns the changes turning the records before into the records
+// after, ordered by scene. Records with the same ID are the same scene. The
+// scenes kept among the other records are found as the longest common
+// subsequence, and a scene removed where another is added is reported as
+// changed.
+func DiffRecords(before, after []ProjectContentRecord) []SceneChange {
+	from, to := sortedByScene(before), sortedByScene(after)
+
+	positions := make(map[uint]int)
+	for j, record := range to {
+		if record.ID != 0 {
+			positions[record.ID] = j
+		}
+	}
+	matched := make([]bool, len(to))
+	var (
+		changes   []SceneChange
+		unmatched []ProjectContentRecord
+	)
+	for _, record := range from {
+		j, ok := positions[record.ID]
+		if record.ID == 0 || !ok || matched[j] {
+			unmatched = append(unmatched, record)
+			continue
+		}
+		matched[j] = true
+		if !sameScene(&record, &to[j]) {
+			changes = append(changes, SceneChange{Kind: SceneChanged, Old: record, New: to[j]})
+		}
+	}
+	var rest []ProjectContentRecord
+	for j, record := range to {
+		if !matched[j] {
+			rest = append(rest, record)
+		}
+	}
+
+	changes = append(changes, diffSequences(unmatched, rest)...)
+	slices.SortStableFunc(changes, func(c1, c2 SceneChange) int { return cmp.Compare(c1.sceneNumber(), c2.sceneNumber()) })
+	return changes
+}
+
+// diffSequences returns the changes between two record sets in scene order,
+// using the longest common subsequence of their scenes
+func diffSequences(from, to []ProjectContentRecord) []SceneChange {
+	// Scenes kept at the start and the end are skipped, which keeps the table
+	// below small when a few scenes of a long project change
+	prefix := 0
+	for prefix < min(len(from), len(to)) && sameScene(&from[prefix], &to[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < min(len(from), len(to))-prefix && sameScene(&from[len(from)-1-suffix], &to[len(to)-1-suffix]) {
+		suffix++
+	}
+	from, to = from[prefix:len(from)-suffix], to[prefix:len(to)-suffix]
+
+	// common[i][j] is the length of the longest common subsequence of from[i:] and to[j:]
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if sameScene(&from[i], &to[j]) {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var (
+		changes []SceneChange
+		removed []ProjectContentRecord
+		added   []ProjectContentRecord
+	)
+
+	// Pairs the scenes removed and added between two common scenes
+	flush := func() {
+		n := min(len(removed), len(added))
+		for k := range n {
+			changes = append(changes, SceneChange{Kind: SceneChanged, Old: removed[k], New: added[k]})
+		}
+		for _, record := range removed[n:] {
+			changes = append(changes, SceneChange{Kind: SceneRemoved, Old: record})
+		}
+		for _, record := range added[n:] {
+			changes = append(changes, SceneChange{Kind: SceneAdded, New: record})
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && sameScene(&from[i], &to[j]):
+			flush()
+			i++
+			j++
+		case j == len(to) || (i < len(from) && common[i+1][j] >= common[i][j+1]):
+			removed = append(removed, from[i])
+			i++
+		default:
+			added = append(added, to[j])
+			j++
+		}
+	}
+	flush()
+	return changes
+}
+
+// SummarizeChanges describes the changes in one line
+func SummarizeChanges(changes []SceneChange) string {
+	switch len(changes) {
+	case 0:
+		return "No changes"
+	case 1:
+		return changes[0].String()
+	}
+
+	var counts [3]int
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+	var parts []string
+	for kind, verb := range []string{"added", "removed", "changed"} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], verb))
+		}
+	}
+	return "Scenes " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func scenes(descriptions ...string) []ProjectContentRecord {
+	records := make([]ProjectContentRecord, len(descriptions))
+	for i, desc := range descriptions {
+		records[i] = ProjectContentRecord{Scene: uint(i), SceneDesc: desc, DurationSec: 10}
+	}
+	return records
+}
+
+// withIDs gives the records the IDs in order
+func withIDs(records []ProjectContentRecord, ids ...uint) []ProjectContentRecord {
+	for i, id := range ids {
+		records[i].ID = id
+	}
+	return records
+}
+
+func TestDiffRecords(t *testing.T) {
+	for _, test := range []struct {
+		desc   string
+		before []ProjectContentRecord
+		after  []ProjectContentRecord
+		want   []string
+	}{
+		{
+			desc:   "no changes",
+			before: scenes("a", "b"),
+			after:  scenes("a", "b"),
+			want:   nil,
+		},
+		{
+			desc:   "scene added at the end",
+			before: scenes("a"),
+			after:  scenes("a", "b"),
+			want:   []string{`+ scene 2: "b"`},
+		},
+		{
+			desc:   "scene removed in the middle",
+			before: scenes("a", "b", "c"),
+			after:  scenes("a", "c"),
+			want:   []string{`- scene 2: "b"`},
+		},
+		{
+			desc:   "scene replaced",
+			before: scenes("a", "b", "c"),
+			after:  scenes("a", "x", "c"),
+			want:   []string{`~ scene 2: "x" changed description`},
+		},
+		{
+			desc:   "from nothing",
+			before: nil,
+			after:  scenes("a", "b"),
+			want:   []string{`+ scene 1: "a"`, `+ scene 2: "b"`},
+		},
+		{
+			desc:   "records are compared in scene order",
+			before: scenes("a", "b"),
+			after:  []ProjectContentRecord{scenes("a", "b")[1], scenes("a", "b")[0]},
+			want:   nil,
+		},
+		{
+			desc:   "scenes with IDs are matched by ID",
+			before: withIDs(scenes("a", "b"), 1, 2),
+			after:  withIDs(scenes("new", "a", "x"), 3, 1, 2),
+			want:   []string{`+ scene 1: "new"`, `~ scene 3: "x" changed description`},
+		},
+		{
+			desc:   "moved scene with ID is not a change",
+			before: withIDs(scenes("a", "b", "c"), 1, 2, 3),
+			after:  withIDs(scenes("c", "a", "b"), 3, 1, 2),
+			want:   nil,
+		},
+		{
+			desc:   "scenes without ID are compared by content",
+			before: withIDs(scenes("a", "b"), 1),
+			after:  withIDs(scenes("a", "b", "c"), 1),
+			want:   []string{`+ scene 3: "c"`},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var got []string
+			for _, change := range DiffRecords(test.before, test.after) {
+				got = append(got, change.String())
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	before := scenes("a")
+	after := scenes("a")
+	after[0].Tempo = 120
+	after[0].Timecode = "00:01:00"
+	after[0].Pinned = "abc"
+
+	changes := DiffRecords(before, after)
+	if len(changes) != 1 {
+		t.Errorf("Wanted 1 change got %d", len(changes))
+		return
+	}
+	want := `~ scene 1: "a" changed tempo, start, pinned piece`
+	if got := changes[0].String(); got != want {
+		t.Errorf("Wanted %q got %q", want, got)
+	}
+}
+
+func TestSummarizeChanges(t *testing.T) {
+	for _, test := range []struct {
+		before []ProjectContentRecord
+		after  []ProjectContentRecord
+		want   string
+	}{
+		{before: scenes("a"), after: scenes("a"), want: "No changes"},
+		{before: scenes("a"), after: scenes(), want: `- scene 1: "a"`},
+		{before: scenes("a", "b"), after: scenes("x", "b", "c", "d"), want: "Scenes 2 added, 1 changed"},
+	} {
+		if got := SummarizeChanges(DiffRecords(test.before, test.after)); got != test.want {
+			t.Errorf("Wanted %q got %q", test.want, got)
+		}
+	}
+}
+
+func historyStoreTests(t *testing.T) []storeTest {
+	var tests []storeTest
+	for _, test := range storeTests(t) {
+		if _, ok := test.store.(HistoryStore); ok {
+			tests = append(tests, test)
+		}
+	}
+	return tests
+}
+
+func TestSnapshotOnSave(t *testing.T) {
+	for _, test := range historyStoreTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			history := test.store.(HistoryStore)
+			project := NewProject(WithName("my-project"), WithRecords(scenes("a", "b")))
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			// Saving the same records does not store a snapshot
+			project.DiversityWindow = 5
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			project.Records = scenes("a")
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			project.Records = scenes("a", "b")
+			project.ChangeSummary = "Restored"
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+
+			snapshots, err := history.History(project.Id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var (
+				summaries []string
+				counts    []int
+			)
+			for _, snapshot := range snapshots {
+				summaries = append(summaries, snapshot.Summary)
+				counts = append(counts, snapshot.SceneCount)
+			}
+			want := []string{"Restored", `- scene 2: "b"`, "Scenes 2 added"}
+			if !slices.Equal(summaries, want) {
+				t.Errorf("Wanted %v got %v", want, summaries)
+			}
+			if wantCounts := []int{2, 1, 2}; !slices.Equal(counts, wantCounts) {
+				t.Errorf("Wanted scene counts %v got %v", wantCounts, counts)
+			}
+
+			records, err := snapshots[1].Records()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(records) != 1 || records[0].SceneDesc != "a" || records[0].ProjectID != project.Id {
+				t.Errorf("Wanted the single scene of the second snapshot got %v", records)
+			}
+		})
+	}
+}
+
+func TestDeleteRemovesHistory(t *testing.T) {
+	for _, test := range historyStoreTests(t) {
+		t.Run(test.desc, func(t *testing.T) {
+			project := NewProject(WithName("my-project"), WithRecords(scenes("a")))
+			if err := test.store.Save(project); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := test.store.Delete(project.Id); err != nil {
+				t.Error(err)
+				return
+			}
+
+			snapshots, err := test.store.(HistoryStore).History(project.Id)
+			if err != nil || len(snapshots) != 0 {
+				t.Errorf("Wanted no snapshots got %d (%v)", len(snapshots), err)
+			}
+		})
+	}
+}
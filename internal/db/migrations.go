@@ -0,0 +1,458 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration changes the schema of the database from the version before it
+// to its version. Migrations are applied in order, each in a transaction.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *gorm.DB) error
+}
+
+// SchemaVersion records a migration that has been applied to the database
+type SchemaVersion struct {
+	Version     int `gorm:"primarykey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+var ErrNewerSchema = errors.New("the database was migrated by a newer version")
+
+// Tables as they were when the database got its first version. Migrations
+// use their own copies of the models, such that they keep doing the same when
+// the models change.
+type recordV1 struct {
+	ProjectID   uint
+	Scene       uint
+	SceneDesc   string `gorm:"default:''"`
+	DurationSec int    `gorm:"default:0"`
+	Keywords    string `gorm:"default:''"`
+	Tempo       uint   `gorm:"default:0"`
+	Theme       uint   `gorm:"default:0"`
+	Intensity   uint   `gorm:"default:0"`
+	Reel        uint   `gorm:"default:0"`
+	Timecode    string `gorm:"default:''"`
+}
+
+func (recordV1) TableName() string { return "project_content_records" }
+
+type reelV1 struct {
+	ProjectID uint
+	Number    uint
+	Title     string `gorm:"default:''"`
+}
+
+func (reelV1) TableName() string { return "reels" }
+
+type projectV1 struct {
+	Id              uint   `gorm:"primarykey,autoincrement,unique"`
+	Name            string `gorm:"unique"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Records         []recordV1 `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	Reels           []reelV1   `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	DiversityWindow int
+	ReadingSpeed    float64
+}
+
+func (projectV1) TableName() string { return "projects" }
+
+type libraryV1 struct {
+	ID        uint `gorm:"primarykey,autoincrement"`
+	CreatedAt time.Time
+	Path      string `gorm:"unique"`
+}
+
+func (libraryV1) TableName() string { return "configured_libraries" }
+
+type sectionV1 struct {
+	ID          uint   `gorm:"primarykey,autoincrement"`
+	Fingerprint string `gorm:"index"`
+	Position    int
+	Name        string `gorm:"default:''"`
+	FirstBar    int
+	LastBar     int
+	Disabled    bool
+}
+
+func (sectionV1) TableName() string { return "piece_sections" }
+
+type snapshotV2 struct {
+	ID        uint `gorm:"primarykey,autoincrement"`
+	ProjectID uint `gorm:"index"`
+	CreatedAt time.Time
+	Summary   string `gorm:"default:''"`
+	Content   string
+}
+
+func (snapshotV2) TableName() string { return "snapshots" }
+
+type recordV3 struct {
+	ID          uint   `gorm:"primarykey,autoincrement"`
+	ProjectID   uint   `gorm:"index:idx_record_position"`
+	Scene       uint   `gorm:"index:idx_record_position"`
+	SceneDesc   string `gorm:"default:''"`
+	DurationSec int    `gorm:"default:0"`
+	Keywords    string `gorm:"default:''"`
+	Tempo       uint   `gorm:"default:0"`
+	Theme       uint   `gorm:"default:0"`
+	Intensity   uint   `gorm:"default:0"`
+	Reel        uint   `gorm:"default:0"`
+	Timecode    string `gorm:"default:''"`
+}
+
+func (recordV3) TableName() string { return "project_content_records" }
+
+type projectV3 struct {
+	Id              uint   `gorm:"primarykey,autoincrement,unique"`
+	Name            string `gorm:"unique"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Records         []recordV3 `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	DiversityWindow int
+	ReadingSpeed    float64
+}
+
+func (projectV3) TableName() string { return "projects" }
+
+type projectV4 struct {
+	Version uint `gorm:"default:0"`
+}
+
+func (projectV4) TableName() string { return "projects" }
+
+type projectV5 struct {
+	Template bool `gorm:"default:false"`
+}
+
+func (projectV5) TableName() string { return "projects" }
+
+type reelV6 struct {
+	ProjectID uint   `gorm:"primarykey;autoIncrement:false"`
+	Number    uint   `gorm:"primarykey;autoIncrement:false"`
+	Title     string `gorm:"default:''"`
+}
+
+func (reelV6) TableName() string { return "reels" }
+
+type projectV6 struct {
+	Id    uint     `gorm:"primarykey,autoincrement,unique"`
+	Reels []reelV6 `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+}
+
+func (projectV6) TableName() string { return "projects" }
+
+type snapshotV7 struct {
+	SceneCount int `gorm:"default:0"`
+}
+
+func (snapshotV7) TableName() string { return "snapshots" }
+
+type recordV8 struct {
+	Pinned string `gorm:"default:''"`
+}
+
+func (recordV8) TableName() string { return "project_content_records" }
+
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create the project, scene, reel, library and section tables",
+		Up: func(tx *gorm.DB) error {
+			// Databases created before versioning get the columns they miss
+			return addProjectSettings(tx, func() error {
+				return tx.AutoMigrate(&projectV1{}, &recordV1{}, &reelV1{}, &libraryV1{}, &sectionV1{})
+			})
+		},
+	},
+	{
+		Version:     2,
+		Description: "Store a snapshot of the scenes on every save",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&snapshotV2{})
+		},
+	},
+	{
+		Version:     3,
+		Description: "Give every scene an ID and index scenes by position",
+		Up:          addRecordIDs,
+	},
+	{
+		Version:     4,
+		Description: "Count the saves of a project to detect concurrent changes",
+		Up: func(tx *gorm.DB) error {
+			hasVersion, err := hasColumn(tx, "projects", "version")
+			if err != nil || hasVersion {
+				return err
+			}
+			return tx.Migrator().AddColumn(&projectV4{}, "Version")
+		},
+	},
+	{
+		Version:     5,
+		Description: "Mark projects used as templates",
+		Up: func(tx *gorm.DB) error {
+			hasTemplate, err := hasColumn(tx, "projects", "template")
+			if err != nil || hasTemplate {
+				return err
+			}
+			return tx.Migrator().AddColumn(&projectV5{}, "Template")
+		},
+	},
+	{
+		Version:     6,
+		Description: "Identify reels by project and number",
+		Up:          addReelKey,
+	},
+	{
+		Version:     7,
+		Description: "Count the scenes of every snapshot",
+		Up: func(tx *gorm.DB) error {
+			hasCount, err := hasColumn(tx, "snapshots", "scene_count")
+			if err != nil || hasCount {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&snapshotV7{}, "SceneCount"); err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE snapshots SET scene_count = json_array_length(content)").Error
+		},
+	},
+	{
+		Version:     8,
+		Description: "Pin pieces to scenes",
+		Up: func(tx *gorm.DB) error {
+			hasPinned, err := hasColumn(tx, "project_content_records", "pinned")
+			if err != nil || hasPinned {
+				return err
+			}
+			return tx.Migrator().AddColumn(&recordV8{}, "Pinned")
+		},
+	},
+}
+
+// Migrations returns every migration in the order they are applied
+func Migrations() []Migration {
+	return slices.Clone(migrations)
+}
+
+func hasColumn(tx *gorm.DB, table, name string) (bool, error) {
+	// HasColumn of the SQLite driver matches project_id when looking for id
+	columnTypes, err := tx.Migrator().ColumnTypes(table)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(columnTypes, func(c gorm.ColumnType) bool { return c.Name() == name }), nil
+}
+
+// addProjectSettings runs the migration and gives projects stored before the
+// diversity window and reading speed existed their defaults instead of zero.
+// Zero is a valid choice for later projects, so only rows that had no such
+// column are updated.
+func addProjectSettings(tx *gorm.DB, migrate func() error) error {
+	hadProjects := tx.Migrator().HasTable("projects")
+	hadSettings := false
+	if hadProjects {
+		var err error
+		if hadSettings, err = hasColumn(tx, "projects", "diversity_window"); err != nil {
+			return err
+		}
+	}
+	if err := migrate(); err != nil {
+		return err
+	}
+	if !hadProjects || hadSettings {
+		return nil
+	}
+	return tx.Exec("UPDATE projects SET diversity_window = ?, reading_speed = ?", DefaultDiversityWindow, DefaultReadingSpeed).Error
+}
+
+// addRecordIDs rebuilds the scene table with an ID column, since SQLite can
+// not add a primary key to an existing table. Scenes get IDs in scene order.
+func addRecordIDs(tx *gorm.DB) error {
+	const table, legacyTable = "project_content_records", "legacy_project_content_records"
+	hasID, err := hasColumn(tx, table, "id")
+	if err != nil || hasID {
+		return err
+	}
+
+	migrator := tx.Migrator()
+	if err := migrator.RenameTable(table, legacyTable); err != nil {
+		return err
+	}
+	if err := tx.AutoMigrate(&projectV3{}, &recordV3{}); err != nil {
+		return err
+	}
+
+	legacyTypes, err := migrator.ColumnTypes(legacyTable)
+	if err != nil {
+		return err
+	}
+	var columns []string
+	for _, column := range legacyTypes {
+		columns = append(columns, "`"+column.Name()+"`")
+	}
+	list := strings.Join(columns, ", ")
+	err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ORDER BY project_id, scene, rowid", table, list, list, legacyTable)).Error
+	if err != nil {
+		return err
+	}
+	return migrator.DropTable(legacyTable)
+}
+
+// addReelKey rebuilds the reel table with the project and number as primary
+// key, since SQLite can not add a primary key to an existing table. Of reels
+// stored more than once, the first one is kept.
+func addReelKey(tx *gorm.DB) error {
+	const table, legacyTable = "reels", "legacy_reels"
+	migrator := tx.Migrator()
+	if err := migrator.RenameTable(table, legacyTable); err != nil {
+		return err
+	}
+	if err := tx.AutoMigrate(&projectV6{}, &reelV6{}); err != nil {
+		return err
+	}
+	err := tx.Exec(fmt.Sprintf("INSERT OR IGNORE INTO %s (project_id, number, title) SELECT project_id, number, title FROM %s ORDER BY rowid", table, legacyTable)).Error
+	if err != nil {
+		return err
+	}
+	return migrator.DropTable(legacyTable)
+}
+
+// AppliedMigrations returns the migrations applied to the database in order
+func AppliedMigrations(con *gorm.DB) ([]SchemaVersion, error) {
+	if err := con.AutoMigrate(&SchemaVersion{}); err != nil {
+		return nil, err
+	}
+	var applied []SchemaVersion
+	tx := con.Order("version").Find(&applied)
+	return applied, tx.Error
+}
+
+// CurrentSchemaVersion returns the version of the latest migration applied
+// to the database. It is zero for new databases.
+func CurrentSchemaVersion(con *gorm.DB) (int, error) {
+	applied, err := AppliedMigrations(con)
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// PendingMigrations returns the migrations that have not been applied
+func PendingMigrations(con *gorm.DB) ([]Migration, error) {
+	version, err := CurrentSchemaVersion(con)
+	if err != nil {
+		return nil, err
+	}
+	if version > migrations[len(migrations)-1].Version {
+		return nil, ErrNewerSchema
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// databaseFile returns the file of the main database. It is empty for
+// databases kept in memory.
+func databaseFile(con *gorm.DB) (string, error) {
+	var databases []struct {
+		Seq  int
+		Name string
+		File string
+	}
+	if err := con.Raw("PRAGMA database_list").Scan(&databases).Error; err != nil {
+		return "", err
+	}
+	for _, database := range databases {
+		if database.Name == "main" {
+			return database.File, nil
+		}
+	}
+	return "", nil
+}
+
+// BackupDatabase writes a copy of a file database next to it, named after the
+// schema version and the time. It returns the name of the copy, which is empty
+// when the database is kept in memory.
+func BackupDatabase(con *gorm.DB) (string, error) {
+	file, err := databaseFile(con)
+	if err != nil || file == "" {
+		return "", err
+	}
+	version, err := CurrentSchemaVersion(con)
+	if err != nil {
+		return "", err
+	}
+
+	backup := fmt.Sprintf("%s.v%d-%s.bak", file, version, time.Now().Format("20060102-150405"))
+	if err := con.Exec("VACUUM INTO ?", backup).Error; err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// hasData returns true if the database has tables other than the schema version
+func hasData(con *gorm.DB) (bool, error) {
+	tables, err := con.Migrator().GetTables()
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(tables, func(table string) bool { return table != SchemaVersion{}.TableName() }), nil
+}
+
+// Migrate applies the pending migrations. A file database that already holds
+// tables is backed up first. It returns the name of the backup, which is
+// empty when no backup was made.
+func Migrate(con *gorm.DB) (string, error) {
+	if err := con.Exec("PRAGMA foreign_keys = ON").Error; err != nil {
+		return "", err
+	}
+	pending, err := PendingMigrations(con)
+	if err != nil || len(pending) == 0 {
+		return "", err
+	}
+
+	var backup string
+	if populated, err := hasData(con); err != nil {
+		return "", err
+	} else if populated {
+		if backup, err = BackupDatabase(con); err != nil {
+			return "", err
+		}
+		slog.Info("Backed up database before migrating", "backup", backup)
+	}
+
+	for _, migration := range pending {
+		err := con.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaVersion{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return backup, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		slog.Info("Applied migration", "version", migration.Version, "description", migration.Description)
+	}
+	return backup, nil
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func tempDatabase(t *testing.T) *gorm.DB {
+	database, err := GormConnection(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func execAll(database *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := database.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	database := tempDatabase(t)
+	pending, err := PendingMigrations(database)
+	if err != nil || len(pending) != len(Migrations()) {
+		t.Errorf("Wanted all migrations to be pending got %d (%v)", len(pending), err)
+	}
+
+	backup, err := Migrate(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != "" {
+		t.Errorf("Wanted no backup of a new database got %s", backup)
+	}
+
+	version, err := CurrentSchemaVersion(database)
+	want := Migrations()[len(Migrations())-1].Version
+	if err != nil || version != want {
+		t.Errorf("Wanted version %d got %d (%v)", want, version, err)
+	}
+	if pending, err := PendingMigrations(database); err != nil || len(pending) != 0 {
+		t.Errorf("Wanted no pending migrations got %d (%v)", len(pending), err)
+	}
+
+	// Migrating again does nothing
+	if backup, err := Migrate(database); err != nil || backup != "" {
+		t.Errorf("Wanted nothing to be done got backup %q (%v)", backup, err)
+	}
+}
+
+func schema(t *testing.T, database *gorm.DB) []string {
+	var statements []string
+	err := database.Raw("SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT IN ('schema_version', 'sqlite_sequence') ORDER BY name").Scan(&statements).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SQLite puts a space before the columns added to an existing table
+	for i, statement := range statements {
+		statements[i] = strings.ReplaceAll(statement, ", `", ",`")
+	}
+	return statements
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	migrated := tempDatabase(t)
+	if _, err := Migrate(migrated); err != nil {
+		t.Fatal(err)
+	}
+	fromModels := tempDatabase(t)
+	if err := fromModels.AutoMigrate(&Project{}, &ProjectContentRecord{}, &Reel{}, &ConfiguredLibraries{}, &PieceSection{}, &Snapshot{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want, got := schema(t, fromModels), schema(t, migrated)
+	if !slices.Equal(want, got) {
+		t.Errorf("Wanted the migrated schema to match the models\nwant %v\ngot  %v", want, got)
+	}
+}
+
+func TestMigrateUnversionedDatabase(t *testing.T) {
+	database := tempDatabase(t)
+	err := execAll(database,
+		"CREATE TABLE projects (id integer PRIMARY KEY AUTOINCREMENT, name text UNIQUE, created_at datetime, updated_at datetime)",
+		"CREATE TABLE project_content_records (project_id integer, scene integer, scene_desc text, duration_sec integer)",
+		"INSERT INTO projects (id, name) VALUES (1, 'old')",
+		"INSERT INTO project_content_records VALUES (1, 1, 'second', 20), (1, 0, 'first', 10)",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := Migrate(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backup); err != nil {
+		t.Errorf("Wanted a backup got %q (%v)", backup, err)
+	}
+	if !strings.Contains(backup, "test.db.v0-") {
+		t.Errorf("Wanted the backup to be named after the database and version got %s", backup)
+	}
+
+	store := GormStore{Database: database}
+	projects, err := store.Load()
+	if err != nil || len(projects) != 1 || len(projects[0].Records) != 2 {
+		t.Fatalf("Wanted the project with two scenes got %+v (%v)", projects, err)
+	}
+	if projects[0].DiversityWindow != DefaultDiversityWindow || projects[0].ReadingSpeed != DefaultReadingSpeed {
+		t.Errorf("Wanted the default settings got window %d and speed %f", projects[0].DiversityWindow, projects[0].ReadingSpeed)
+	}
+	records := projects[0].Records
+	if records[0].SceneDesc != "first" || records[0].ID != 1 || records[1].ID != 2 || records[1].DurationSec != 20 {
+		t.Errorf("Wanted records with IDs in scene order got %+v", records)
+	}
+	if database.Migrator().HasTable("legacy_project_content_records") {
+		t.Errorf("Wanted the legacy table to be removed")
+	}
+
+	// Columns added by the migrations can be used
+	projects[0].Records[0].Timecode = "00:00:10"
+	if err := store.Save(&projects[0]); err != nil {
+		t.Error(err)
+	}
+
+	// Deleting a project deletes its scenes
+	if err := store.Delete(1); err != nil {
+		t.Error(err)
+	}
+	var count int64
+	database.Model(&ProjectContentRecord{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Wanted scenes to be deleted with the project got %d", count)
+	}
+}
+
+func TestMigrateKeepsStoredDiversityWindow(t *testing.T) {
+	database := tempDatabase(t)
+	err := execAll(database,
+		"CREATE TABLE projects (id integer PRIMARY KEY AUTOINCREMENT, name text UNIQUE, created_at datetime, updated_at datetime, diversity_window integer, reading_speed real)",
+		"INSERT INTO projects (id, name, diversity_window, reading_speed) VALUES (1, 'old', 0, 1.5)",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(database); err != nil {
+		t.Fatal(err)
+	}
+
+	store := GormStore{Database: database}
+	projects, err := store.Load()
+	if err != nil || len(projects) != 1 {
+		t.Fatalf("Wanted one project got %+v (%v)", projects, err)
+	}
+	if projects[0].DiversityWindow != 0 || projects[0].ReadingSpeed != 1.5 {
+		t.Errorf("Wanted the stored settings kept got window %d and speed %f", projects[0].DiversityWindow, projects[0].ReadingSpeed)
+	}
+}
+
+func TestMigrateKeepsFirstOfDuplicatedReels(t *testing.T) {
+	database := tempDatabase(t)
+	err := execAll(database,
+		"CREATE TABLE projects (id integer PRIMARY KEY AUTOINCREMENT, name text UNIQUE, created_at datetime, updated_at datetime)",
+		"CREATE TABLE reels (project_id integer, number integer, title text)",
+		"INSERT INTO projects (id, name) VALUES (1, 'old')",
+		"INSERT INTO reels VALUES (1, 1, 'Arrival'), (1, 2, 'The chase'), (1, 1, 'Departure')",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(database); err != nil {
+		t.Fatal(err)
+	}
+
+	store := GormStore{Database: database}
+	projects, err := store.Load()
+	if err != nil || len(projects) != 1 {
+		t.Fatalf("Wanted one project got %+v (%v)", projects, err)
+	}
+	want := []Reel{{ProjectID: 1, Number: 1, Title: "Arrival"}, {ProjectID: 1, Number: 2, Title: "The chase"}}
+	if !slices.Equal(projects[0].Reels, want) {
+		t.Errorf("Wanted %+v got %+v", want, projects[0].Reels)
+	}
+	if database.Migrator().HasTable("legacy_reels") {
+		t.Errorf("Wanted the legacy table to be removed")
+	}
+
+	// A reel can not be stored twice
+	if err := database.Create(&Reel{ProjectID: 1, Number: 2}).Error; err == nil {
+		t.Errorf("Wanted an error when storing reel 2 twice")
+	}
+}
+
+func TestMigrateCountsScenesOfSnapshots(t *testing.T) {
+	database := tempDatabase(t)
+	err := execAll(database,
+		"CREATE TABLE projects (id integer PRIMARY KEY AUTOINCREMENT, name text UNIQUE, created_at datetime, updated_at datetime)",
+		"CREATE TABLE snapshots (id integer PRIMARY KEY AUTOINCREMENT, project_id integer, created_at datetime, summary text, content text)",
+		"INSERT INTO projects (id, name) VALUES (1, 'old')",
+		`INSERT INTO snapshots (project_id, content) VALUES (1, '[{"description":"a"},{"description":"b"}]'), (1, '[]')`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(database); err != nil {
+		t.Fatal(err)
+	}
+
+	store := GormStore{Database: database}
+	snapshots, err := store.History(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counts []int
+	for _, snapshot := range snapshots {
+		counts = append(counts, snapshot.SceneCount)
+	}
+	if want := []int{0, 2}; !slices.Equal(counts, want) {
+		t.Errorf("Wanted scene counts %v got %v", want, counts)
+	}
+}
+
+func TestFailingMigrationIsRolledBack(t *testing.T) {
+	database := tempDatabase(t)
+	if _, err := Migrate(database); err != nil {
+		t.Fatal(err)
+	}
+	version, _ := CurrentSchemaVersion(database)
+
+	failure := errors.New("failed")
+	original := migrations
+	defer func() { migrations = original }()
+	migrations = append(slices.Clone(original),
+		Migration{Version: version + 1, Description: "Create a table", Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TABLE extra (id integer)").Error
+		}},
+		Migration{Version: version + 2, Description: "Fail", Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE half (id integer)").Error; err != nil {
+				return err
+			}
+			return failure
+		}},
+	)
+
+	backup, err := Migrate(database)
+	if !errors.Is(err, failure) {
+		t.Errorf("Wanted the migration to fail got %v", err)
+	}
+	if backup == "" {
+		t.Errorf("Wanted a backup before migrating")
+	}
+	if got, _ := CurrentSchemaVersion(database); got != version+1 {
+		t.Errorf("Wanted version %d got %d", version+1, got)
+	}
+	if !database.Migrator().HasTable("extra") || database.Migrator().HasTable("half") {
+		t.Errorf("Wanted the failing migration to be rolled back")
+	}
+}
+
+func TestNewerSchemaIsRejected(t *testing.T) {
+	database := tempDatabase(t)
+	if _, err := Migrate(database); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Create(&SchemaVersion{Version: 1000, Description: "From the future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(database); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("Wanted ErrNewerSchema got %v", err)
+	}
+}
+
+func TestBackupOfMemoryDatabase(t *testing.T) {
+	database, err := GormConnection(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup, err := BackupDatabase(database); err != nil || backup != "" {
+		t.Errorf("Wanted no backup got %q (%v)", backup, err)
+	}
+}
@@ -1,6 +1,10 @@
 package db
 
-import "fmt"
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
 
 type ProjectStore interface {
 	Save(project *Project) error
@@ -9,12 +13,17 @@ type ProjectStore interface {
 }
 
 type InMemoryProjectStore struct {
-	projects map[uint]Project
+	projects  map[uint]Project
+	snapshots map[uint][]Snapshot
+
+	// Largest ID given to a record
+	lastRecordID uint
 }
 
 func NewInMemoryProjectStore() *InMemoryProjectStore {
 	return &InMemoryProjectStore{
-		projects: make(map[uint]Project),
+		projects:  make(map[uint]Project),
+		snapshots: make(map[uint][]Snapshot),
 	}
 }
 
@@ -32,20 +41,128 @@ func (im *InMemoryProjectStore) Save(project *Project) error {
 			return fmt.Errorf("name already exists")
 		}
 	}
+	if stored, ok := im.projects[project.Id]; ok && stored.Version != project.Version {
+		return ErrConflict
+	}
+
+	if err := im.saveRecords(project); err != nil {
+		return err
+	}
+	project.Version++
+	stored := *project
+	stored.Records = sortedByScene(project.Records)
+	im.projects[project.Id] = stored
+	return im.saveSnapshot(project)
+}
+
+// saveRecords gives the new records of the project an ID
+func (im *InMemoryProjectStore) saveRecords(project *Project) error {
+	owned := func(id uint) bool {
+		return slices.ContainsFunc(im.projects[project.Id].Records, func(r ProjectContentRecord) bool { return r.ID == id })
+	}
+	taken := func(ids []uint) ([]uint, error) {
+		var found []uint
+		for _, p := range im.projects {
+			for _, record := range p.Records {
+				if slices.Contains(ids, record.ID) {
+					found = append(found, record.ID)
+				}
+			}
+		}
+		return found, nil
+	}
+	if err := claimRecordIDs(project, owned, taken); err != nil {
+		return err
+	}
+
+	for _, record := range project.Records {
+		im.lastRecordID = max(im.lastRecordID, record.ID)
+	}
+	for i := range project.Records {
+		if project.Records[i].ID == 0 {
+			im.lastRecordID++
+			project.Records[i].ID = im.lastRecordID
+		}
+	}
+	return nil
+}
+
+// claimRecordIDs sets the project of the records and clears the IDs that can
+// not be kept, such that the records are stored as new ones. IDs appearing
+// twice are cleared, as are IDs taken by records that are not owned by the
+// project. Other IDs are kept, such that a deleted record can be restored.
+func claimRecordIDs(p *Project, owned func(id uint) bool, taken func(ids []uint) ([]uint, error)) error {
+	seen := make(map[uint]bool)
+	var foreign []uint
+	for i := range p.Records {
+		record := &p.Records[i]
+		record.ProjectID = p.Id
+		if record.ID == 0 {
+			continue
+		}
+		if seen[record.ID] {
+			record.ID = 0
+			continue
+		}
+		seen[record.ID] = true
+		if !owned(record.ID) {
+			foreign = append(foreign, record.ID)
+		}
+	}
+	if len(foreign) == 0 {
+		return nil
+	}
 
-	im.projects[project.Id] = *project
+	ids, err := taken(foreign)
+	if err != nil {
+		return err
+	}
+	isTaken := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		isTaken[id] = true
+	}
+	for i := range p.Records {
+		if isTaken[p.Records[i].ID] {
+			p.Records[i].ID = 0
+		}
+	}
+	return nil
+}
+
+func (im *InMemoryProjectStore) saveSnapshot(project *Project) error {
+	var latest *Snapshot
+	snapshots := im.snapshots[project.Id]
+	if n := len(snapshots); n > 0 {
+		latest = &snapshots[n-1]
+	}
+
+	snapshot, changed, err := nextSnapshot(project, latest)
+	if err != nil || !changed {
+		return err
+	}
+	snapshot.ID = uint(len(snapshots) + 1)
+	im.snapshots[project.Id] = append(snapshots, snapshot)
 	return nil
 }
 
 func (im *InMemoryProjectStore) Delete(id uint) error {
 	delete(im.projects, id)
+	delete(im.snapshots, id)
 	return nil
 }
 
+func (im *InMemoryProjectStore) History(projectID uint) ([]Snapshot, error) {
+	snapshots := slices.Clone(im.snapshots[projectID])
+	slices.Reverse(snapshots)
+	return snapshots, nil
+}
+
 func (im *InMemoryProjectStore) Load() ([]Project, error) {
 	projList := make([]Project, 0, len(im.projects))
 	for _, project := range im.projects {
+		project.Records = slices.Clone(project.Records)
 		projList = append(projList, project)
 	}
+	slices.SortFunc(projList, func(p1, p2 Project) int { return cmp.Compare(p1.Id, p2.Id) })
 	return projList, nil
 }
@@ -0,0 +1,56 @@
+package db
+
+import "slices"
+
+// PieceSection is a user defined section of a piece in the library. The piece
+// is identified by the fingerprint of its file, and the bars are numbered
+// from one with the last bar included in the section.
+type PieceSection struct {
+	ID          uint   `gorm:"primarykey,autoincrement"`
+	Fingerprint string `gorm:"index"`
+	Position    int
+	Name        string `gorm:"default:''"`
+	FirstBar    int
+	LastBar     int
+	Disabled    bool
+}
+
+type SectionStore interface {
+	SaveSections(fingerprint string, sections []PieceSection) error
+	LoadSections(fingerprint string) ([]PieceSection, error)
+}
+
+// prepareSections sets the fingerprint and the position of the sections
+func prepareSections(fingerprint string, sections []PieceSection) []PieceSection {
+	result := make([]PieceSection, len(sections))
+	for i, section := range sections {
+		section.ID = 0
+		section.Fingerprint = fingerprint
+		section.Position = i
+		result[i] = section
+	}
+	return result
+}
+
+type InMemorySectionStore struct {
+	sections map[string][]PieceSection
+}
+
+func NewInMemorySectionStore() *InMemorySectionStore {
+	return &InMemorySectionStore{
+		sections: make(map[string][]PieceSection),
+	}
+}
+
+func (im *InMemorySectionStore) SaveSections(fingerprint string, sections []PieceSection) error {
+	if len(sections) == 0 {
+		delete(im.sections, fingerprint)
+		return nil
+	}
+	im.sections[fingerprint] = prepareSections(fingerprint, sections)
+	return nil
+}
+
+func (im *InMemorySectionStore) LoadSections(fingerprint string) ([]PieceSection, error) {
+	return slices.Clone(im.sections[fingerprint]), nil
+}
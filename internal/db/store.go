@@ -3,16 +3,19 @@ package db
 type Store interface {
 	ProjectStore
 	LibraryList
+	SectionStore
 }
 
 type InMemoryStore struct {
 	InMemoryProjectStore
 	InMemoryLibraryList
+	InMemorySectionStore
 }
 
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
 		InMemoryProjectStore: *NewInMemoryProjectStore(),
 		InMemoryLibraryList:  *NewInMemoryLibraryList(),
+		InMemorySectionStore: *NewInMemorySectionStore(),
 	}
 }
@@ -0,0 +1,293 @@
+package interchange
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+// DefaultFrameRate is the frame rate of edit decision lists when none is given
+const DefaultFrameRate = 24.0
+
+var (
+	ErrInvalidTimecode   = errors.New("timecode must look like 01:00:02:12")
+	ErrInvalidEvent      = errors.New("event must have a number, reel, track, transition and four timecodes")
+	ErrInvalidFrameRate  = errors.New("frame rate must be positive")
+	ErrDropFrameRate     = errors.New("drop frame timecodes require a frame rate of 29.97 or 59.94")
+	ErrRecordOutBeforeIn = errors.New("record out is before record in")
+)
+
+var timecodePattern = regexp.MustCompile(`^(\d{2}):(\d{2}):(\d{2})([:;.,])(\d{2})$`)
+
+// timecodeReader converts timecodes of an edit decision list to time
+type timecodeReader struct {
+	frameRate float64
+
+	// Set by the frame code mode line of the list
+	dropFrame bool
+}
+
+func (t *timecodeReader) nominalRate() int {
+	return int(math.Round(t.frameRate))
+}
+
+// read returns the time of the timecode. Timecodes separating the frames by a
+// semicolon or a period are drop frame, like those following FCM: DROP FRAME.
+func (t *timecodeReader) read(timecode string) (time.Duration, error) {
+	match := timecodePattern.FindStringSubmatch(timecode)
+	if match == nil {
+		return 0, ErrInvalidTimecode
+	}
+	var hours, minutes, seconds, frames int
+	for i, value := range []*int{&hours, &minutes, &seconds} {
+		*value, _ = strconv.Atoi(match[i+1])
+	}
+	frames, _ = strconv.Atoi(match[5])
+
+	nominal := t.nominalRate()
+	if minutes >= 60 || seconds >= 60 || frames >= nominal {
+		return 0, ErrInvalidTimecode
+	}
+
+	rate := t.frameRate
+	count := ((hours*60+minutes)*60+seconds)*nominal + frames
+	if t.dropFrame || match[4] == ";" || match[4] == "." {
+		if nominal != 30 && nominal != 60 {
+			return 0, ErrDropFrameRate
+		}
+
+		// Frame labels are skipped at the start of every minute except every tenth
+		dropped := nominal / 15
+		if seconds == 0 && frames < dropped && minutes%10 != 0 {
+			return 0, ErrInvalidTimecode
+		}
+		totalMinutes := hours*60 + minutes
+		count -= dropped * (totalMinutes - totalMinutes/10)
+		rate = float64(nominal) * 1000 / 1001
+	}
+	return time.Duration(float64(count) / rate * float64(time.Second)), nil
+}
+
+// EDLEvent is a shot on the record timeline
+type EDLEvent struct {
+	Number    string
+	RecordIn  time.Duration
+	RecordOut time.Duration
+	Clip      string
+
+	// Set when the clip name is given by a TO CLIP NAME comment
+	incoming bool
+}
+
+func (e *EDLEvent) name() string {
+	if e.Clip != "" {
+		return e.Clip
+	}
+	return "Event " + e.Number
+}
+
+// isPictureTrack returns true for events on the main video track. Audio and
+// superimposed video tracks do not start new scenes.
+func isPictureTrack(track string) bool {
+	if track == "B" {
+		return true
+	}
+	return slices.Contains(strings.Split(track, "/"), "V")
+}
+
+// ReadEDL reads the video events of a CMX3600 edit decision list. Events
+// without length, such as the outgoing side of a dissolve, are skipped.
+func ReadEDL(r io.Reader, opts ...ImportOpt) ([]EDLEvent, error) {
+	options := newImportOptions(opts...)
+	if options.frameRate <= 0 {
+		return nil, ErrInvalidFrameRate
+	}
+	timecodes := timecodeReader{frameRate: options.frameRate}
+
+	var (
+		events []EDLEvent
+		last   *EDLEvent
+		number int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 0:
+			continue
+		case strings.HasPrefix(line, "FCM:"):
+			mode := strings.ToUpper(line)
+			timecodes.dropFrame = strings.Contains(mode, "DROP") && !strings.Contains(mode, "NON")
+		case strings.HasPrefix(line, "*"):
+			comment := strings.TrimSpace(strings.TrimPrefix(line, "*"))
+			if last == nil {
+				continue
+			}
+			if clip, ok := strings.CutPrefix(comment, "FROM CLIP NAME:"); ok && !last.incoming {
+				last.Clip = strings.TrimSpace(clip)
+			}
+			if clip, ok := strings.CutPrefix(comment, "TO CLIP NAME:"); ok {
+				last.Clip = strings.TrimSpace(clip)
+				last.incoming = true
+			}
+		case isEventNumber(fields[0]):
+			if len(fields) < 8 {
+				return nil, &LineError{Line: number, Err: ErrInvalidEvent}
+			}
+			last = nil
+			if !isPictureTrack(fields[2]) {
+				continue
+			}
+
+			timecodeFields := fields[len(fields)-4:]
+			var times [4]time.Duration
+			for i, field := range timecodeFields {
+				d, err := timecodes.read(field)
+				if err != nil {
+					return nil, &LineError{Line: number, Err: err}
+				}
+				times[i] = d
+			}
+			if times[3] < times[2] {
+				return nil, &LineError{Line: number, Err: ErrRecordOutBeforeIn}
+			}
+			if times[3] == times[2] {
+				continue
+			}
+			events = append(events, EDLEvent{Number: fields[0], RecordIn: times[2], RecordOut: times[3]})
+			last = &events[len(events)-1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func isEventNumber(field string) bool {
+	_, err := strconv.ParseUint(field, 10, 32)
+	return err == nil
+}
+
+// shotGroup is a run of shots that becomes one scene
+type shotGroup struct {
+	start time.Duration
+	end   time.Duration
+	shots []EDLEvent
+}
+
+func (g *shotGroup) length() time.Duration {
+	return g.end - g.start
+}
+
+func (g *shotGroup) description() string {
+	if len(g.shots) == 1 {
+		return g.shots[0].name()
+	}
+	return fmt.Sprintf("%s (%d shots)", g.shots[0].name(), len(g.shots))
+}
+
+// groupShots puts consecutive shots shorter than the minimum length into one
+// group. Groups that are still too short are merged into the group before
+// them, or the one after them at the start of the list.
+func groupShots(shots []shotGroup, minLength time.Duration) []shotGroup {
+	if minLength <= 0 {
+		return shots
+	}
+
+	var groups []shotGroup
+	for _, shot := range shots {
+		n := len(groups)
+		if n > 0 && shot.length() < minLength && groups[n-1].length() < minLength {
+			groups[n-1].end = shot.end
+			groups[n-1].shots = append(groups[n-1].shots, shot.shots...)
+			continue
+		}
+		groups = append(groups, shot)
+	}
+
+	var merged []shotGroup
+	for i, group := range groups {
+		n := len(merged)
+		switch {
+		case group.length() >= minLength:
+			merged = append(merged, group)
+		case n > 0:
+			merged[n-1].end = group.end
+			merged[n-1].shots = append(merged[n-1].shots, group.shots...)
+		case i+1 < len(groups):
+			groups[i+1].start = group.start
+			groups[i+1].shots = slices.Concat(group.shots, groups[i+1].shots)
+		default:
+			merged = append(merged, group)
+		}
+	}
+	return merged
+}
+
+// ScenesFromEDL turns the events into scenes. A shot lasts from its record in
+// until the next shot starts, such that the scenes cover the record timeline.
+// The record in of the first shot of a scene is kept as its timecode.
+// Shots shorter than the minimum shot length are merged into one scene.
+func ScenesFromEDL(events []EDLEvent, opts ...ImportOpt) []db.ProjectContentRecord {
+	if len(events) == 0 {
+		return []db.ProjectContentRecord{}
+	}
+	options := newImportOptions(opts...)
+
+	events = slices.Clone(events)
+	slices.SortStableFunc(events, func(e1, e2 EDLEvent) int { return cmp.Compare(e1.RecordIn, e2.RecordIn) })
+
+	shots := make([]shotGroup, len(events))
+	for i, event := range events {
+		end := event.RecordOut
+		if i+1 < len(events) {
+			end = events[i+1].RecordIn
+		}
+		shots[i] = shotGroup{start: event.RecordIn, end: end, shots: []EDLEvent{event}}
+	}
+
+	// Times are counted from the first record in, since timelines often start at one hour
+	origin := events[0].RecordIn
+	groups := groupShots(shots, options.minShotLength)
+	records := make([]db.ProjectContentRecord, len(groups))
+	for i, group := range groups {
+		records[i] = db.ProjectContentRecord{
+			Scene:       uint(i),
+			SceneDesc:   group.description(),
+			DurationSec: wholeSeconds(group.end-origin) - wholeSeconds(group.start-origin),
+			Timecode:    db.FormatClock(group.start),
+		}
+	}
+	return records
+}
+
+// ImportEDL creates a project with the scenes of a CMX3600 edit decision list.
+// The project is named after the file when no name is given.
+func ImportEDL(store db.ProjectStore, path, name string, opts ...ImportOpt) (*db.Project, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events, err := ReadEDL(file, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return saveImportedProject(store, path, name, ScenesFromEDL(events, opts...))
+}
@@ -0,0 +1,180 @@
+package interchange
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+const edlExample = `TITLE: SUNRISE RESTORATION
+FCM: NON-DROP FRAME
+
+001  AX       V     C        00:00:00:00 00:00:10:00 01:00:00:00 01:00:10:00
+* FROM CLIP NAME: FARM_WIDE.MOV
+002  AX       A     C        00:00:00:00 00:00:20:00 01:00:00:00 01:00:20:00
+003  AX       V     C        00:05:00:00 00:05:00:00 01:00:10:00 01:00:10:00
+003  BX       V     D    024 00:07:00:00 00:07:05:12 01:00:10:00 01:00:15:12
+* FROM CLIP NAME: FARM_WIDE.MOV
+* TO CLIP NAME: CITY_LIGHTS.MOV
+004  AX       V2    C        00:00:00:00 00:00:02:00 01:00:11:00 01:00:13:00
+005  CX       AA/V  C        00:01:00:00 00:01:01:00 01:00:15:12 01:00:16:12
+M2   CX       048.0          00:01:00:00
+`
+
+func TestReadEDL(t *testing.T) {
+	events, err := ReadEDL(strings.NewReader(edlExample))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []EDLEvent{
+		{Number: "001", RecordIn: time.Hour, RecordOut: time.Hour + 10*time.Second, Clip: "FARM_WIDE.MOV"},
+		{Number: "003", RecordIn: time.Hour + 10*time.Second, RecordOut: time.Hour + 15500*time.Millisecond, Clip: "CITY_LIGHTS.MOV", incoming: true},
+		{Number: "005", RecordIn: time.Hour + 15500*time.Millisecond, RecordOut: time.Hour + 16500*time.Millisecond},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("Wanted %+v got %+v", want, events)
+	}
+}
+
+func TestReadTimecode(t *testing.T) {
+	for _, test := range []struct {
+		timecode string
+		rate     float64
+		drop     bool
+		want     time.Duration
+		err      error
+	}{
+		{timecode: "00:00:01:12", rate: 24, want: 1500 * time.Millisecond},
+		{timecode: "00:00:01:12", rate: 25, want: 1480 * time.Millisecond},
+		{timecode: "00:01:00;02", rate: 29.97, want: time.Duration(1800 * float64(time.Second) * 1001 / 30000)},
+		{timecode: "00:10:00:00", rate: 29.97, drop: true, want: time.Duration(17982 * float64(time.Second) * 1001 / 30000)},
+		{timecode: "00:01:00;00", rate: 29.97, err: ErrInvalidTimecode},
+		{timecode: "00:00:01;00", rate: 24, err: ErrDropFrameRate},
+		{timecode: "00:00:01:24", rate: 24, err: ErrInvalidTimecode},
+		{timecode: "0:00:01:00", rate: 24, err: ErrInvalidTimecode},
+	} {
+		reader := timecodeReader{frameRate: test.rate, dropFrame: test.drop}
+		got, err := reader.read(test.timecode)
+		if err != test.err || (err == nil && (got-test.want).Abs() > time.Millisecond) {
+			t.Errorf("Wanted %s (%v) got %s (%v) for %s at %.2f fps", test.want, test.err, got, err, test.timecode, test.rate)
+		}
+	}
+}
+
+func TestDropFrameHourIsRealTime(t *testing.T) {
+	reader := timecodeReader{frameRate: 29.97}
+	got, err := reader.read("01:00:00;00")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if (got - time.Hour).Abs() > 10*time.Millisecond {
+		t.Errorf("Wanted one hour of drop frame timecode to be about an hour got %s", got)
+	}
+}
+
+func TestReadEDLErrorsNameLine(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    error
+		line    int
+	}{
+		{content: "TITLE: X\n001  AX  V  C  00:00:00:00 00:00:01:00\n", want: ErrInvalidEvent, line: 2},
+		{content: "001  AX  V  C  00:00:00:00 00:00:01:00 01:00:00:00 01:00:61:00\n", want: ErrInvalidTimecode, line: 1},
+		{content: "001  AX  V  C  00:00:00:00 00:00:01:00 01:00:05:00 01:00:01:00\n", want: ErrRecordOutBeforeIn, line: 1},
+	} {
+		_, err := ReadEDL(strings.NewReader(test.content))
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) || !errors.Is(err, test.want) || lineErr.Line != test.line {
+			t.Errorf("Wanted %v on line %d got %v", test.want, test.line, err)
+		}
+	}
+
+	if _, err := ReadEDL(strings.NewReader(""), WithFrameRate(0)); err != ErrInvalidFrameRate {
+		t.Errorf("Wanted %v got %v", ErrInvalidFrameRate, err)
+	}
+}
+
+func shots(lengths ...int) []EDLEvent {
+	var events []EDLEvent
+	start := time.Hour
+	for i, length := range lengths {
+		end := start + time.Duration(length)*time.Second
+		events = append(events, EDLEvent{Number: string(rune('A' + i)), RecordIn: start, RecordOut: end})
+		start = end
+	}
+	return events
+}
+
+func TestScenesFromEDL(t *testing.T) {
+	for _, test := range []struct {
+		events    []EDLEvent
+		minLength time.Duration
+		want      []int
+		descs     []string
+		desc      string
+	}{
+		{events: shots(10, 2, 3), want: []int{10, 2, 3}, descs: []string{"Event A", "Event B", "Event C"}, desc: "one scene per shot"},
+		{events: shots(10, 2, 1, 2, 8), minLength: 4 * time.Second, want: []int{10, 5, 8}, descs: []string{"Event A", "Event B (3 shots)", "Event E"}, desc: "short shots grouped"},
+		{events: shots(10, 2, 8), minLength: 4 * time.Second, want: []int{12, 8}, descs: []string{"Event A (2 shots)", "Event C"}, desc: "single short shot merged into previous"},
+		{events: shots(1, 1, 8), minLength: 4 * time.Second, want: []int{10}, descs: []string{"Event A (3 shots)"}, desc: "short opening merged into next"},
+		{events: shots(1), minLength: 4 * time.Second, want: []int{1}, descs: []string{"Event A"}, desc: "only a short shot"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			records := ScenesFromEDL(test.events, WithMinShotLength(test.minLength))
+			if got := durations(records); !slices.Equal(got, test.want) {
+				t.Errorf("Wanted durations %v got %v", test.want, got)
+			}
+			var descs []string
+			for _, record := range records {
+				descs = append(descs, record.SceneDesc)
+			}
+			if !slices.Equal(descs, test.descs) {
+				t.Errorf("Wanted scenes %v got %v", test.descs, descs)
+			}
+		})
+	}
+}
+
+func TestScenesFromEDLCoverGaps(t *testing.T) {
+	events := []EDLEvent{
+		{Number: "2", RecordIn: time.Hour + 20*time.Second, RecordOut: time.Hour + 30*time.Second},
+		{Number: "1", RecordIn: time.Hour, RecordOut: time.Hour + 15*time.Second},
+	}
+	records := ScenesFromEDL(events)
+	if got := durations(records); !slices.Equal(got, []int{20, 10}) {
+		t.Errorf("Wanted the gap to belong to the first shot got %v", got)
+	}
+	if records[1].Timecode != "01:00:20" {
+		t.Errorf("Wanted record in as timecode got %s", records[1].Timecode)
+	}
+}
+
+func TestImportEDL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Sunrise.edl")
+	if err := os.WriteFile(path, []byte(edlExample), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	store := db.NewInMemoryProjectStore()
+	project, err := ImportFile(store, path, "", WithMinShotLength(2*time.Second))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []string{"FARM_WIDE.MOV", "CITY_LIGHTS.MOV (2 shots)"}
+	var descs []string
+	for _, record := range project.Records {
+		descs = append(descs, record.SceneDesc)
+	}
+	if project.Name != "Sunrise" || !slices.Equal(descs, want) {
+		t.Errorf("Wanted project Sunrise with scenes %v got %s with %v", want, project.Name, descs)
+	}
+}
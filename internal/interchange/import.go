@@ -0,0 +1,61 @@
+package interchange
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+type importOptions struct {
+	gap           time.Duration
+	frameRate     float64
+	minShotLength time.Duration
+}
+
+type ImportOpt func(o *importOptions)
+
+func newImportOptions(opts ...ImportOpt) importOptions {
+	options := importOptions{gap: DefaultSubtitleGap, frameRate: DefaultFrameRate}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithGapThreshold sets the longest silence between two subtitles that is
+// merged into the scene before it. Longer silences become scenes of their own.
+func WithGapThreshold(gap time.Duration) ImportOpt {
+	return func(o *importOptions) {
+		o.gap = gap
+	}
+}
+
+// WithFrameRate sets the frame rate of the timecodes in edit decision lists,
+// for example 24, 25 or 29.97
+func WithFrameRate(fps float64) ImportOpt {
+	return func(o *importOptions) {
+		o.frameRate = fps
+	}
+}
+
+// WithMinShotLength merges shots of edit decision lists that are shorter than
+// the given length into one scene. Zero gives one scene per shot.
+func WithMinShotLength(length time.Duration) ImportOpt {
+	return func(o *importOptions) {
+		o.minShotLength = length
+	}
+}
+
+// ImportFile creates a project from a subtitle file, an edit decision list or
+// a scene table
+func ImportFile(store db.ProjectStore, path, name string, opts ...ImportOpt) (*db.Project, error) {
+	switch {
+	case IsSubtitleFile(path):
+		return ImportSubtitles(store, path, name, opts...)
+	case strings.EqualFold(filepath.Ext(path), ".edl"):
+		return ImportEDL(store, path, name, opts...)
+	}
+	return ImportProject(store, path, name)
+}
@@ -0,0 +1,95 @@
+package interchange
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+// ReadTableFile reads the scenes of a .csv or .tsv file
+func ReadTableFile(path string) ([]db.ProjectContentRecord, error) {
+	format, err := TableFormatFromFileName(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadRecords(file, format)
+}
+
+// WriteTableFile writes the scenes to a .csv or .tsv file
+func WriteTableFile(path string, records []db.ProjectContentRecord) error {
+	format, err := TableFormatFromFileName(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return WriteRecords(file, format, records)
+}
+
+// ProjectNameFromFileName returns the file name without directory and extension
+func ProjectNameFromFileName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// ImportProject creates a project with the scenes of the file. The project is
+// named after the file when no name is given.
+func ImportProject(store db.ProjectStore, path, name string) (*db.Project, error) {
+	records, err := ReadTableFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return saveImportedProject(store, path, name, records)
+}
+
+// saveImportedProject saves a new project with the scenes read from the file.
+// The project is named after the file when no name is given.
+func saveImportedProject(store db.ProjectStore, path, name string, records []db.ProjectContentRecord) (*db.Project, error) {
+	if name == "" {
+		name = ProjectNameFromFileName(path)
+	}
+	project := db.NewProject(db.WithName(name), db.WithRecords(records))
+	project.SyncReels()
+	if err := store.Save(project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// FindProject returns the project with the given name
+func FindProject(store db.ProjectStore, name string) (*db.Project, error) {
+	projects, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		if project.Name == name {
+			return &project, nil
+		}
+	}
+	return nil, fmt.Errorf("no project named %q", name)
+}
+
+// ExportProject writes the scenes of the project with the given name to the file
+func ExportProject(store db.ProjectStore, name, path string) error {
+	project, err := FindProject(store, name)
+	if err != nil {
+		return err
+	}
+	records := slices.Clone(project.Records)
+	slices.SortStableFunc(records, func(r1, r2 db.ProjectContentRecord) int { return cmp.Compare(r1.Scene, r2.Scene) })
+	return WriteTableFile(path, records)
+}
@@ -0,0 +1,84 @@
+package interchange
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+func TestImportExportProject(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "The Kid.csv")
+	content := "Scene description,Tempo,Keywords,Theme,Duration,Intensity,Reel,Start\n" +
+		"Opening,,waltz,,30,,,\n" +
+		"Chase,120,galop,1,45,5,2,01:00:30\n"
+	if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	store := db.NewInMemoryProjectStore()
+	project, err := ImportProject(store, source, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if project.Name != "The Kid" || len(project.Records) != 2 || len(project.Reels) != 2 {
+		t.Errorf("Wanted project The Kid with two scenes in two reels got %+v", project)
+	}
+
+	target := filepath.Join(dir, "exported.csv")
+	if err := ExportProject(store, "The Kid", target); err != nil {
+		t.Error(err)
+		return
+	}
+	exported, err := os.ReadFile(target)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(exported) != content {
+		t.Errorf("Wanted %q got %q", content, exported)
+	}
+}
+
+func TestExportUnknownProject(t *testing.T) {
+	if err := ExportProject(db.NewInMemoryProjectStore(), "missing", filepath.Join(t.TempDir(), "out.csv")); err == nil {
+		t.Errorf("Wanted error for unknown project")
+	}
+}
+
+func TestImportRejectsUnknownFormat(t *testing.T) {
+	if _, err := ImportProject(db.NewInMemoryProjectStore(), "scenes.xlsx", ""); err != ErrUnknownTableFormat {
+		t.Errorf("Wanted %v got %v", ErrUnknownTableFormat, err)
+	}
+}
+
+func TestExportSortsByScene(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := db.NewProject(db.WithName("p"), db.WithRecords([]db.ProjectContentRecord{{Scene: 1, SceneDesc: "b"}, {Scene: 0, SceneDesc: "a"}}))
+	if err := store.Save(project); err != nil {
+		t.Error(err)
+		return
+	}
+	target := filepath.Join(t.TempDir(), "p.tsv")
+	if err := ExportProject(store, "p", target); err != nil {
+		t.Error(err)
+		return
+	}
+	records, err := ReadTableFile(target)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var descs []string
+	for _, record := range records {
+		descs = append(descs, record.SceneDesc)
+	}
+	if !slices.Equal(descs, []string{"a", "b"}) {
+		t.Errorf("Wanted scenes in order got %v", descs)
+	}
+}
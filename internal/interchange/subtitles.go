@@ -0,0 +1,253 @@
+package interchange
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+// DefaultSubtitleGap is the longest silence between two cues that is merged
+// into the scene before it
+const DefaultSubtitleGap = 2 * time.Second
+
+// Keyword given to scenes made from subtitle cues. Cards without other keywords
+// continue the music of the scene before them.
+const subtitleKeyword = "intertitle"
+
+var (
+	ErrInvalidCueTiming = errors.New("cue timing must look like 00:01:02,500 --> 00:01:05,000")
+	ErrCueEndsEarly     = errors.New("cue ends before it starts")
+)
+
+// LineError reports an invalid line in a subtitle file
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// SubtitleCue is a text shown from start to end
+type SubtitleCue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// IsSubtitleFile returns true if the file is an SRT or WebVTT file
+func IsSubtitleFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".srt", ".vtt":
+		return true
+	}
+	return false
+}
+
+var (
+	timestampPattern = `(?:(\d+):)?(\d{1,2}):(\d{2})[,.](\d{1,3})`
+	timingPattern    = regexp.MustCompile(`^\s*` + timestampPattern + `\s*-->\s*` + timestampPattern + `(?:\s.*)?$`)
+	markupPattern    = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+)
+
+// timestamp converts the hours, minutes, seconds and milliseconds matched by
+// timestampPattern
+func timestamp(parts []string) (time.Duration, bool) {
+	var values [4]int
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		values[i] = value
+	}
+	if values[1] >= 60 || values[2] >= 60 {
+		return 0, false
+	}
+
+	// One and two digit fractions are tenths and hundredths
+	millis := values[3]
+	for n := len(parts[3]); n < 3; n++ {
+		millis *= 10
+	}
+	d := time.Duration(values[0])*time.Hour + time.Duration(values[1])*time.Minute + time.Duration(values[2])*time.Second
+	return d + time.Duration(millis)*time.Millisecond, true
+}
+
+func parseTiming(line string) (time.Duration, time.Duration, error) {
+	match := timingPattern.FindStringSubmatch(line)
+	if match == nil {
+		return 0, 0, ErrInvalidCueTiming
+	}
+	start, ok := timestamp(match[1:5])
+	if !ok {
+		return 0, 0, ErrInvalidCueTiming
+	}
+	end, ok := timestamp(match[5:9])
+	if !ok {
+		return 0, 0, ErrInvalidCueTiming
+	}
+	if end < start {
+		return 0, 0, ErrCueEndsEarly
+	}
+	return start, end, nil
+}
+
+// cueText joins the lines of a cue and removes formatting tags
+func cueText(lines []string) string {
+	text := markupPattern.ReplaceAllString(strings.Join(lines, " "), "")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// ReadSubtitles reads the cues of an SRT or WebVTT file. Lines before the
+// timing line of a cue, such as cue numbers, are ignored, and blocks without a
+// timing line, such as the WebVTT header and notes, are skipped.
+func ReadSubtitles(r io.Reader) ([]SubtitleCue, error) {
+	scanner := bufio.NewScanner(r)
+	var (
+		cues    []SubtitleCue
+		current *SubtitleCue
+		text    []string
+		number  int
+	)
+	finishBlock := func() {
+		if current != nil {
+			current.Text = cueText(text)
+			cues = append(cues, *current)
+		}
+		current = nil
+		text = nil
+	}
+
+	for scanner.Scan() {
+		number++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if number == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			finishBlock()
+		case current != nil:
+			text = append(text, line)
+		case strings.Contains(line, "-->"):
+			start, end, err := parseTiming(line)
+			if err != nil {
+				return nil, &LineError{Line: number, Err: err}
+			}
+			current = &SubtitleCue{Start: start, End: end}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	finishBlock()
+	return cues, nil
+}
+
+// scene is a part of the film between two boundaries
+type scene struct {
+	start    time.Duration
+	end      time.Duration
+	text     string
+	subtitle bool
+}
+
+func wholeSeconds(d time.Duration) int {
+	return int(d.Round(time.Second).Seconds())
+}
+
+// ScenesFromSubtitles turns the cues into scenes. Every cue starts a scene
+// holding its text, which lasts until the next cue when the silence between
+// them is no longer than the gap threshold. Longer silences, including the
+// one before the first cue, become scenes without text. Cues starting in the
+// same second as the previous scene are merged into it.
+func ScenesFromSubtitles(cues []SubtitleCue, opts ...ImportOpt) []db.ProjectContentRecord {
+	options := newImportOptions(opts...)
+
+	cues = slices.Clone(cues)
+	slices.SortStableFunc(cues, func(c1, c2 SubtitleCue) int { return cmp.Compare(c1.Start, c2.Start) })
+
+	var scenes []scene
+	for i, cue := range cues {
+		n := len(scenes)
+		switch {
+		case n > 0 && scenes[n-1].subtitle && wholeSeconds(cue.Start) <= wholeSeconds(scenes[n-1].start):
+			scenes[n-1].text = strings.TrimSpace(scenes[n-1].text + " " + cue.Text)
+			scenes[n-1].end = max(scenes[n-1].end, cue.End)
+		case n == 0 && cue.Start > options.gap:
+			scenes = append(scenes, scene{start: 0, end: cue.Start}, scene{start: cue.Start, end: cue.End, text: cue.Text, subtitle: true})
+		case n == 0:
+			scenes = append(scenes, scene{start: 0, end: cue.End, text: cue.Text, subtitle: true})
+		default:
+			scenes = append(scenes, scene{start: cue.Start, end: cue.End, text: cue.Text, subtitle: true})
+		}
+
+		if i+1 < len(cues) {
+			next := cues[i+1].Start
+			last := &scenes[len(scenes)-1]
+			if next-last.end > options.gap {
+				scenes = append(scenes, scene{start: last.end, end: next})
+			} else {
+				last.end = next
+			}
+		}
+	}
+
+	// Durations are taken between rounded boundaries such that the scenes add
+	// up to the length of the film
+	records := make([]db.ProjectContentRecord, 0, len(scenes))
+	for i, s := range scenes {
+		end := s.end
+		if i+1 < len(scenes) {
+			end = scenes[i+1].start
+		}
+		record := db.ProjectContentRecord{
+			Scene:       uint(i),
+			SceneDesc:   s.text,
+			DurationSec: wholeSeconds(end) - wholeSeconds(s.start),
+		}
+		if s.subtitle {
+			record.Keywords = subtitleKeyword
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ImportSubtitles creates a project with a scene for every cue of an SRT or
+// WebVTT file. The project is named after the file when no name is given.
+func ImportSubtitles(store db.ProjectStore, path, name string, opts ...ImportOpt) (*db.Project, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cues, err := ReadSubtitles(file)
+	if err != nil {
+		return nil, err
+	}
+	return saveImportedProject(store, path, name, ScenesFromSubtitles(cues, opts...))
+}
@@ -0,0 +1,223 @@
+package interchange
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+const srtExample = "1\r\n" +
+	"00:00:01,000 --> 00:00:04,500\r\n" +
+	"<i>The year is 1921.</i>\r\n" +
+	"\r\n" +
+	"2\r\n" +
+	"00:00:05,200 --> 00:00:08,000\r\n" +
+	"Came the dawn...\r\n" +
+	"and with it, hope.\r\n"
+
+const vttExample = "WEBVTT - Restored edition\n" +
+	"\n" +
+	"NOTE Intertitles translated from the German release\n" +
+	"\n" +
+	"title-card-1\n" +
+	"00:01.000 --> 00:04.500 align:center\n" +
+	"The year is 1921.\n" +
+	"\n" +
+	"01:00:05.2 --> 01:00:08.000\n" +
+	"Tom &amp; Jerry\n"
+
+func TestReadSubtitles(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    []SubtitleCue
+		desc    string
+	}{
+		{
+			content: srtExample,
+			want: []SubtitleCue{
+				{Start: time.Second, End: 4500 * time.Millisecond, Text: "The year is 1921."},
+				{Start: 5200 * time.Millisecond, End: 8 * time.Second, Text: "Came the dawn... and with it, hope."},
+			},
+			desc: "SRT with tags and windows line endings",
+		},
+		{
+			content: vttExample,
+			want: []SubtitleCue{
+				{Start: time.Second, End: 4500 * time.Millisecond, Text: "The year is 1921."},
+				{Start: time.Hour + 5200*time.Millisecond, End: time.Hour + 8*time.Second, Text: "Tom & Jerry"},
+			},
+			desc: "WebVTT with header, note, identifier and settings",
+		},
+		{
+			content: "\ufeff1\n00:00:01,000 --> 00:00:02,000\n{\\an8}Top\n",
+			want:    []SubtitleCue{{Start: time.Second, End: 2 * time.Second, Text: "Top"}},
+			desc:    "byte order mark and position tag",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := ReadSubtitles(strings.NewReader(test.content))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Wanted %+v got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestReadSubtitlesErrorsNameLine(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		want    error
+		line    int
+	}{
+		{content: "1\n00:00:01,000 --> soon\nText\n", want: ErrInvalidCueTiming, line: 2},
+		{content: "1\n00:00:01,000 --> 00:00:02,000\nA\n\n2\n00:00:05,000 --> 00:00:04,000\nB\n", want: ErrCueEndsEarly, line: 6},
+		{content: "1\n00:00:75,000 --> 00:00:80,000\nA\n", want: ErrInvalidCueTiming, line: 2},
+	} {
+		_, err := ReadSubtitles(strings.NewReader(test.content))
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) || !errors.Is(err, test.want) || lineErr.Line != test.line {
+			t.Errorf("Wanted %v on line %d got %v", test.want, test.line, err)
+		}
+	}
+}
+
+func durations(records []db.ProjectContentRecord) []int {
+	result := make([]int, len(records))
+	for i, record := range records {
+		result[i] = record.DurationSec
+	}
+	return result
+}
+
+func TestScenesFromSubtitles(t *testing.T) {
+	cues := []SubtitleCue{
+		{Start: 10 * time.Second, End: 14 * time.Second, Text: "A"},
+		{Start: 15 * time.Second, End: 18 * time.Second, Text: "B"},
+		{Start: 30 * time.Second, End: 33 * time.Second, Text: "C"},
+	}
+	for _, test := range []struct {
+		gap   time.Duration
+		want  []int
+		descs []string
+		desc  string
+	}{
+		{gap: 2 * time.Second, want: []int{10, 5, 3, 12, 3}, descs: []string{"", "A", "B", "", "C"}, desc: "long gaps become scenes"},
+		{gap: 15 * time.Second, want: []int{15, 15, 3}, descs: []string{"A", "B", "C"}, desc: "all gaps merged"},
+		{gap: 0, want: []int{10, 4, 1, 3, 12, 3}, descs: []string{"", "A", "", "B", "", "C"}, desc: "no gaps merged"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			records := ScenesFromSubtitles(cues, WithGapThreshold(test.gap))
+			if got := durations(records); !slices.Equal(got, test.want) {
+				t.Errorf("Wanted durations %v got %v", test.want, got)
+			}
+			var descs []string
+			for i, record := range records {
+				descs = append(descs, record.SceneDesc)
+				if record.Scene != uint(i) {
+					t.Errorf("Wanted scene %d got %d", i, record.Scene)
+				}
+				if (record.SceneDesc != "") != (record.Keywords == subtitleKeyword) {
+					t.Errorf("Wanted subtitle scenes to be intertitles got %+v", record)
+				}
+			}
+			if !slices.Equal(descs, test.descs) {
+				t.Errorf("Wanted scenes %v got %v", test.descs, descs)
+			}
+		})
+	}
+}
+
+func TestScenesFromSubtitlesRoundingAddsUp(t *testing.T) {
+	cues := []SubtitleCue{
+		{Start: 400 * time.Millisecond, End: 2600 * time.Millisecond, Text: "A"},
+		{Start: 2600 * time.Millisecond, End: 5400 * time.Millisecond, Text: "B"},
+		{Start: 5400 * time.Millisecond, End: 7600 * time.Millisecond, Text: "C"},
+	}
+	total := 0
+	for _, d := range durations(ScenesFromSubtitles(cues)) {
+		total += d
+	}
+	if total != 8 {
+		t.Errorf("Wanted scenes to add up to 8s got %d", total)
+	}
+}
+
+func TestScenesFromSubtitlesMergesCuesInSameSecond(t *testing.T) {
+	cues := []SubtitleCue{
+		{Start: 0, End: 3 * time.Second, Text: "First line"},
+		{Start: 200 * time.Millisecond, End: 3 * time.Second, Text: "second line"},
+		{Start: 3 * time.Second, End: 6 * time.Second, Text: "Next"},
+	}
+	records := ScenesFromSubtitles(cues)
+	if len(records) != 2 || records[0].SceneDesc != "First line second line" || records[0].DurationSec != 3 {
+		t.Errorf("Wanted the first two cues merged got %+v", records)
+	}
+}
+
+func TestImportFileDispatchesOnExtension(t *testing.T) {
+	dir := t.TempDir()
+	subtitles := filepath.Join(dir, "Metropolis.srt")
+	if err := os.WriteFile(subtitles, []byte(srtExample), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	store := db.NewInMemoryProjectStore()
+	project, err := ImportFile(store, subtitles, "", WithGapThreshold(time.Second))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if project.Name != "Metropolis" || len(project.Records) != 2 {
+		t.Errorf("Wanted project Metropolis with two scenes got %+v", project)
+	}
+
+	table := filepath.Join(dir, "scenes.csv")
+	if err := os.WriteFile(table, []byte("Description\nOpening\n"), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	if project, err = ImportFile(store, table, "Table"); err != nil || len(project.Records) != 1 {
+		t.Errorf("Wanted the table imported got %v", err)
+	}
+}
+
+func TestComposeImportedSubtitles(t *testing.T) {
+	// Four cards in a row, a long silence and two more cards
+	srt := "1\n00:00:00,000 --> 00:00:03,000\nThe year is 1921.\n\n" +
+		"2\n00:00:04,000 --> 00:00:07,000\nCame the dawn...\n\n" +
+		"3\n00:00:08,000 --> 00:00:11,000\nand with it, hope.\n\n" +
+		"4\n00:00:12,000 --> 00:00:15,000\nThe village woke.\n\n" +
+		"5\n00:01:00,000 --> 00:01:03,000\nMeanwhile, in the city...\n\n" +
+		"6\n00:01:04,000 --> 00:01:07,000\nThe train was late.\n"
+	cues, err := ReadSubtitles(strings.NewReader(srt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := db.NewProject(db.WithName("film"), db.WithRecords(ScenesFromSubtitles(cues)))
+
+	composition := compose.CreateComposition(compose.NewStandardLibrary(), project)
+
+	// The cards continue the music of the scene before them, such that only
+	// the first card and the silence get a cue of their own
+	var scenes []string
+	for _, cue := range composition.CueSheet.Cues {
+		scenes = append(scenes, cue.Scene)
+	}
+	if want := []string{"The year is 1921.", ""}; !slices.Equal(scenes, want) {
+		t.Errorf("Wanted cues for %q got %q", want, scenes)
+	}
+	if len(scenes) == 2 && (composition.CueSheet.Cues[0].End != 15*time.Second || composition.CueSheet.Cues[1].End != 67*time.Second) {
+		t.Errorf("Wanted the cues to last through the cards got %+v", composition.CueSheet.Cues)
+	}
+}
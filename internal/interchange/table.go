@@ -0,0 +1,336 @@
+package interchange
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+// TableFormat is the format of a scene table
+type TableFormat int
+
+const (
+	FormatCSV TableFormat = iota
+	FormatTSV
+)
+
+func (f TableFormat) String() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatTSV:
+		return "tsv"
+	}
+	return ""
+}
+
+func (f TableFormat) delimiter() rune {
+	if f == FormatTSV {
+		return '\t'
+	}
+	return ','
+}
+
+var ErrUnknownTableFormat = errors.New("scene tables must be .csv or .tsv files")
+
+// TableFormatFromFileName returns the format given by the file extension
+func TableFormatFromFileName(name string) (TableFormat, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".tsv", ".tab":
+		return FormatTSV, nil
+	}
+	return FormatCSV, ErrUnknownTableFormat
+}
+
+// Columns of the table in the order they are written
+const (
+	columnSceneDesc = "Scene description"
+	columnTempo     = "Tempo"
+	columnKeywords  = "Keywords"
+	columnTheme     = "Theme"
+	columnDuration  = "Duration"
+	columnIntensity = "Intensity"
+	columnReel      = "Reel"
+	columnStart     = "Start"
+
+	// End of the scene, used for the duration when the table has none
+	columnEnd = "End"
+
+	// Scene number. Scenes are numbered in the order of the rows, so the
+	// column is accepted but not read.
+	columnSceneNumber = "Scene number"
+)
+
+var tableColumns = []string{columnSceneDesc, columnTempo, columnKeywords, columnTheme, columnDuration, columnIntensity, columnReel, columnStart}
+
+// Header names accepted for each column. Headers are compared case-insensitively.
+var headerAliases = map[string]string{
+	"scene description": columnSceneDesc,
+	"scene desc":        columnSceneDesc,
+	"description":       columnSceneDesc,
+	"scene":             columnSceneNumber,
+	"scene number":      columnSceneNumber,
+	"scene no":          columnSceneNumber,
+	"#":                 columnSceneNumber,
+	"tempo":             columnTempo,
+	"bpm":               columnTempo,
+	"keywords":          columnKeywords,
+	"music":             columnKeywords,
+	"theme":             columnTheme,
+	"duration":          columnDuration,
+	"duration (sec)":    columnDuration,
+	"seconds":           columnDuration,
+	"intensity":         columnIntensity,
+	"reel":              columnReel,
+	"start":             columnStart,
+	"in":                columnStart,
+	"timecode":          columnStart,
+	"end":               columnEnd,
+	"out":               columnEnd,
+}
+
+var (
+	ErrNoKnownColumns   = errors.New("the header has no known columns")
+	ErrNotAnInteger     = errors.New("must be a whole number")
+	ErrNotADuration     = errors.New("must be seconds or a timecode like 1:30")
+	ErrIntensityRange   = errors.New("must be from 1 to 5")
+	ErrReelNotPositive  = errors.New("must be a positive number")
+	ErrEndBeforeStart   = errors.New("must not be before the start")
+	ErrDuplicatedColumn = errors.New("appears more than once")
+)
+
+// CellError reports an invalid value in the table. Rows are numbered as in a
+// spreadsheet, such that the header is row 1.
+type CellError struct {
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e *CellError) Error() string {
+	return fmt.Sprintf("row %d, column %s: %s", e.Row, e.Column, e.Err)
+}
+
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// columnIndices maps the columns to their position in the header
+func columnIndices(header []string) (map[string]int, error) {
+	indices := make(map[string]int)
+	for i, name := range header {
+		column, ok := headerAliases[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			slog.Warn("Ignoring unknown column in scene table", "column", name)
+			continue
+		}
+		if _, exists := indices[column]; exists {
+			return nil, &CellError{Row: 1, Column: name, Err: ErrDuplicatedColumn}
+		}
+		indices[column] = i
+	}
+	if _, numbered := indices[columnSceneNumber]; len(indices) == 0 || (numbered && len(indices) == 1) {
+		return nil, ErrNoKnownColumns
+	}
+	return indices, nil
+}
+
+type tableRow struct {
+	cells   []string
+	number  int
+	indices map[string]int
+}
+
+// value returns the cell of the column as written. Text is not trimmed such
+// that it reads back exactly as it was written.
+func (r *tableRow) value(column string) string {
+	i, ok := r.indices[column]
+	if !ok || i >= len(r.cells) {
+		return ""
+	}
+	return r.cells[i]
+}
+
+func (r *tableRow) trimmed(column string) string {
+	return strings.TrimSpace(r.value(column))
+}
+
+func (r *tableRow) uint(column string) (uint, error) {
+	value := r.trimmed(column)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, &CellError{Row: r.number, Column: column, Err: ErrNotAnInteger}
+	}
+	return uint(number), nil
+}
+
+func (r *tableRow) clock(column string) (time.Duration, bool, error) {
+	value := r.trimmed(column)
+	if value == "" {
+		return 0, false, nil
+	}
+	d, err := db.ParseClock(value)
+	if err != nil {
+		return 0, false, &CellError{Row: r.number, Column: column, Err: ErrNotADuration}
+	}
+	return d, true, nil
+}
+
+func (r *tableRow) record() (db.ProjectContentRecord, error) {
+	record := db.ProjectContentRecord{
+		SceneDesc: r.value(columnSceneDesc),
+		Keywords:  r.value(columnKeywords),
+	}
+
+	var err error
+	if record.Tempo, err = r.uint(columnTempo); err != nil {
+		return record, err
+	}
+	if record.Theme, err = r.uint(columnTheme); err != nil {
+		return record, err
+	}
+	if record.Intensity, err = r.uint(columnIntensity); err != nil {
+		return record, err
+	}
+	if record.Intensity > 5 {
+		return record, &CellError{Row: r.number, Column: columnIntensity, Err: ErrIntensityRange}
+	}
+	if record.Reel, err = r.uint(columnReel); err != nil {
+		return record, err
+	}
+	if r.trimmed(columnReel) != "" && record.Reel == 0 {
+		return record, &CellError{Row: r.number, Column: columnReel, Err: ErrReelNotPositive}
+	}
+
+	duration, ok, err := r.clock(columnDuration)
+	if err != nil {
+		return record, err
+	}
+	if ok {
+		record.DurationSec = int(duration.Round(time.Second).Seconds())
+	}
+
+	if _, ok, err = r.clock(columnStart); err != nil {
+		return record, err
+	} else if ok {
+		record.Timecode = r.trimmed(columnStart)
+	}
+	return record, nil
+}
+
+// ReadRecords reads scenes from a table with a header row. The duration is
+// given in seconds or as a clock time. The start of a scene is kept as its
+// timecode. Tables without a duration column may give the end of each scene
+// instead, and a scene without an end lasts until the next scene starts.
+func ReadRecords(r io.Reader, format TableFormat) ([]db.ProjectContentRecord, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = format.delimiter()
+	reader.FieldsPerRecord = -1
+	if format == FormatTSV {
+		reader.LazyQuotes = true
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return []db.ProjectContentRecord{}, nil
+	}
+
+	indices, err := columnIndices(rows[0])
+	if err != nil {
+		return nil, err
+	}
+	_, hasDuration := indices[columnDuration]
+
+	records := make([]db.ProjectContentRecord, 0, len(rows)-1)
+	starts := make([]time.Duration, 0, len(rows)-1)
+	openEnded := make([]bool, 0, len(rows)-1)
+	for i, cells := range rows[1:] {
+		row := tableRow{cells: cells, number: i + 2, indices: indices}
+		record, err := row.record()
+		if err != nil {
+			return nil, err
+		}
+		record.Scene = uint(i)
+
+		if !hasDuration {
+			start, hasStart, err := row.clock(columnStart)
+			if err != nil {
+				return nil, err
+			}
+			end, hasEnd, err := row.clock(columnEnd)
+			if err != nil {
+				return nil, err
+			}
+			if hasStart && hasEnd {
+				if end < start {
+					return nil, &CellError{Row: row.number, Column: columnEnd, Err: ErrEndBeforeStart}
+				}
+				record.DurationSec = int((end - start).Round(time.Second).Seconds())
+			}
+			if !hasStart {
+				start = -1
+			}
+			starts = append(starts, start)
+			openEnded = append(openEnded, hasStart && !hasEnd)
+		}
+		records = append(records, record)
+	}
+
+	// Scenes with only a start last until the next scene
+	for i := 0; i+1 < len(starts); i++ {
+		if openEnded[i] && starts[i+1] > starts[i] {
+			records[i].DurationSec = int((starts[i+1] - starts[i]).Round(time.Second).Seconds())
+		}
+	}
+	return records, nil
+}
+
+func optionalUint(value uint) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(value), 10)
+}
+
+// WriteRecords writes the scenes as a table with a header row. Reading the
+// table back gives the same scenes.
+func WriteRecords(w io.Writer, format TableFormat, records []db.ProjectContentRecord) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = format.delimiter()
+	if err := writer.Write(tableColumns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{
+			record.SceneDesc,
+			optionalUint(record.Tempo),
+			record.Keywords,
+			optionalUint(record.Theme),
+			strconv.Itoa(record.DurationSec),
+			optionalUint(record.Intensity),
+			optionalUint(record.Reel),
+			record.Timecode,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,150 @@
+package interchange
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/test"
+	"pgregory.net/rapid"
+)
+
+func TestTableRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		format := rapid.SampledFrom([]TableFormat{FormatCSV, FormatTSV}).Draw(t, "format")
+		records := rapid.SliceOfN(rapid.Custom(func(t *rapid.T) db.ProjectContentRecord {
+			record := test.GenerateProjectContentRecord(t, 0)
+			record.SceneDesc = rapid.StringMatching(`[a-zA-Z0-9 ,;"\t]*`).Draw(t, "desc")
+
+			// Pinned pieces are kept in the project, not in scene tables
+			record.Pinned = ""
+			return record
+		}), 0, 10).Draw(t, "records")
+		for i := range records {
+			records[i].Scene = uint(i)
+		}
+
+		var buffer bytes.Buffer
+		if err := WriteRecords(&buffer, format, records); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadRecords(&buffer, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, records) {
+			t.Fatalf("Wanted %+v got %+v", records, got)
+		}
+	})
+}
+
+func TestReadRecordsHeaderAliases(t *testing.T) {
+	table := "Description\tBPM\tMusic\tSeconds\tNotes\n" +
+		"Opening titles\t90\twaltz\t1:30\tignored\n" +
+		"Chase\t\tgalop\t45\t\n"
+	got, err := ReadRecords(strings.NewReader(table), FormatTSV)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []db.ProjectContentRecord{
+		{Scene: 0, SceneDesc: "Opening titles", Tempo: 90, Keywords: "waltz", DurationSec: 90},
+		{Scene: 1, SceneDesc: "Chase", Keywords: "galop", DurationSec: 45},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %+v got %+v", want, got)
+	}
+}
+
+func TestReadRecordsIgnoresSceneNumbers(t *testing.T) {
+	table := "Scene,Description,Duration\n" +
+		"12,Opening titles,30\n" +
+		"14,Chase,45\n"
+	got, err := ReadRecords(strings.NewReader(table), FormatCSV)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := []db.ProjectContentRecord{
+		{Scene: 0, SceneDesc: "Opening titles", DurationSec: 30},
+		{Scene: 1, SceneDesc: "Chase", DurationSec: 45},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Wanted %+v got %+v", want, got)
+	}
+}
+
+func TestReadRecordsFromTimecodes(t *testing.T) {
+	table := "Description,Start,End\n" +
+		"Opening,00:00:00,\n" +
+		"Chase,00:01:10,00:02:00\n" +
+		"Finale,00:03:00,\n"
+	got, err := ReadRecords(strings.NewReader(table), FormatCSV)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want := []int{70, 50, 0}; !slices.Equal(durations(got), want) {
+		t.Errorf("Wanted durations %v got %v", want, durations(got))
+	}
+	if got[1].Timecode != "00:01:10" {
+		t.Errorf("Wanted the start kept as timecode got %s", got[1].Timecode)
+	}
+}
+
+func TestReadRecordsErrorsNameRowAndColumn(t *testing.T) {
+	for _, test := range []struct {
+		table string
+		want  error
+		row   int
+		col   string
+		desc  string
+	}{
+		{table: "Description,Tempo\nOpening,90\nChase,fast\n", want: ErrNotAnInteger, row: 3, col: "Tempo", desc: "tempo not a number"},
+		{table: "Description,Duration\nOpening,1:75\n", want: ErrNotADuration, row: 2, col: "Duration", desc: "invalid clock time"},
+		{table: "Description,Intensity\nOpening,6\n", want: ErrIntensityRange, row: 2, col: "Intensity", desc: "intensity out of range"},
+		{table: "Description,Reel\nOpening,0\n", want: ErrReelNotPositive, row: 2, col: "Reel", desc: "reel zero"},
+		{table: "Description,Start,End\nOpening,1:00,0:30\n", want: ErrEndBeforeStart, row: 2, col: "End", desc: "end before start"},
+		{table: "Description,Scene description\nOpening,Titles\n", want: ErrDuplicatedColumn, row: 1, col: "Scene description", desc: "duplicated column"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := ReadRecords(strings.NewReader(test.table), FormatCSV)
+			var cellErr *CellError
+			if !errors.As(err, &cellErr) || !errors.Is(err, test.want) {
+				t.Errorf("Wanted %v got %v", test.want, err)
+				return
+			}
+			if cellErr.Row != test.row || cellErr.Column != test.col {
+				t.Errorf("Wanted row %d column %s got %s", test.row, test.col, err)
+			}
+		})
+	}
+}
+
+func TestReadRecordsWithoutKnownColumns(t *testing.T) {
+	for _, table := range []string{"Shot,Lens\n1,50mm\n", "Scene,Lens\n1,50mm\n"} {
+		if _, err := ReadRecords(strings.NewReader(table), FormatCSV); !errors.Is(err, ErrNoKnownColumns) {
+			t.Errorf("Wanted %v got %v", ErrNoKnownColumns, err)
+		}
+	}
+}
+
+func TestTableFormatFromFileName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want TableFormat
+		err  error
+	}{
+		{name: "scenes.csv", want: FormatCSV},
+		{name: "Scenes.TSV", want: FormatTSV},
+		{name: "scenes.xlsx", want: FormatCSV, err: ErrUnknownTableFormat},
+	} {
+		got, err := TableFormatFromFileName(test.name)
+		if got != test.want || err != test.err {
+			t.Errorf("Wanted %s (%v) got %s (%v)", test.want, test.err, got, err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package musicxml
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	ModeMajor Mode = "major"
+	ModeMinor Mode = "minor"
+)
+
+// Analysis holds musical features of a piece
+type Analysis struct {
+	// Estimated key as the number of fifths of its key signature and the mode
+	Fifths int
+	Mode   Mode
+
+	// Lowest and highest pitch as MIDI numbers. Both are zero for pieces without pitched notes
+	LowestPitch  int
+	HighestPitch int
+
+	// Number of notes started per beat in the first part
+	NotesPerBeat float64
+
+	// Most frequent dynamics marking. Empty if the piece has no dynamics
+	Dynamics string
+
+	// First time signature. Zero if the piece has no time signature
+	Time Timesignature
+
+	// First marked tempo in beats per minute. Zero if the piece has no metronome mark
+	Tempo int
+}
+
+// Key profiles by Krumhansl and Kessler starting at the tonic
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+var (
+	majorKeyNames = []string{"Cb", "Gb", "Db", "Ab", "Eb", "Bb", "F", "C", "G", "D", "A", "E", "B", "F#", "C#"}
+	minorKeyNames = []string{"Ab", "Eb", "Bb", "F", "C", "G", "D", "A", "E", "B", "F#", "C#", "G#", "D#", "A#"}
+	pitchNames    = []string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	stepSemitones = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+)
+
+// Dynamics markings in the order they are reported when equally frequent
+var dynamicsMarkings = []struct {
+	name  string
+	marks func(d *Dynamics) []Empty
+}{
+	{name: "ppp", marks: func(d *Dynamics) []Empty { return d.Ppp }},
+	{name: "pp", marks: func(d *Dynamics) []Empty { return d.Pp }},
+	{name: "p", marks: func(d *Dynamics) []Empty { return d.P }},
+	{name: "mp", marks: func(d *Dynamics) []Empty { return d.Mp }},
+	{name: "mf", marks: func(d *Dynamics) []Empty { return d.Mf }},
+	{name: "f", marks: func(d *Dynamics) []Empty { return d.F }},
+	{name: "ff", marks: func(d *Dynamics) []Empty { return d.Ff }},
+	{name: "fff", marks: func(d *Dynamics) []Empty { return d.Fff }},
+}
+
+// Analyze extracts musical features from the score. The key is taken from
+// the key signature when it states the mode. Otherwise it is estimated by
+// correlating the pitch classes of the notes with major and minor key profiles.
+func Analyze(score *Scorepartwise) Analysis {
+	var analysis Analysis
+	if score == nil || len(score.Part) == 0 {
+		return analysis
+	}
+
+	var (
+		pitchClasses  [12]float64
+		signature     *Key
+		numPitched    int
+		dynamicsCount = make(map[string]int)
+	)
+	for _, part := range score.Part {
+		for _, measure := range part.Measure {
+			for _, element := range measure.MusicDataElements {
+				if attr := element.Attributes; attr != nil {
+					if signature == nil && len(attr.Key) > 0 {
+						signature = &attr.Key[0]
+					}
+					if analysis.Time.Beats == 0 && len(attr.Time) > 0 {
+						analysis.Time = attr.Time[0]
+					}
+				}
+				if direction := element.Direction; direction != nil {
+					for _, dirType := range direction.Directiontype {
+						if dirType.Metronome != nil && dirType.Metronome.Perminute != nil && analysis.Tempo == 0 {
+							analysis.Tempo = dirType.Metronome.Perminute.Value
+						}
+						for _, dynamics := range dirType.Dynamics {
+							for _, marking := range dynamicsMarkings {
+								dynamicsCount[marking.name] += len(marking.marks(&dynamics))
+							}
+						}
+					}
+				}
+				if note := element.Note; note != nil && note.Pitch != nil {
+					pitch := midiNumber(note.Pitch)
+					if numPitched == 0 || pitch < analysis.LowestPitch {
+						analysis.LowestPitch = pitch
+					}
+					if numPitched == 0 || pitch > analysis.HighestPitch {
+						analysis.HighestPitch = pitch
+					}
+					numPitched++
+					pitchClasses[((pitch%12)+12)%12] += math.Max(note.Duration.Duration, 1.0)
+				}
+			}
+		}
+	}
+
+	analysis.Fifths, analysis.Mode = estimateKey(signature, pitchClasses)
+	analysis.NotesPerBeat = notesPerBeat(score.Part[0].Measure, analysis.Time)
+
+	best := 0
+	for _, marking := range dynamicsMarkings {
+		if dynamicsCount[marking.name] > best {
+			best = dynamicsCount[marking.name]
+			analysis.Dynamics = marking.name
+		}
+	}
+	return analysis
+}
+
+func midiNumber(pitch *Pitch) int {
+	return 12*(pitch.Octave+1) + stepSemitones[strings.ToUpper(pitch.Step)] + int(math.Round(pitch.Alter))
+}
+
+func notesPerBeat(measures []Measure, time Timesignature) float64 {
+	beats := time.Beats
+	if beats == 0 {
+		beats = 4
+	}
+
+	numNotes := 0
+	for _, measure := range measures {
+		for _, element := range measure.MusicDataElements {
+			if note := element.Note; note != nil && note.Rest == nil && note.Chord == nil && note.Grace == nil {
+				numNotes++
+			}
+		}
+	}
+	if len(measures) == 0 {
+		return 0.0
+	}
+	return float64(numNotes) / float64(len(measures)*beats)
+}
+
+// estimateKey returns the key as the number of fifths and the mode
+func estimateKey(signature *Key, pitchClasses [12]float64) (int, Mode) {
+	if signature != nil {
+		switch mode := Mode(strings.ToLower(signature.Mode)); mode {
+		case ModeMajor, ModeMinor:
+			return signature.Fifths, mode
+		}
+	}
+
+	var total float64
+	for _, weight := range pitchClasses {
+		total += weight
+	}
+	if total == 0.0 {
+		if signature != nil {
+			return signature.Fifths, ModeMajor
+		}
+		return 0, ModeMajor
+	}
+
+	// With a key signature, only the major key and its relative minor are candidates
+	candidates := []int{}
+	if signature != nil {
+		candidates = append(candidates, signature.Fifths)
+	} else {
+		for fifths := -6; fifths <= 5; fifths++ {
+			candidates = append(candidates, fifths)
+		}
+	}
+
+	bestFifths, bestMode, bestCorrelation := 0, ModeMajor, math.Inf(-1)
+	for _, fifths := range candidates {
+		majorTonic := ((7*fifths)%12 + 12) % 12
+		for _, candidate := range []struct {
+			mode    Mode
+			tonic   int
+			profile [12]float64
+		}{
+			{mode: ModeMajor, tonic: majorTonic, profile: majorProfile},
+			{mode: ModeMinor, tonic: (majorTonic + 9) % 12, profile: minorProfile},
+		} {
+			if c := profileCorrelation(pitchClasses, candidate.profile, candidate.tonic); c > bestCorrelation {
+				bestFifths, bestMode, bestCorrelation = fifths, candidate.mode, c
+			}
+		}
+	}
+	return bestFifths, bestMode
+}
+
+// profileCorrelation is the Pearson correlation between the pitch class
+// weights and the key profile rotated to the tonic
+func profileCorrelation(pitchClasses [12]float64, profile [12]float64, tonic int) float64 {
+	var meanX, meanY float64
+	for i := range 12 {
+		meanX += pitchClasses[i] / 12.0
+		meanY += profile[i] / 12.0
+	}
+
+	var cov, varX, varY float64
+	for i := range 12 {
+		dx := pitchClasses[(i+tonic)%12] - meanX
+		dy := profile[i] - meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0.0 || varY == 0.0 {
+		return 0.0
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// Tonic returns the name of the tonic of the estimated key
+func (a *Analysis) Tonic() string {
+	index := a.Fifths + 7
+	if index < 0 || index >= len(majorKeyNames) {
+		return ""
+	}
+	if a.Mode == ModeMinor {
+		return minorKeyNames[index]
+	}
+	return majorKeyNames[index]
+}
+
+// Key returns the estimated key, for example "D minor"
+func (a *Analysis) Key() string {
+	if tonic := a.Tonic(); tonic != "" && a.Mode != "" {
+		return tonic + " " + string(a.Mode)
+	}
+	return ""
+}
+
+// Meter returns the time signature, for example "3/4". Empty if the piece has no time signature
+func (a *Analysis) Meter() string {
+	if a.Time.Beats == 0 || a.Time.Beattype == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", a.Time.Beats, a.Time.Beattype)
+}
+
+// Range returns the pitch range, for example "C4-G5". Empty if the piece has no pitched notes
+func (a *Analysis) Range() string {
+	if a.LowestPitch == 0 && a.HighestPitch == 0 {
+		return ""
+	}
+	return PitchName(a.LowestPitch) + "-" + PitchName(a.HighestPitch)
+}
+
+// PitchName returns the scientific pitch name of a MIDI number, for example "C4" for 60
+func PitchName(midi int) string {
+	octave := midi/12 - 1
+	return fmt.Sprintf("%s%d", pitchNames[((midi%12)+12)%12], octave)
+}
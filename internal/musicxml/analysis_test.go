@@ -0,0 +1,146 @@
+package musicxml
+
+import (
+	"encoding/xml"
+	"math"
+	"testing"
+)
+
+func noteElement(step string, alter float64, octave int, duration float64) MusicDataElement {
+	note := Note{
+		Fullnote: Fullnote{Pitch: &Pitch{Step: step, Alter: alter, Octave: octave}},
+		Duration: Duration{Duration: duration},
+	}
+	return MusicDataElement{XMLName: xml.Name{Local: "note"}, Note: &note}
+}
+
+// scoreWithNotes creates a one-measure piece with the attributes followed by the notes
+func scoreWithNotes(attr *Attributes, notes ...MusicDataElement) *Scorepartwise {
+	measure := NewMeasure()
+	if attr != nil {
+		measure.MusicDataElements = append(measure.MusicDataElements, MusicDataElement{XMLName: xml.Name{Local: "attributes"}, Attributes: attr})
+	}
+	measure.MusicDataElements = append(measure.MusicDataElements, notes...)
+	return NewScorePartwise(WithPart(Part{Measure: []Measure{*measure}}))
+}
+
+func cMajorScale() []MusicDataElement {
+	var notes []MusicDataElement
+	for _, step := range []string{"C", "D", "E", "F", "G", "A", "B"} {
+		notes = append(notes, noteElement(step, 0, 4, 1))
+	}
+	// Emphasise the tonic triad
+	return append(notes, noteElement("C", 0, 5, 4), noteElement("E", 0, 4, 2), noteElement("G", 0, 4, 2))
+}
+
+func aMinorScale() []MusicDataElement {
+	var notes []MusicDataElement
+	for _, step := range []string{"A", "B", "C", "D", "E", "F", "G"} {
+		notes = append(notes, noteElement(step, 0, 4, 1))
+	}
+	// Emphasise the tonic triad with a raised leading tone
+	return append(notes, noteElement("A", 0, 4, 4), noteElement("C", 0, 5, 2), noteElement("E", 0, 5, 2), noteElement("G", 1, 4, 2))
+}
+
+func TestAnalyzeKey(t *testing.T) {
+	for _, test := range []struct {
+		score *Scorepartwise
+		want  string
+		desc  string
+	}{
+		{
+			score: scoreWithNotes(&Attributes{Key: []Key{{Traditionalkey: Traditionalkey{Fifths: -1, Mode: "minor"}}}}),
+			want:  "D minor",
+			desc:  "mode given by key signature",
+		},
+		{
+			score: scoreWithNotes(&Attributes{Key: []Key{{Traditionalkey: Traditionalkey{Fifths: 0}}}}, cMajorScale()...),
+			want:  "C major",
+			desc:  "major estimated from notes",
+		},
+		{
+			score: scoreWithNotes(&Attributes{Key: []Key{{Traditionalkey: Traditionalkey{Fifths: 0}}}}, aMinorScale()...),
+			want:  "A minor",
+			desc:  "relative minor estimated from notes",
+		},
+		{
+			score: scoreWithNotes(nil, aMinorScale()...),
+			want:  "A minor",
+			desc:  "no key signature",
+		},
+		{
+			score: scoreWithNotes(&Attributes{Key: []Key{{Traditionalkey: Traditionalkey{Fifths: 2}}}}),
+			want:  "D major",
+			desc:  "no notes",
+		},
+		{
+			score: nil,
+			want:  "",
+			desc:  "no score",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			analysis := Analyze(test.score)
+			if got := analysis.Key(); got != test.want {
+				t.Errorf("Wanted %q got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFeatures(t *testing.T) {
+	rest := MusicDataElement{XMLName: xml.Name{Local: "note"}, Note: &Note{Fullnote: Fullnote{Rest: &Rest{}}}}
+	chord := noteElement("G", 0, 5, 1)
+	chord.Note.Chord = &Empty{}
+
+	score := scoreWithNotes(
+		&Attributes{Time: []Timesignature{{Beats: 3, Beattype: 4}}},
+		noteElement("E", -1, 3, 1), noteElement("C", 0, 4, 1), rest, chord, noteElement("B", 0, 4, 1),
+	)
+	measure := &score.Part[0].Measure[0]
+	measure.MusicDataElements = append(measure.MusicDataElements,
+		MusicDataElement{XMLName: xml.Name{Local: "direction"}, Direction: NewDirection(WithTempo(96))},
+		MusicDataElement{XMLName: xml.Name{Local: "direction"}, Direction: NewDirection(WithDynamics(DynamicsP))},
+		MusicDataElement{XMLName: xml.Name{Local: "direction"}, Direction: NewDirection(WithDynamics(DynamicsF))},
+		MusicDataElement{XMLName: xml.Name{Local: "direction"}, Direction: NewDirection(WithDynamics(DynamicsF))},
+	)
+
+	analysis := Analyze(score)
+	if got := analysis.Range(); got != "Eb3-G5" {
+		t.Errorf("Wanted range Eb3-G5 got %s", got)
+	}
+	if math.Abs(analysis.NotesPerBeat-1.0) > 1e-6 {
+		t.Errorf("Wanted 1 note per beat got %f", analysis.NotesPerBeat)
+	}
+	if got := analysis.Meter(); got != "3/4" {
+		t.Errorf("Wanted meter 3/4 got %s", got)
+	}
+	if analysis.Tempo != 96 {
+		t.Errorf("Wanted tempo 96 got %d", analysis.Tempo)
+	}
+	if analysis.Dynamics != "f" {
+		t.Errorf("Wanted dynamics f got %s", analysis.Dynamics)
+	}
+}
+
+func TestAnalyzeWithoutMarkings(t *testing.T) {
+	analysis := Analyze(scoreWithNotes(nil))
+	if analysis.Range() != "" || analysis.Meter() != "" || analysis.Dynamics != "" || analysis.Tempo != 0 {
+		t.Errorf("Wanted no features got %+v", analysis)
+	}
+}
+
+func TestPitchName(t *testing.T) {
+	for midi, want := range map[int]string{60: "C4", 21: "A0", 70: "Bb4", 108: "C8"} {
+		if got := PitchName(midi); got != want {
+			t.Errorf("Wanted %s got %s for %d", want, got, midi)
+		}
+	}
+}
+
+func TestTonicOutsideCircleOfFifths(t *testing.T) {
+	analysis := Analysis{Fifths: 9, Mode: ModeMajor}
+	if analysis.Tonic() != "" || analysis.Key() != "" {
+		t.Errorf("Wanted no key got %s", analysis.Key())
+	}
+}
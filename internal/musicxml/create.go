@@ -83,6 +83,24 @@ func WithWords(words string) DirectionOpt {
 	}
 }
 
+// WithBoxedWords adds the words enclosed in a rectangle above the staff
+func WithBoxedWords(words string) DirectionOpt {
+	return func(d *Direction) {
+		text := Formattedtextid{Value: words}
+		text.EnclosureAttr = "rectangle"
+		d.PlacementAttr = "above"
+		d.Directiontype = append(d.Directiontype, Directiontype{Words: []Formattedtextid{text}})
+	}
+}
+
+// WithRehearsal adds a rehearsal mark above the staff
+func WithRehearsal(mark string) DirectionOpt {
+	return func(d *Direction) {
+		d.PlacementAttr = "above"
+		d.Directiontype = append(d.Directiontype, Directiontype{Rehearsal: []Formattedtextid{{Value: mark}}})
+	}
+}
+
 func WithSegno(segno Segno) DirectionOpt {
 	return func(d *Direction) {
 		dirType := Directiontype{Segno: []Segno{segno}}
@@ -97,6 +115,86 @@ func WithCoda(coda Coda) DirectionOpt {
 	}
 }
 
+type DynamicsLevel int
+
+const (
+	DynamicsPp = iota
+	DynamicsP
+	DynamicsMp
+	DynamicsMf
+	DynamicsF
+	DynamicsFf
+)
+
+func (dl DynamicsLevel) String() string {
+	var result string
+	switch dl {
+	case DynamicsPp:
+		result = "pp"
+	case DynamicsP:
+		result = "p"
+	case DynamicsMp:
+		result = "mp"
+	case DynamicsMf:
+		result = "mf"
+	case DynamicsF:
+		result = "f"
+	case DynamicsFf:
+		result = "ff"
+	}
+	return result
+}
+
+func WithDynamics(level DynamicsLevel) DirectionOpt {
+	return func(d *Direction) {
+		var dynamics Dynamics
+		switch level {
+		case DynamicsPp:
+			dynamics.Pp = []Empty{{}}
+		case DynamicsP:
+			dynamics.P = []Empty{{}}
+		case DynamicsMp:
+			dynamics.Mp = []Empty{{}}
+		case DynamicsMf:
+			dynamics.Mf = []Empty{{}}
+		case DynamicsF:
+			dynamics.F = []Empty{{}}
+		case DynamicsFf:
+			dynamics.Ff = []Empty{{}}
+		}
+		d.PlacementAttr = "below"
+		d.Directiontype = append(d.Directiontype, Directiontype{Dynamics: []Dynamics{dynamics}})
+	}
+}
+
+type WedgeType int
+
+const (
+	WedgeCrescendo = iota
+	WedgeDiminuendo
+	WedgeStop
+)
+
+func (wt WedgeType) String() string {
+	var result string
+	switch wt {
+	case WedgeCrescendo:
+		result = "crescendo"
+	case WedgeDiminuendo:
+		result = "diminuendo"
+	case WedgeStop:
+		result = "stop"
+	}
+	return result
+}
+
+func WithWedge(wedgeType WedgeType) DirectionOpt {
+	return func(d *Direction) {
+		d.PlacementAttr = "below"
+		d.Directiontype = append(d.Directiontype, Directiontype{Wedge: &Wedge{TypeAttr: wedgeType.String()}})
+	}
+}
+
 func NewDirection(opts ...DirectionOpt) *Direction {
 	d := Direction{}
 	for _, opt := range opts {
@@ -1,6 +1,11 @@
 package musicxml
 
-import "testing"
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func TestWithRehersalMark(t *testing.T) {
 	measure := NewMeasure(WithRehersalMark("A"))
@@ -135,3 +140,71 @@ func TestWithPart(t *testing.T) {
 		return
 	}
 }
+
+func TestWithDynamics(t *testing.T) {
+	for _, level := range []DynamicsLevel{DynamicsPp, DynamicsP, DynamicsMp, DynamicsMf, DynamicsF, DynamicsFf} {
+		direction := NewDirection(WithDynamics(level))
+		if len(direction.Directiontype) != 1 || len(direction.Directiontype[0].Dynamics) != 1 {
+			t.Errorf("Direction should have one dynamics element for %s", level)
+			return
+		}
+
+		content, err := xml.Marshal(direction.Directiontype[0].Dynamics[0])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		want := fmt.Sprintf("<%s></%s>", level, level)
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Wanted %s to contain %s", content, want)
+		}
+	}
+}
+
+func TestWithWedge(t *testing.T) {
+	for _, wedgeType := range []WedgeType{WedgeCrescendo, WedgeDiminuendo, WedgeStop} {
+		direction := NewDirection(WithWedge(wedgeType))
+		if len(direction.Directiontype) != 1 || direction.Directiontype[0].Wedge == nil {
+			t.Errorf("Direction should have a wedge for %s", wedgeType)
+			return
+		}
+		if got := direction.Directiontype[0].Wedge.TypeAttr; got != wedgeType.String() {
+			t.Errorf("Wanted %s got %s", wedgeType, got)
+		}
+	}
+}
+
+func TestUnknownDynamicsAndWedgeString(t *testing.T) {
+	if s := DynamicsLevel(100).String(); s != "" {
+		t.Errorf("Wanted empty string got %s", s)
+	}
+	if s := WedgeType(100).String(); s != "" {
+		t.Errorf("Wanted empty string got %s", s)
+	}
+}
+
+func TestWithBoxedWords(t *testing.T) {
+	direction := NewDirection(WithBoxedWords("Came the dawn"))
+	content, err := xml.Marshal(direction)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := `<words enclosure="rectangle">Came the dawn</words>`
+	if !strings.Contains(string(content), want) {
+		t.Errorf("Wanted %s to contain %s", content, want)
+	}
+}
+
+func TestWithRehearsal(t *testing.T) {
+	direction := NewDirection(WithRehearsal("3"))
+	content, err := xml.Marshal(direction)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := `<rehearsal>3</rehearsal>`
+	if !strings.Contains(string(content), want) {
+		t.Errorf("Wanted %s to contain %s", content, want)
+	}
+}
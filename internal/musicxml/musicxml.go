@@ -1980,7 +1980,7 @@ type Timpani struct {
 
 // Wedge is The wedge type represents crescendo and diminuendo wedge symbols. The type attribute is crescendo for the start of a wedge that is closed at the left side, and diminuendo for the start of a wedge that is closed on the right side. Spread values are measured in tenths; those at the start of a crescendo wedge or end of a diminuendo wedge are ignored. The niente attribute is yes if a circle appears at the point of the wedge, indicating a crescendo from nothing or diminuendo to nothing. It is no by default, and used only when the type is crescendo, or the type is stop for a wedge that began with a diminuendo type. The line-type is solid if not specified.
 type Wedge struct {
-	Linetype         string
+	Linetype         string `xml:"line-type,attr,omitempty"`
 	Dashedformatting *Dashedformatting
 	Position         *Position
 	ColorAttr        Color `xml:"color,attr,omitempty"`
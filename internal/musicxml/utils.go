@@ -92,32 +92,33 @@ func ReadFromFile(reader io.Reader) (Scorepartwise, error) {
 	return score, nil
 }
 
-func ReadFromFileName(fileSystem fs.FS, name string) Scorepartwise {
+// ReadScore reads a MusicXML file from the file system. Files ending with .mxl
+// are treated as compressed MusicXML
+func ReadScore(fileSystem fs.FS, name string) (Scorepartwise, error) {
 	var score Scorepartwise
 	file, err := fileSystem.Open(name)
 	if err != nil {
-		slog.Error("Failed to open file", "file", name, "error", err)
-		return score
+		return score, err
 	}
 	defer file.Close()
 
+	var reader io.Reader = file
 	if strings.HasSuffix(name, ".mxl") {
-		reader, err := Zip2MusicXMLReader(file)
-		if err != nil {
-			slog.Error("Failed to read compressed file", "file", name, "error", err)
-			return score
-		}
-		score, err = ReadFromFile(reader)
+		reader, err = Zip2MusicXMLReader(file)
 		if err != nil {
-			slog.Error("Failed to read score from compressed file", "file", name, "error", err)
-			return score
-		}
-	} else {
-		score, err = ReadFromFile(file)
-		if err != nil {
-			slog.Error("Failed to read score", "file", name, "error", err)
+			return score, err
 		}
 	}
+	return ReadFromFile(reader)
+}
+
+// ReadFromFileName reads a score like ReadScore, but logs errors instead of
+// returning them
+func ReadFromFileName(fileSystem fs.FS, name string) Scorepartwise {
+	score, err := ReadScore(fileSystem, name)
+	if err != nil {
+		slog.Error("Failed to read score", "file", name, "error", err)
+	}
 	return score
 }
 
@@ -181,6 +182,11 @@ func SetTempoAtBeginning(measure *Measure, metronome *Metronome) {
 	applyBeforeFirstNote(measure, "direction", false, func(m *MusicDataElement) { setTempo(m, metronome) })
 }
 
+// AddDirectionAtBeginning inserts the direction before the first note of the measure
+func AddDirectionAtBeginning(measure *Measure, direction *Direction) {
+	applyBeforeFirstNote(measure, "direction", false, func(m *MusicDataElement) { m.Direction = direction })
+}
+
 func SetSystemTextAtBeginning(measure *Measure, text string) {
 	applyBeforeFirstNote(measure, "direction", true, func(m *MusicDataElement) { setSystemText(m, text) })
 }
@@ -194,6 +200,15 @@ func SetTimeSignatureAtBeginning(measure *Measure, timeSignature Timesignature)
 	applyBeforeFirstNote(measure, "attributes", true, func(m *MusicDataElement) { setTimeSignature(m, timeSignature) })
 }
 
+func setKey(element *MusicDataElement, key Key) {
+	ensureAttributes(element)
+	element.Attributes.Key = []Key{key}
+}
+
+func SetKeyAtBeginning(measure *Measure, key Key) {
+	applyBeforeFirstNote(measure, "attributes", true, func(m *MusicDataElement) { setKey(m, key) })
+}
+
 func setTempo(element *MusicDataElement, metronome *Metronome) {
 	ensureDirection(element)
 	metronomeIsSet := false
@@ -245,9 +260,11 @@ func applyBeforeFirstNote(measure *Measure, name string, reuseExisting bool, fn
 	measure.MusicDataElements = append(measure.MusicDataElements, newElement)
 }
 
+// SetBarlineAtEnd replaces the barlines of the measure with the barline at the
+// end. A barline at the beginning of the measure is kept.
 func SetBarlineAtEnd(measure *Measure, barline *Barline) {
 	measure.MusicDataElements = slices.DeleteFunc(measure.MusicDataElements, func(m MusicDataElement) bool {
-		return m.Barline != nil
+		return m.Barline != nil && m.Barline.LocationAttr != "left"
 	})
 	measure.MusicDataElements = append(measure.MusicDataElements, MusicDataElement{
 		Barline: barline,
@@ -255,6 +272,18 @@ func SetBarlineAtEnd(measure *Measure, barline *Barline) {
 	})
 }
 
+// SetBarlineAtBeginning replaces the barline at the beginning of the measure
+func SetBarlineAtBeginning(measure *Measure, barline *Barline) {
+	measure.MusicDataElements = slices.DeleteFunc(measure.MusicDataElements, func(m MusicDataElement) bool {
+		return m.Barline != nil && m.Barline.LocationAttr == "left"
+	})
+	barline.LocationAttr = "left"
+	measure.MusicDataElements = slices.Insert(measure.MusicDataElements, 0, MusicDataElement{
+		Barline: barline,
+		XMLName: xml.Name{Local: "barline"},
+	})
+}
+
 func ClefEquals(clef1, clef2 *Clef) bool {
 	if clef1 == nil || clef2 == nil {
 		return false
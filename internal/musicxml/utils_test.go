@@ -9,6 +9,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -414,6 +415,85 @@ func TestSetTimeSignatureAtBeginning(t *testing.T) {
 	}
 }
 
+func TestSetKeyAtBeginning(t *testing.T) {
+	for _, test := range []struct {
+		measure *Measure
+		desc    string
+	}{
+		{
+			measure: &Measure{},
+			desc:    "Bar without elements",
+		},
+		{
+			measure: &Measure{
+				MusicDataElements: []MusicDataElement{
+					{XMLName: xml.Name{Local: "attributes"}, Attributes: &Attributes{Key: []Key{{Traditionalkey: Traditionalkey{Fifths: 1}}}}},
+				},
+			},
+			desc: "Replaces existing key",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			SetKeyAtBeginning(test.measure, Key{Traditionalkey: Traditionalkey{Fifths: -3}})
+			if len(test.measure.MusicDataElements) != 1 {
+				t.Errorf("Wanted one element got %d", len(test.measure.MusicDataElements))
+				return
+			}
+			attr := test.measure.MusicDataElements[0].Attributes
+			if attr == nil || len(attr.Key) != 1 || attr.Key[0].Fifths != -3 {
+				t.Errorf("Wanted key with -3 fifths got %+v", attr)
+			}
+		})
+	}
+}
+
+func TestAddDirectionAtBeginning(t *testing.T) {
+	measure := NewMeasure(WithDirection(NewDirection(WithWords("Allegro"))))
+	measure.MusicDataElements = append(measure.MusicDataElements, MusicDataElement{XMLName: xml.Name{Local: "note"}, Note: &Note{}})
+	AddDirectionAtBeginning(measure, NewDirection(WithDynamics(DynamicsF)))
+
+	var names []string
+	for _, element := range measure.MusicDataElements {
+		names = append(names, element.XMLName.Local)
+	}
+	want := []string{"direction", "direction", "note"}
+	if !slices.Equal(names, want) {
+		t.Errorf("Wanted %v got %v", want, names)
+		return
+	}
+	if direction := measure.MusicDataElements[1].Direction; direction == nil || len(direction.Directiontype[0].Dynamics) != 1 {
+		t.Errorf("Wanted dynamics before the first note got %+v", direction)
+	}
+}
+
+func TestSetBarlineAtEndKeepsBarlineAtBeginning(t *testing.T) {
+	measure := NewMeasure()
+	SetBarlineAtBeginning(measure, NewBarline(WithRepeat(&Repeat{DirectionAttr: "forward"})))
+	SetBarlineAtEnd(measure, NewBarline(WithBarStyle(BarStyleLightLight)))
+	SetBarlineAtEnd(measure, NewBarline(WithBarStyle(BarStyleLightHeavy)))
+
+	if len(measure.MusicDataElements) != 2 {
+		t.Errorf("Wanted two barlines got %d elements", len(measure.MusicDataElements))
+		return
+	}
+	left, right := measure.MusicDataElements[0].Barline, measure.MusicDataElements[1].Barline
+	if left == nil || left.LocationAttr != "left" || left.Repeat == nil {
+		t.Errorf("Wanted forward repeat at the beginning got %+v", left)
+	}
+	if right == nil || right.Barstyle.Value != BarStyle(BarStyleLightHeavy).String() {
+		t.Errorf("Wanted light-heavy barline at the end got %+v", right)
+	}
+}
+
+func TestSetBarlineAtBeginningReplacesBarlineAtBeginning(t *testing.T) {
+	measure := NewMeasure()
+	SetBarlineAtBeginning(measure, NewBarline(WithBarStyle(BarStyleHeavyLight)))
+	SetBarlineAtBeginning(measure, NewBarline(WithRepeat(&Repeat{DirectionAttr: "forward"})))
+	if len(measure.MusicDataElements) != 1 || measure.MusicDataElements[0].Barline.Repeat == nil {
+		t.Errorf("Wanted one barline with a repeat got %+v", measure.MusicDataElements)
+	}
+}
+
 func TestApplyBeforeFirstNote(t *testing.T) {
 	newName := "element-was-modified"
 	fn := func(m *MusicDataElement) { m.XMLName.Local = newName }
@@ -637,3 +717,21 @@ func TestIsRepeatJump(t *testing.T) {
 		})
 	}
 }
+
+func TestReadScoreReturnsErrors(t *testing.T) {
+	for _, test := range []struct {
+		fs   fs.FS
+		name string
+		desc string
+	}{
+		{fs: &openFailFs{}, name: "file.musicxml", desc: "open fails"},
+		{fs: &nonXmlFileFs{}, name: "file.musicxml", desc: "read fails"},
+		{fs: &nonXmlFileFs{}, name: "file.mxl", desc: "unzip fails"},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := ReadScore(test.fs, test.name); err == nil {
+				t.Errorf("Expected error")
+			}
+		})
+	}
+}
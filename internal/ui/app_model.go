@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/charmbracelet/bubbles/viewport"
@@ -15,12 +16,25 @@ type AppModel struct {
 	view    viewport.Model
 	current tea.Model
 	store   db.Store
+	config  *Config
+
+	// Library shared by all views. It is nil until it has been loaded
+	library *compose.MultiSourceLibrary
+
+	// State of an ongoing library load
+	loading    *compose.MultiSourceLibrary
+	cancelLoad context.CancelFunc
+	pending    tea.Msg
+
+	// Incremented for every load, such that messages of earlier loads are ignored
+	loadID int
 }
 
-func NewAppModel(store db.Store) *AppModel {
+func NewAppModel(store db.Store, edits ...EditConfigFunc) *AppModel {
 	vp := viewport.New(120, 32)
 
-	a := AppModel{view: vp, store: store, current: &ProjectOverviewModel{store: store}}
+	config := NewConfig(edits...)
+	a := AppModel{view: vp, store: store, config: config, current: &ProjectOverviewModel{store: store, importOpts: config.ImportOpts()}}
 	return &a
 }
 
@@ -38,34 +52,123 @@ func (a *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c":
+			a.cancelLibraryLoad()
 			return a, tea.Quit
 		}
 	case toProjectOverview:
-		nextModel = &ProjectOverviewModel{store: a.store}
+		nextModel = &ProjectOverviewModel{store: a.store, importOpts: a.config.ImportOpts()}
 	case toProjectWorkspace:
-		libs := libraries(a.store)
-		libs = append(libs, compose.NewStandardLibrary())
+		if a.library == nil {
+			nextModel = a.loadLibrary(msg)
+			break
+		}
 		nextModel = &ProjectWorkspace{
 			store:        a.store,
 			project:      msg.project,
-			library:      compose.NewMultiSourceLibrary(libs...),
+			library:      a.library,
 			creator:      &musicxml.FileCreator{},
+			composeOpts:  append(a.config.ComposeOpts(), compose.WithManualSections(a.store)),
 			initialWidth: a.view.Width,
+			scorePerReel: a.config.ScorePerReel,
+			frameRate:    a.config.FrameRate,
 		}
+	case toProjectHistory:
+		nextModel = &ProjectHistoryView{store: a.store, project: msg.project, height: a.view.Height}
 	case toLibraryList:
+		// The configured libraries may change, so the library is loaded again when needed
+		a.library = nil
 		nextModel = &LibraryModel{store: a.store}
 	case toLibraryContent:
-		libs := libraries(a.store)
-		libs = append(libs, compose.NewStandardLibrary())
-		nextModel = &LibraryContentView{lib: compose.NewMultiSourceLibrary(libs...), width: a.view.Width, height: a.view.Height}
+		if a.library == nil {
+			nextModel = a.loadLibrary(msg)
+			break
+		}
+		nextModel = &LibraryContentView{lib: a.library, width: a.view.Width, height: a.view.Height}
+	case toLibraryHealth:
+		if a.library == nil {
+			nextModel = a.loadLibrary(msg)
+			break
+		}
+		nextModel = &LibraryHealthView{
+			lib:            a.library,
+			creator:        &musicxml.FileCreator{},
+			excludeFailing: a.config.ExcludeFailingPieces,
+			width:          a.view.Width,
+			height:         a.view.Height,
+		}
+	case toSectionEditor:
+		if a.library == nil {
+			nextModel = a.loadLibrary(msg)
+			break
+		}
+		nextModel = &SectionEditorView{
+			store:  a.store,
+			lib:    a.library,
+			piece:  msg.piece,
+			width:  a.view.Width,
+			height: a.view.Height,
+		}
+	case setExcludeFailing:
+		if msg.exclude != a.config.ExcludeFailingPieces {
+			a.config.ExcludeFailingPieces = msg.exclude
+			// Pieces are excluded when the library is built
+			a.library = nil
+		}
+	case libraryLoadedMsg:
+		return a.finishLibraryLoad(msg)
 	}
 
+	var initCmd tea.Cmd
 	if nextModel != nil && nextModel != a.current {
-		nextModel.Init()
+		initCmd = nextModel.Init()
 		a.current = nextModel
 	}
 	_, cmd := a.current.Update(msg)
-	return a, cmd
+	return a, tea.Batch(initCmd, cmd)
+}
+
+// loadLibrary starts loading the library in the background and returns a view
+// showing the progress. The pending message is re-dispatched once loading is done.
+func (a *AppModel) loadLibrary(pending tea.Msg) tea.Model {
+	a.cancelLibraryLoad()
+	opt := compose.WithExcludeFailing(a.config.ExcludeFailingPieces)
+	libs := libraries(a.store, opt)
+	libs = append(libs, compose.NewStandardLibrary(opt))
+	a.loading = compose.NewMultiSourceLibrary(libs...)
+	a.pending = pending
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelLoad = cancel
+	a.loadID++
+	return NewLibraryLoadingView(startLibraryLoad(ctx, a.loading, a.loadID), cancel, a.view.Width)
+}
+
+// finishLibraryLoad uses the loaded library and re-dispatches the pending
+// message. Messages of loads that were replaced or cancelled are ignored.
+func (a *AppModel) finishLibraryLoad(msg libraryLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.id != a.loadID || a.loading == nil {
+		slog.Info("Ignoring stale library load", "id", msg.id, "current", a.loadID)
+		return a, nil
+	}
+	pending := a.pending
+	loaded := a.loading
+	a.cancelLibraryLoad()
+
+	if msg.err != nil {
+		slog.Info("Library loading stopped", "error", msg.err)
+		return a.Update(toProjectOverview{})
+	}
+	a.library = loaded
+	return a.Update(pending)
+}
+
+func (a *AppModel) cancelLibraryLoad() {
+	if a.cancelLoad != nil {
+		a.cancelLoad()
+	}
+	a.cancelLoad = nil
+	a.loading = nil
+	a.pending = nil
 }
 
 func (a *AppModel) View() string {
@@ -77,7 +180,7 @@ func (a *AppModel) View() string {
 	return a.view.View()
 }
 
-func libraries(store db.LibraryList) []compose.Library {
+func libraries(store db.LibraryList, opts ...compose.FsLibraryOpt) []compose.Library {
 	libraries, err := store.ListLibraries()
 	var result []compose.Library
 	if err != nil {
@@ -86,7 +189,7 @@ func libraries(store db.LibraryList) []compose.Library {
 	}
 
 	for _, item := range libraries {
-		result = append(result, compose.NewLocalLibrary(item.Path))
+		result = append(result, compose.NewLocalLibrary(item.Path, opts...))
 	}
 	return result
 }
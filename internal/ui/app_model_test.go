@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -111,11 +112,27 @@ func TestTransitionToLibraryListInApp(t *testing.T) {
 	}
 }
 
+// waitUntilLibraryLoaded feeds library updates to the app until loading is done
+func waitUntilLibraryLoaded(app *AppModel) {
+	for {
+		view, ok := app.current.(*LibraryLoadingView)
+		if !ok {
+			return
+		}
+		app.Update(<-view.updates)
+	}
+}
+
 func TestTransitionToProjectWorkspace(t *testing.T) {
 	app := NewAppModel(initProjectDb())
 	app.Init()
 	app.Update(toProjectWorkspace{project: &db.Project{}})
 
+	if _, ok := app.current.(*LibraryLoadingView); !ok {
+		t.Errorf("Wanted library to be loaded first got %T", app.current)
+	}
+	waitUntilLibraryLoaded(app)
+
 	switch app.current.(type) {
 	case *ProjectWorkspace:
 	default:
@@ -127,6 +144,7 @@ func TestTransitionToLibraryContent(t *testing.T) {
 	app := NewAppModel(initProjectDb())
 	app.Init()
 	app.Update(toLibraryContent{})
+	waitUntilLibraryLoaded(app)
 
 	switch app.current.(type) {
 	case *LibraryContentView:
@@ -152,3 +170,129 @@ func TestLibrariesFailingStore(t *testing.T) {
 		t.Errorf("Wanted 0 libraries, got %d", len(libs))
 	}
 }
+
+func TestLibraryIsOnlyLoadedOnce(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryContent{})
+	waitUntilLibraryLoaded(app)
+	loaded := app.library
+
+	app.Update(toProjectWorkspace{project: &db.Project{}})
+	if _, ok := app.current.(*ProjectWorkspace); !ok {
+		t.Errorf("Wanted project workspace without loading got %T", app.current)
+	}
+	if app.library != loaded {
+		t.Errorf("Library should be reused")
+	}
+
+	app.Update(toLibraryList{})
+	if app.library != nil {
+		t.Errorf("Library should be reloaded after visiting the library list")
+	}
+}
+
+func TestCancelLibraryLoad(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryContent{})
+
+	view, ok := app.current.(*LibraryLoadingView)
+	if !ok {
+		t.Errorf("Wanted library loading view got %T", app.current)
+		return
+	}
+	view.cancel()
+	app.Update(libraryLoadedMsg{id: app.loadID, err: context.Canceled})
+
+	if _, ok := app.current.(*ProjectOverviewModel); !ok {
+		t.Errorf("Wanted project overview after cancel got %T", app.current)
+	}
+	if app.library != nil || app.cancelLoad != nil {
+		t.Errorf("Wanted no library and no ongoing load")
+	}
+}
+
+func TestStaleLibraryLoadIsIgnored(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryContent{})
+	stale := app.loadID
+	app.Update(toLibraryHealth{})
+
+	app.Update(libraryLoadedMsg{id: stale})
+	if _, ok := app.current.(*LibraryLoadingView); !ok || app.library != nil {
+		t.Errorf("Wanted the newer load to continue got %T", app.current)
+	}
+
+	waitUntilLibraryLoaded(app)
+	if _, ok := app.current.(*LibraryHealthView); !ok {
+		t.Errorf("Wanted library health view got %T", app.current)
+	}
+}
+
+func TestCtrlCCancelsLibraryLoad(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryContent{})
+	app.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+
+	if app.cancelLoad != nil {
+		t.Errorf("Loading should have been cancelled")
+	}
+}
+
+func TestTransitionToLibraryHealth(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryHealth{})
+	waitUntilLibraryLoaded(app)
+
+	if _, ok := app.current.(*LibraryHealthView); !ok {
+		t.Errorf("Wanted library health view got %T", app.current)
+	}
+}
+
+func TestSetExcludeFailingReloadsLibrary(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryHealth{})
+	waitUntilLibraryLoaded(app)
+
+	app.Update(setExcludeFailing{exclude: true})
+	if !app.config.ExcludeFailingPieces {
+		t.Errorf("Wanted failing pieces to be excluded")
+	}
+	if app.library != nil {
+		t.Errorf("Library should be rebuilt with the new setting")
+	}
+}
+
+func TestTransitionToSectionEditor(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toLibraryContent{})
+	waitUntilLibraryLoaded(app)
+
+	piece := app.library.Content()[0]
+	app.Update(toSectionEditor{piece: piece})
+
+	editor, ok := app.current.(*SectionEditorView)
+	if !ok {
+		t.Errorf("Wanted section editor got %T", app.current)
+		return
+	}
+	if len(editor.measureText) == 0 {
+		t.Errorf("Wanted the measures of %s in the editor", piece.ScoreTitle)
+	}
+}
+
+func TestTransitionToProjectHistory(t *testing.T) {
+	app := NewAppModel(initProjectDb())
+	app.Init()
+	app.Update(toProjectHistory{project: &db.Project{Id: 1}})
+
+	if _, ok := app.current.(*ProjectHistoryView); !ok {
+		t.Errorf("Wanted project history got %T", app.current)
+	}
+}
@@ -1,14 +1,66 @@
 package ui
 
+import (
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/interchange"
+	"gorm.io/gorm"
+)
+
+// ProjectBackend is where projects are stored
+type ProjectBackend int
+
+const (
+	// Projects are stored in the database together with the libraries
+	DatabaseBackend ProjectBackend = iota
+
+	// Projects are stored as one file each in a directory
+	FileBackend
+)
+
 type Config struct {
 	DbName  string
 	LogFile string
+
+	// Where projects are stored. The libraries are always kept in the database
+	ProjectBackend    ProjectBackend
+	ProjectDir        string
+	ProjectFileFormat db.ProjectFileFormat
+
+	// Exclude pieces failing the library health check from matching
+	ExcludeFailingPieces bool
+
+	// How pieces are split into sections when composing
+	SectionDetection      compose.SectionDetection
+	MinBoundaryConfidence float64
+
+	// Give scenes with empty keywords no music
+	TacetForEmptyKeywords bool
+
+	// Write one score per reel in addition to the combined score
+	ScorePerReel bool
+
+	// Longest silence between two subtitles that is merged into the scene before it
+	SubtitleGap time.Duration
+
+	// Frame rate of the timecodes in edit decision lists and marker files
+	FrameRate float64
+
+	// Shots of imported edit decision lists shorter than this are merged into one scene
+	MinShotLength time.Duration
 }
 
 func defaultConfig() *Config {
 	return &Config{
 		DbName:  "silent-score.db",
 		LogFile: "silent-score.log",
+
+		SectionDetection:      compose.SectionsFromRehearsalMarks,
+		MinBoundaryConfidence: 0.4,
+		SubtitleGap:           interchange.DefaultSubtitleGap,
+		FrameRate:             interchange.DefaultFrameRate,
 	}
 }
 
@@ -26,6 +78,100 @@ func WithLogFile(name string) EditConfigFunc {
 	}
 }
 
+// WithProjectFiles stores projects as files of the given format in the directory
+func WithProjectFiles(dir string, format db.ProjectFileFormat) EditConfigFunc {
+	return func(c *Config) {
+		c.ProjectBackend = FileBackend
+		c.ProjectDir = dir
+		c.ProjectFileFormat = format
+	}
+}
+
+func WithExcludeFailingPieces(exclude bool) EditConfigFunc {
+	return func(c *Config) {
+		c.ExcludeFailingPieces = exclude
+	}
+}
+
+func WithSectionDetection(detection compose.SectionDetection, minConfidence float64) EditConfigFunc {
+	return func(c *Config) {
+		c.SectionDetection = detection
+		c.MinBoundaryConfidence = minConfidence
+	}
+}
+
+func WithTacetForEmptyKeywords(tacet bool) EditConfigFunc {
+	return func(c *Config) {
+		c.TacetForEmptyKeywords = tacet
+	}
+}
+
+func WithScorePerReel(perReel bool) EditConfigFunc {
+	return func(c *Config) {
+		c.ScorePerReel = perReel
+	}
+}
+
+func WithSubtitleGap(gap time.Duration) EditConfigFunc {
+	return func(c *Config) {
+		c.SubtitleGap = gap
+	}
+}
+
+func WithFrameRate(fps float64) EditConfigFunc {
+	return func(c *Config) {
+		c.FrameRate = fps
+	}
+}
+
+func WithMinShotLength(length time.Duration) EditConfigFunc {
+	return func(c *Config) {
+		c.MinShotLength = length
+	}
+}
+
+// WithEDLImport sets the frame rate and the shortest shot of imported edit
+// decision lists. The frame rate is used for marker files as well.
+func WithEDLImport(frameRate float64, minShotLength time.Duration) EditConfigFunc {
+	return func(c *Config) {
+		c.FrameRate = frameRate
+		c.MinShotLength = minShotLength
+	}
+}
+
+// ImportOpts returns the options used when importing subtitle files and edit
+// decision lists
+func (c *Config) ImportOpts() []interchange.ImportOpt {
+	return []interchange.ImportOpt{
+		interchange.WithGapThreshold(c.SubtitleGap),
+		interchange.WithFrameRate(c.FrameRate),
+		interchange.WithMinShotLength(c.MinShotLength),
+	}
+}
+
+// Store returns the store of the configured backend. Libraries and sections
+// are kept in the database for both backends.
+func (c *Config) Store(database *gorm.DB) db.Store {
+	gormStore := &db.GormStore{Database: database}
+	if c.ProjectBackend != FileBackend {
+		return gormStore
+	}
+	return &db.SplitStore{
+		ProjectStore: db.NewFileStore(c.ProjectDir, c.ProjectFileFormat),
+		LibraryList:  gormStore,
+		SectionStore: gormStore,
+	}
+}
+
+// ComposeOpts returns the options passed to the composer
+func (c *Config) ComposeOpts() []compose.ComposeOpt {
+	return []compose.ComposeOpt{
+		compose.WithSectionDetection(c.SectionDetection),
+		compose.WithMinBoundaryConfidence(c.MinBoundaryConfidence),
+		compose.WithTacetForEmptyKeywords(c.TacetForEmptyKeywords),
+	}
+}
+
 func NewConfig(edits ...EditConfigFunc) *Config {
 	c := defaultConfig()
 	for _, editFunc := range edits {
@@ -1,6 +1,14 @@
 package ui
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/db"
+)
 
 func configIsEqual(c1, c2 *Config) bool {
 	return c1.DbName == c2.DbName
@@ -29,3 +37,91 @@ func TestSetWithLogFile(t *testing.T) {
 		t.Errorf("Wanted logfile.log got %s", config.LogFile)
 	}
 }
+
+func TestSetExcludeFailingPieces(t *testing.T) {
+	config := NewConfig(WithExcludeFailingPieces(true))
+	if !config.ExcludeFailingPieces {
+		t.Errorf("Wanted failing pieces to be excluded")
+	}
+}
+
+func TestSetSectionDetection(t *testing.T) {
+	config := NewConfig(WithSectionDetection(compose.SectionsFromRehearsalMarksAndDetected, 0.8))
+	if config.SectionDetection != compose.SectionsFromRehearsalMarksAndDetected {
+		t.Errorf("Wanted %s got %s", compose.SectionsFromRehearsalMarksAndDetected, config.SectionDetection)
+	}
+	if config.MinBoundaryConfidence != 0.8 {
+		t.Errorf("Wanted 0.8 got %f", config.MinBoundaryConfidence)
+	}
+	if opts := config.ComposeOpts(); len(opts) != 3 {
+		t.Errorf("Wanted 3 compose options got %d", len(opts))
+	}
+}
+
+func TestSetTacetForEmptyKeywords(t *testing.T) {
+	config := NewConfig(WithTacetForEmptyKeywords(true))
+	if !config.TacetForEmptyKeywords {
+		t.Errorf("Wanted scenes with empty keywords to be tacet")
+	}
+}
+
+func TestSetScorePerReel(t *testing.T) {
+	config := NewConfig(WithScorePerReel(true))
+	if !config.ScorePerReel {
+		t.Errorf("Wanted one score per reel")
+	}
+}
+
+func TestSetSubtitleGap(t *testing.T) {
+	config := NewConfig(WithSubtitleGap(5 * time.Second))
+	if config.SubtitleGap != 5*time.Second {
+		t.Errorf("Wanted gap of 5s got %s", config.SubtitleGap)
+	}
+}
+
+func TestSetFrameRateAndMinShotLength(t *testing.T) {
+	for _, test := range []struct {
+		desc  string
+		edits []EditConfigFunc
+	}{
+		{desc: "separate options", edits: []EditConfigFunc{WithFrameRate(25), WithMinShotLength(3 * time.Second)}},
+		{desc: "edl import", edits: []EditConfigFunc{WithEDLImport(25, 3*time.Second)}},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			config := NewConfig(test.edits...)
+			if config.FrameRate != 25 || config.MinShotLength != 3*time.Second {
+				t.Errorf("Wanted 25 fps and 3s shots got %.2f fps and %s", config.FrameRate, config.MinShotLength)
+			}
+		})
+	}
+}
+
+func TestStoreBackend(t *testing.T) {
+	dir := t.TempDir()
+	database, err := db.GormConnection(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := db.Migrate(database); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := NewConfig().Store(database).(*db.GormStore); !ok {
+		t.Errorf("Wanted projects in the database by default")
+	}
+
+	projectDir := filepath.Join(dir, "projects")
+	store := NewConfig(WithProjectFiles(projectDir, db.FormatJSON)).Store(database)
+	if err := store.Save(db.NewProject(db.WithName("film"))); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "film.json")); err != nil {
+		t.Errorf("Wanted the project stored as a file: %v", err)
+	}
+	if err := store.AddLibrary("scores"); err != nil {
+		t.Errorf("Wanted libraries kept in the database: %v", err)
+	}
+}
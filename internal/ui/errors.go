@@ -5,4 +5,11 @@ import "errors"
 var (
 	ErrTempoMustBeInteger    = errors.New("tempo must be an integer")
 	ErrDurationMustBeInteger = errors.New("duration must be an integer")
+	ErrIntensityOutOfRange   = errors.New("intensity must be an integer from 1 to 5")
+	ErrReelMustBePositive    = errors.New("reel must be a positive integer")
+	ErrStartMustBeClockTime  = errors.New("start must be a clock time like 01:02:03.5")
+	ErrNothingToUndo         = errors.New("nothing to undo")
+	ErrNothingToRedo         = errors.New("nothing to redo")
+	ErrProjectDeleted        = errors.New("the project was deleted elsewhere")
+	ErrNoConflict            = errors.New("the project was not changed elsewhere")
 )
@@ -114,6 +114,10 @@ func (l *LibraryModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 			l.inputField.SetValue("")
 			l.inputField.CursorStart()
+		case "ctrl+r":
+			return l, func() tea.Msg {
+				return toLibraryHealth{}
+			}
 		case "down":
 			l.currentLibraries.CursorDown()
 		case "up":
@@ -142,7 +146,7 @@ func (l *LibraryModel) View() string {
 		l.currentLibraries.View(),
 		fmt.Sprintf("Add library: %s", l.currentBestGuess),
 		l.inputField.View(),
-		helpStyle.Render("esc: to project overview \u2022 enter: add library \u2022 delete: remove library"),
+		helpStyle.Render("esc: to project overview \u2022 enter: add library \u2022 delete: remove library \u2022 ctrl+r: library health report"),
 	}
 	return lipgloss.JoinVertical(lipgloss.Left, content...)
 }
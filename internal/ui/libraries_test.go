@@ -242,3 +242,12 @@ func TestRenderWithWrongType(t *testing.T) {
 		t.Errorf("Wanted empty string got %s", writer.String())
 	}
 }
+
+func TestCtrlRToLibraryHealth(t *testing.T) {
+	model := LibraryModel{store: db.NewInMemoryLibraryList()}
+	model.Init()
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if _, ok := cmd().(toLibraryHealth); !ok {
+		t.Errorf("Wanted transition to library health")
+	}
+}
@@ -1,29 +1,43 @@
 package ui
 
 import (
+	"fmt"
 	"slices"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/davidkleiven/silent-score/internal/compose"
 )
 
+// Number of pieces listed when searching for similar pieces
+const numSimilarPieces = 10
+
 type LibraryContentView struct {
 	lib     compose.Library
 	content list.Model
 	width   int
 	height  int
+
+	// Piece the list shows similar pieces for. Nil when all pieces are listed
+	similarTo *compose.LibraryContent
 }
 
 func listHeight(componentHeight int) int {
 	return confine(componentHeight-1, 0, componentHeight)
 }
 
-func (l *LibraryContentView) Init() tea.Cmd {
+func contentItems(content []compose.LibraryContent) []list.Item {
 	var items []list.Item
-	for _, item := range l.lib.Content() {
+	for _, item := range content {
 		items = append(items, &item)
 	}
+	return items
+}
+
+func (l *LibraryContentView) allItems() []list.Item {
+	items := contentItems(l.lib.Content())
 	slices.SortFunc(items, func(i, j list.Item) int {
 		if i.FilterValue() < j.FilterValue() {
 			return -1
@@ -32,12 +46,21 @@ func (l *LibraryContentView) Init() tea.Cmd {
 		}
 		return 0
 	})
+	return items
+}
 
-	l.content = list.New(items, list.NewDefaultDelegate(), l.width, listHeight(l.height))
+func (l *LibraryContentView) Init() tea.Cmd {
+	l.content = list.New(l.allItems(), list.NewDefaultDelegate(), l.width, listHeight(l.height))
 	l.content.SetFilteringEnabled(true)
 	l.content.SetShowFilter(true)
 	l.content.SetShowHelp(true)
 	l.content.SetShowTitle(false)
+	l.content.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "edit sections")),
+			key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "find similar pieces")),
+		}
+	}
 	return nil
 }
 
@@ -47,8 +70,29 @@ func (l *LibraryContentView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		l.content.SetSize(msg.Width, listHeight(msg.Height))
 	case tea.KeyMsg:
+		if l.content.FilterState() == list.Filtering {
+			break
+		}
 		switch msg.String() {
+		case "enter":
+			if item, ok := l.content.SelectedItem().(*compose.LibraryContent); ok {
+				piece := *item
+				return l, func() tea.Msg {
+					return toSectionEditor{piece: piece}
+				}
+			}
+		case "s":
+			if item, ok := l.content.SelectedItem().(*compose.LibraryContent); ok {
+				l.similarTo = item
+				cmd := l.content.SetItems(contentItems(compose.SimilarPieces(l.lib, item.Fingerprint, numSimilarPieces)))
+				l.content.Select(0)
+				return l, cmd
+			}
 		case "esc":
+			if l.content.FilterState() == list.Unfiltered && l.similarTo != nil {
+				l.similarTo = nil
+				return l, l.content.SetItems(l.allItems())
+			}
 			if l.content.FilterState() == list.Unfiltered {
 				cmds = append(cmds, func() tea.Msg {
 					return toProjectOverview{}
@@ -63,5 +107,9 @@ func (l *LibraryContentView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (l *LibraryContentView) View() string {
+	if l.similarTo != nil {
+		header := helpStyle.Render(fmt.Sprintf("Pieces similar to %s (esc: show all pieces)", l.similarTo.FilterValue()))
+		return lipgloss.JoinVertical(lipgloss.Left, header, l.content.View())
+	}
 	return l.content.View()
 }
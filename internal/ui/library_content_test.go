@@ -106,3 +106,85 @@ func TestWindowResizePassedToList(t *testing.T) {
 		return
 	}
 }
+
+func TestEnterOpensSectionEditor(t *testing.T) {
+	view := LibraryContentView{
+		lib: &compose.InMemoryLibrary{
+			Scores: []*musicxml.Scorepartwise{musicxml.NewScorePartwise(musicxml.WithComposer("Chopin"))},
+		},
+		width:  80,
+		height: 80,
+	}
+
+	view.Init()
+	_, cmd := view.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	msg, ok := cmd().(toSectionEditor)
+	if !ok {
+		t.Errorf("Wanted transition to section editor got %v", cmd())
+		return
+	}
+	if msg.piece.Composer != "Chopin" || msg.piece.Fingerprint == "" {
+		t.Errorf("Wanted piece by Chopin with fingerprint got %+v", msg.piece)
+	}
+}
+
+func TestViewShowsAnalysisColumns(t *testing.T) {
+	measure := musicxml.NewMeasure()
+	measure.MusicDataElements = append(measure.MusicDataElements, musicxml.MusicDataElement{
+		Attributes: &musicxml.Attributes{
+			Key:  []musicxml.Key{{Traditionalkey: musicxml.Traditionalkey{Fifths: -1, Mode: "minor"}}},
+			Time: []musicxml.Timesignature{{Beats: 3, Beattype: 4}},
+		},
+	})
+	content := LibraryContentView{
+		lib: &compose.InMemoryLibrary{
+			Scores: []*musicxml.Scorepartwise{
+				musicxml.NewScorePartwise(musicxml.WithComposer("Chopin"), musicxml.WithPart(musicxml.Part{Measure: []musicxml.Measure{*measure}})),
+			},
+		},
+		width:  120,
+		height: 80,
+	}
+
+	content.Init()
+	result := content.View()
+	for _, substr := range []string{"Chopin", "D minor", "3/4"} {
+		if !strings.Contains(result, substr) {
+			t.Errorf("Expected %s in view, got %s", substr, result)
+		}
+	}
+}
+
+func TestFindSimilarPieces(t *testing.T) {
+	view := LibraryContentView{
+		lib: &compose.InMemoryLibrary{
+			Scores: []*musicxml.Scorepartwise{
+				musicxml.NewScorePartwise(musicxml.WithComposer("Bach")),
+				musicxml.NewScorePartwise(musicxml.WithComposer("Beethoven")),
+				musicxml.NewScorePartwise(musicxml.WithComposer("Chopin")),
+			},
+		},
+		width:  120,
+		height: 80,
+	}
+
+	view.Init()
+	view.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if view.similarTo == nil || len(view.content.Items()) != 2 {
+		t.Errorf("Wanted the two other pieces got %d items", len(view.content.Items()))
+		return
+	}
+	if !strings.Contains(view.View(), "Pieces similar to") {
+		t.Errorf("Wanted header for similar pieces got %s", view.View())
+	}
+
+	_, cmd := view.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if cmd != nil {
+		if _, ok := cmd().(toProjectOverview); ok {
+			t.Errorf("Wanted to return to all pieces, not the project overview")
+		}
+	}
+	if view.similarTo != nil || len(view.content.Items()) != 3 {
+		t.Errorf("Wanted all pieces to be listed got %d items", len(view.content.Items()))
+	}
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const healthReportFile = "library-health.json"
+
+type healthItem struct {
+	health compose.PieceHealth
+}
+
+func (h *healthItem) FilterValue() string {
+	return h.health.File + " " + h.health.Title + " " + h.health.Composer
+}
+
+func (h *healthItem) Title() string {
+	marker := "OK  "
+	if h.health.Failing() {
+		marker = "FAIL"
+	} else if len(h.health.Issues) > 0 {
+		marker = "WARN"
+	}
+	return fmt.Sprintf("%s %s (%s)", marker, h.health.File, h.health.Title)
+}
+
+func (h *healthItem) Description() string {
+	if len(h.health.Issues) == 0 {
+		return "No issues"
+	}
+	issues := make([]string, len(h.health.Issues))
+	for i, issue := range h.health.Issues {
+		issues[i] = fmt.Sprintf("%s: %s", issue.Kind, issue.Message)
+	}
+	return strings.Join(issues, "; ")
+}
+
+type LibraryHealthView struct {
+	lib            compose.Library
+	creator        musicxml.Creator
+	excludeFailing bool
+	pieces         []compose.PieceHealth
+	content        list.Model
+	status         *Status
+	width          int
+	height         int
+}
+
+func (l *LibraryHealthView) Init() tea.Cmd {
+	l.status = NewStatus()
+	l.pieces = l.lib.Health()
+
+	// Failing pieces first, then pieces with warnings
+	slices.SortStableFunc(l.pieces, func(a, b compose.PieceHealth) int {
+		if a.Failing() != b.Failing() {
+			if a.Failing() {
+				return -1
+			}
+			return 1
+		}
+		return len(b.Issues) - len(a.Issues)
+	})
+
+	items := make([]list.Item, len(l.pieces))
+	for i, piece := range l.pieces {
+		items[i] = &healthItem{health: piece}
+	}
+
+	l.content = list.New(items, list.NewDefaultDelegate(), l.width, listHeight(l.height-2))
+	l.content.SetFilteringEnabled(true)
+	l.content.SetShowTitle(false)
+	l.content.SetStatusBarItemName("piece", "pieces")
+	return nil
+}
+
+func (l *LibraryHealthView) numFailing() int {
+	num := 0
+	for _, piece := range l.pieces {
+		if piece.Failing() {
+			num++
+		}
+	}
+	return num
+}
+
+func (l *LibraryHealthView) writeReport() error {
+	file, err := l.creator.Create(healthReportFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return compose.WriteHealthReport(file, l.pieces)
+}
+
+func (l *LibraryHealthView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.content.SetSize(msg.Width, listHeight(msg.Height-2))
+	case tea.KeyMsg:
+		if l.content.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "esc":
+			if l.content.FilterState() == list.Unfiltered {
+				return l, func() tea.Msg {
+					return toLibraryList{}
+				}
+			}
+		case "ctrl+s":
+			err := l.writeReport()
+			l.status.Set(fmt.Sprintf("Health report written to %s", healthReportFile), err)
+		case "x":
+			l.excludeFailing = !l.excludeFailing
+			l.status.Set(l.exclusionDescription(), nil)
+			exclude := l.excludeFailing
+			cmds = append(cmds, func() tea.Msg {
+				return setExcludeFailing{exclude: exclude}
+			})
+		}
+	}
+	var cmd tea.Cmd
+	l.content, cmd = l.content.Update(msg)
+	cmds = append(cmds, cmd)
+	return l, tea.Batch(cmds...)
+}
+
+func (l *LibraryHealthView) exclusionDescription() string {
+	if l.excludeFailing {
+		return "Failing pieces are excluded from matching"
+	}
+	return "Failing pieces take part in matching"
+}
+
+func (l *LibraryHealthView) View() string {
+	summary := fmt.Sprintf("%d pieces, %d failing. %s", len(l.pieces), l.numFailing(), l.exclusionDescription())
+	content := []string{
+		pad2.Render(summary),
+		l.content.View(),
+		helpStyle.Render("esc: to library list \u2022 ctrl+s: write JSON report \u2022 x: toggle exclusion of failing pieces"),
+		l.status.Render("Health"),
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, content...)
+}
@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func healthView(t *testing.T) (*LibraryHealthView, string) {
+	reportFile := t.TempDir() + "/health.json"
+	view := LibraryHealthView{
+		lib: &compose.InMemoryLibrary{
+			Scores: []*musicxml.Scorepartwise{
+				musicxml.NewScorePartwise(musicxml.WithComposer("Bach"), musicxml.WithPart(musicxml.Part{Measure: []musicxml.Measure{{}}})),
+				musicxml.NewScorePartwise(),
+			},
+		},
+		creator: &customFileCreator{name: reportFile},
+		width:   120,
+		height:  40,
+	}
+	view.Init()
+	return &view, reportFile
+}
+
+func TestHealthViewFailingFirst(t *testing.T) {
+	view, _ := healthView(t)
+	if !view.pieces[0].Failing() || view.pieces[1].Failing() {
+		t.Errorf("Wanted failing piece first got %v", view.pieces)
+	}
+
+	result := view.View()
+	for _, substr := range []string{"2 pieces, 1 failing", "FAIL", "WARN"} {
+		if !strings.Contains(result, substr) {
+			t.Errorf("Expected %s in view, got %s", substr, result)
+		}
+	}
+}
+
+func TestHealthViewWriteReport(t *testing.T) {
+	view, reportFile := healthView(t)
+	view.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+
+	content, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(string(content), `"numFailing": 1`) {
+		t.Errorf("Wanted one failing piece in report got %s", content)
+	}
+}
+
+func TestHealthViewToggleExclusion(t *testing.T) {
+	view, _ := healthView(t)
+	_, cmd := view.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+
+	result, ok := cmd().(setExcludeFailing)
+	if !ok || !result.exclude {
+		t.Errorf("Wanted message enabling exclusion got %v", cmd())
+	}
+}
+
+func TestHealthViewEsc(t *testing.T) {
+	view, _ := healthView(t)
+	_, cmd := view.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if _, ok := cmd().(toLibraryList); !ok {
+		t.Errorf("Wanted transition to library list")
+	}
+}
+
+func TestHealthItemDescription(t *testing.T) {
+	item := healthItem{}
+	if item.Description() != "No issues" {
+		t.Errorf("Wanted 'No issues' got %s", item.Description())
+	}
+	if !strings.HasPrefix(item.Title(), "OK") {
+		t.Errorf("Wanted OK prefix got %s", item.Title())
+	}
+}
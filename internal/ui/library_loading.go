@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/davidkleiven/silent-score/internal/compose"
+)
+
+type libraryProgressMsg struct {
+	done  int
+	total int
+}
+
+type libraryLoadedMsg struct {
+	// Identifies the load that finished, such that a load that was replaced
+	// by a newer one is not taken for it
+	id  int
+	err error
+}
+
+// startLibraryLoad loads the library in the background. Updates are published on
+// the returned channel. Progress updates are dropped if the UI is lagging behind,
+// but the final libraryLoadedMsg is always delivered with the given id.
+func startLibraryLoad(ctx context.Context, lib compose.Loader, id int) <-chan tea.Msg {
+	updates := make(chan tea.Msg, 1)
+	go func() {
+		err := lib.Load(ctx, func(done, total int) {
+			select {
+			case updates <- libraryProgressMsg{done: done, total: total}:
+			default:
+			}
+		})
+		updates <- libraryLoadedMsg{id: id, err: err}
+	}()
+	return updates
+}
+
+func waitForLibraryUpdate(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+type LibraryLoadingView struct {
+	updates  <-chan tea.Msg
+	cancel   context.CancelFunc
+	progress progress.Model
+	done     int
+	total    int
+	width    int
+}
+
+func NewLibraryLoadingView(updates <-chan tea.Msg, cancel context.CancelFunc, width int) *LibraryLoadingView {
+	return &LibraryLoadingView{updates: updates, cancel: cancel, width: width}
+}
+
+func (l *LibraryLoadingView) Init() tea.Cmd {
+	l.progress = progress.New(progress.WithDefaultGradient())
+	l.progress.Width = confine(l.width-4, 10, 80)
+	return waitForLibraryUpdate(l.updates)
+}
+
+func (l *LibraryLoadingView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.progress.Width = confine(msg.Width-4, 10, 80)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			l.cancel()
+		}
+	case libraryProgressMsg:
+		l.done = msg.done
+		l.total = msg.total
+		return l, waitForLibraryUpdate(l.updates)
+	}
+	return l, nil
+}
+
+func (l *LibraryLoadingView) percent() float64 {
+	if l.total == 0 {
+		return 0.0
+	}
+	return float64(l.done) / float64(l.total)
+}
+
+func (l *LibraryLoadingView) View() string {
+	content := []string{
+		pad2.Render(fmt.Sprintf("Indexing %d/%d pieces", l.done, l.total)),
+		pad2.Render(l.progress.ViewAs(l.percent())),
+		helpStyle.Render("esc: cancel \u2022 ctrl+c: quit"),
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, content...)
+}
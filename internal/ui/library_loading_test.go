@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/davidkleiven/silent-score/internal/compose"
+)
+
+func TestLibraryLoadingProgress(t *testing.T) {
+	updates := make(chan tea.Msg, 1)
+	view := NewLibraryLoadingView(updates, func() {}, 80)
+	view.Init()
+
+	_, cmd := view.Update(libraryProgressMsg{done: 812, total: 2000})
+	if cmd == nil {
+		t.Errorf("Wanted command waiting for the next update")
+	}
+
+	if result := view.View(); !strings.Contains(result, "Indexing 812/2000 pieces") {
+		t.Errorf("Wanted progress in view got %s", result)
+	}
+}
+
+func TestLibraryLoadingEscCancels(t *testing.T) {
+	cancelled := false
+	view := NewLibraryLoadingView(make(chan tea.Msg), func() { cancelled = true }, 80)
+	view.Init()
+	view.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if !cancelled {
+		t.Errorf("Wanted loading to be cancelled on esc")
+	}
+}
+
+func TestLibraryLoadingPercentNoPieces(t *testing.T) {
+	view := LibraryLoadingView{}
+	if p := view.percent(); p != 0.0 {
+		t.Errorf("Wanted 0 got %f", p)
+	}
+}
+
+func TestStartLibraryLoad(t *testing.T) {
+	updates := startLibraryLoad(context.Background(), compose.NewStandardLibrary(), 7)
+	for msg := range updates {
+		if loaded, ok := msg.(libraryLoadedMsg); ok {
+			if loaded.err != nil {
+				t.Error(loaded.err)
+			}
+			if loaded.id != 7 {
+				t.Errorf("Wanted the load id 7 got %d", loaded.id)
+			}
+			return
+		}
+	}
+}
@@ -1,11 +1,25 @@
 package ui
 
-import "github.com/davidkleiven/silent-score/internal/db"
+import (
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/db"
+)
 
 type toProjectOverview struct{}
 type toProjectWorkspace struct {
 	project *db.Project
 }
+type toProjectHistory struct {
+	project *db.Project
+}
 
 type toLibraryList struct{}
 type toLibraryContent struct{}
+type toLibraryHealth struct{}
+type toSectionEditor struct {
+	piece compose.LibraryContent
+}
+
+type setExcludeFailing struct {
+	exclude bool
+}
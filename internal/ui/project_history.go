@@ -0,0 +1,169 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+const snapshotTimeLayout = "2006-01-02 15:04:05"
+
+// ProjectHistoryView lists the snapshots stored when the project was saved.
+// Two snapshots can be compared, and a snapshot can be restored.
+type ProjectHistoryView struct {
+	store     db.ProjectStore
+	project   *db.Project
+	snapshots []db.Snapshot
+	cursor    int
+	mark      int
+	diff      []string
+	status    *Status
+	height    int
+}
+
+func (h *ProjectHistoryView) Init() tea.Cmd {
+	h.status = NewStatus()
+	h.mark = noMark
+
+	history, ok := h.store.(db.HistoryStore)
+	if !ok {
+		h.status.Set("History is not kept for projects stored as files", nil)
+		return nil
+	}
+	snapshots, err := history.History(h.project.Id)
+	if err != nil {
+		h.status.Set("", err)
+		return nil
+	}
+	h.snapshots = snapshots
+	h.status.Set(fmt.Sprintf("%d snapshots", len(snapshots)), nil)
+	return nil
+}
+
+func (h *ProjectHistoryView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		h.height = msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc":
+			return h, h.toWorkspace
+		case "up":
+			h.cursor = confine(h.cursor-1, 0, max(len(h.snapshots)-1, 0))
+		case "down":
+			h.cursor = confine(h.cursor+1, 0, max(len(h.snapshots)-1, 0))
+		case " ":
+			if h.mark == h.cursor {
+				h.mark = noMark
+			} else if len(h.snapshots) > 0 {
+				h.mark = h.cursor
+			}
+		case "d":
+			h.showDiff()
+		case "r":
+			if err := h.restore(); err != nil {
+				h.status.Set("", err)
+				break
+			}
+			return h, h.toWorkspace
+		}
+	}
+	return h, nil
+}
+
+func (h *ProjectHistoryView) toWorkspace() tea.Msg {
+	return toProjectWorkspace{project: h.project}
+}
+
+// showDiff compares the snapshot at the cursor with the marked snapshot, or
+// with the current scenes when no snapshot is marked
+func (h *ProjectHistoryView) showDiff() {
+	if len(h.snapshots) == 0 {
+		return
+	}
+	selected, err := h.snapshots[h.cursor].Records()
+	if err != nil {
+		h.status.Set("", err)
+		return
+	}
+
+	before, after := selected, h.project.Records
+	description := fmt.Sprintf("Changes since %s", h.snapshots[h.cursor].CreatedAt.Format(snapshotTimeLayout))
+	if h.mark != noMark && h.mark != h.cursor {
+		marked, err := h.snapshots[h.mark].Records()
+		if err != nil {
+			h.status.Set("", err)
+			return
+		}
+
+		// Snapshots are listed with the newest first
+		older, newer := max(h.mark, h.cursor), min(h.mark, h.cursor)
+		before, after = selected, marked
+		if older == h.mark {
+			before, after = marked, selected
+		}
+		description = fmt.Sprintf("Changes from %s to %s", h.snapshots[older].CreatedAt.Format(snapshotTimeLayout), h.snapshots[newer].CreatedAt.Format(snapshotTimeLayout))
+	}
+
+	changes := db.DiffRecords(before, after)
+	h.diff = make([]string, len(changes))
+	for i, change := range changes {
+		h.diff[i] = change.String()
+	}
+	h.status.Set(fmt.Sprintf("%s: %s", description, db.SummarizeChanges(changes)), nil)
+}
+
+// restore saves the project with the scenes of the snapshot at the cursor
+func (h *ProjectHistoryView) restore() error {
+	if len(h.snapshots) == 0 {
+		return nil
+	}
+	snapshot := h.snapshots[h.cursor]
+	records, err := snapshot.Records()
+	if err != nil {
+		return err
+	}
+
+	previous := h.project.Records
+	h.project.Records = records
+	h.project.SyncReels()
+	h.project.ChangeSummary = "Restored snapshot from " + snapshot.CreatedAt.Format(snapshotTimeLayout)
+	defer func() { h.project.ChangeSummary = "" }()
+	if err := h.store.Save(h.project); err != nil {
+		h.project.Records = previous
+		return err
+	}
+	return nil
+}
+
+func (h *ProjectHistoryView) snapshotsView() string {
+	start, end := visibleRange(h.cursor, len(h.snapshots), h.height/2)
+	lines := []string{"Saved               Scenes  Changes"}
+	for i := start; i < end; i++ {
+		snapshot := h.snapshots[i]
+		mark := " "
+		if i == h.mark {
+			mark = "*"
+		}
+		line := fmt.Sprintf("%s%s%s %6d  %s", cursorPrefix(i == h.cursor), mark, snapshot.CreatedAt.Format(snapshotTimeLayout), snapshot.SceneCount, snapshot.Summary)
+		lines = append(lines, line)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (h *ProjectHistoryView) View() string {
+	content := []string{
+		pad2.Render(h.project.Name),
+		pad2.Render(h.snapshotsView()),
+	}
+	if len(h.diff) > 0 {
+		content = append(content, pad2.Render(lipgloss.JoinVertical(lipgloss.Left, h.diff...)))
+	}
+	content = append(content,
+		helpStyle.Render("\u2191/\u2193 up/down \u2022 space: mark snapshot \u2022 d: diff with marked snapshot or current scenes \u2022 r: restore \u2022 esc: back"),
+		h.status.Render("History"),
+	)
+	return lipgloss.JoinVertical(lipgloss.Left, content...)
+}
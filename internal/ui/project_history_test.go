@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidkleiven/silent-score/internal/db"
+)
+
+// projectWithHistory saves the project once for every set of scenes
+func projectWithHistory(t *testing.T, store db.ProjectStore, descriptions ...[]string) *db.Project {
+	project := db.NewProject(db.WithName("my-project"))
+	for _, scenes := range descriptions {
+		project.Records = make([]db.ProjectContentRecord, len(scenes))
+		for i, desc := range scenes {
+			project.Records[i] = db.ProjectContentRecord{Scene: uint(i), SceneDesc: desc}
+		}
+		if err := store.Save(project); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return project
+}
+
+func TestHistoryListsSnapshots(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := projectWithHistory(t, store, []string{"Opening"}, []string{"Opening", "Chase"})
+	view := &ProjectHistoryView{store: store, project: project}
+	view.Init()
+
+	if len(view.snapshots) != 2 {
+		t.Errorf("Wanted 2 snapshots got %d", len(view.snapshots))
+	}
+	result := view.View()
+	for _, substr := range []string{`+ scene 2: "Chase"`, `+ scene 1: "Opening"`, "restore"} {
+		if !strings.Contains(result, substr) {
+			t.Errorf("Expected %s in view, got %s", substr, result)
+		}
+	}
+}
+
+func TestHistoryWithoutHistoryStore(t *testing.T) {
+	store := db.NewFileStore(t.TempDir(), db.FormatYAML)
+	project := projectWithHistory(t, store, []string{"Opening"})
+	view := &ProjectHistoryView{store: store, project: project}
+	view.Init()
+
+	if len(view.snapshots) != 0 || !strings.Contains(view.status.msg, "not kept") {
+		t.Errorf("Wanted a message that history is not kept got %q", view.status.msg)
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := projectWithHistory(t, store, []string{"Opening", "Chase"}, []string{"Opening"}, []string{"Opening", "Finale"})
+
+	for _, test := range []struct {
+		desc string
+		keys []string
+		want []string
+	}{
+		{
+			desc: "oldest snapshot against current scenes",
+			keys: []string{"down", "down", "d"},
+			want: []string{`~ scene 2: "Finale" changed description`},
+		},
+		{
+			desc: "newest against oldest snapshot",
+			keys: []string{"space", "down", "down", "d"},
+			want: []string{`~ scene 2: "Finale" changed description`},
+		},
+		{
+			desc: "two latest snapshots",
+			keys: []string{"down", "space", "up", "d"},
+			want: []string{`+ scene 2: "Finale"`},
+		},
+		{
+			desc: "oldest against middle snapshot",
+			keys: []string{"down", "down", "space", "up", "d"},
+			want: []string{`- scene 2: "Chase"`},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			view := &ProjectHistoryView{store: store, project: project}
+			view.Init()
+			pressKeys(view, test.keys...)
+			if strings.Join(view.diff, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("Wanted %v got %v", test.want, view.diff)
+			}
+		})
+	}
+}
+
+func TestHistoryRestore(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := projectWithHistory(t, store, []string{"Opening", "Chase"}, []string{"Opening"})
+	view := &ProjectHistoryView{store: store, project: project}
+	view.Init()
+
+	cmd := pressKeys(view, "down", "r")
+	if cmd == nil {
+		t.Fatal("Wanted command to return to the workspace")
+	}
+	if _, ok := cmd().(toProjectWorkspace); !ok {
+		t.Errorf("Wanted to return to the workspace")
+	}
+
+	projects, err := store.Load()
+	if err != nil || len(projects) != 1 || len(projects[0].Records) != 2 {
+		t.Errorf("Wanted the restored project with 2 scenes got %v (%v)", projects, err)
+	}
+
+	snapshots, err := store.History(project.Id)
+	if err != nil || len(snapshots) != 3 || !strings.HasPrefix(snapshots[0].Summary, "Restored snapshot from") {
+		t.Errorf("Wanted a snapshot of the restore got %v (%v)", snapshots, err)
+	}
+	if project.ChangeSummary != "" {
+		t.Errorf("Wanted the change summary to be reset got %q", project.ChangeSummary)
+	}
+}
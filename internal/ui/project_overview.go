@@ -12,6 +12,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/interchange"
 )
 
 type ProjectOverviewMode int
@@ -20,6 +21,9 @@ const (
 	browseMode ProjectOverviewMode = iota
 	newProjectMode
 	deleteConfirmationMode
+	importMode
+	duplicateMode
+	templateMode
 )
 
 type ProjectOverviewModel struct {
@@ -27,6 +31,8 @@ type ProjectOverviewModel struct {
 	projects       list.Model
 	status         *Status
 	newProjectName textinput.Model
+	importPath     textinput.Model
+	importOpts     []interchange.ImportOpt
 	mode           ProjectOverviewMode
 }
 
@@ -44,6 +50,9 @@ func (d projectItemDelegate) Render(w io.Writer, m list.Model, index int, listIt
 	}
 
 	s := fmt.Sprintf("%d. %s", index+1, project.Name)
+	if project.Template {
+		s += " (template)"
+	}
 	fn := itemStyle.Render
 	if index == m.Index() {
 		fn = func(s ...string) string {
@@ -84,6 +93,9 @@ func (p *ProjectOverviewModel) Init() tea.Cmd {
 	p.newProjectName = textinput.New()
 	p.newProjectName.Placeholder = "Press ctrl+n to create new project"
 	p.newProjectName.CharLimit = 128
+	p.importPath = textinput.New()
+	p.importPath.Placeholder = "Path to a scene table (.csv, .tsv), subtitles (.srt, .vtt) or cut list (.edl)"
+	p.importPath.CharLimit = 512
 	p.toBrowseMode("")
 	return nil
 }
@@ -96,6 +108,8 @@ func (p *ProjectOverviewModel) toBrowseMode(msg string) {
 	p.mode = browseMode
 	p.newProjectName.Blur()
 	p.newProjectName.Reset()
+	p.importPath.Blur()
+	p.importPath.Reset()
 	p.status.Set(msg, nil)
 }
 
@@ -105,6 +119,28 @@ func (p *ProjectOverviewModel) toTextInputMode(msg string) {
 	p.status.Set(msg, nil)
 }
 
+func (p *ProjectOverviewModel) toImportMode() {
+	p.mode = importMode
+	p.importPath.Focus()
+	p.status.Set("Enter the file to import", nil)
+}
+
+// toCopyMode asks for the name of a copy of the chosen project. Copies made in
+// templateMode are templates.
+func (p *ProjectOverviewModel) toCopyMode(mode ProjectOverviewMode) {
+	project, ok := p.projects.SelectedItem().(*db.Project)
+	if !ok {
+		return
+	}
+	p.mode = mode
+	p.newProjectName.Focus()
+	if mode == templateMode {
+		p.status.Set(fmt.Sprintf("Enter the name of the template made from %s", project.Name), nil)
+	} else {
+		p.status.Set(fmt.Sprintf("Enter the name of the copy of %s", project.Name), nil)
+	}
+}
+
 func (p *ProjectOverviewModel) toDeleteConfirmation() {
 	p.mode = deleteConfirmationMode
 	p.status.Set(fmt.Sprintf("Are you sure that %s should be deleted? (y/N)", p.projects.SelectedItem().FilterValue()), nil)
@@ -128,6 +164,35 @@ func (p *ProjectOverviewModel) createNewProject() {
 	p.loadProjectsFromDb()
 }
 
+// copyChosenProject stores a copy of the chosen project under the entered
+// name. A copy of a template is a regular project.
+func (p *ProjectOverviewModel) copyChosenProject() {
+	project, ok := p.projects.SelectedItem().(*db.Project)
+	if !ok {
+		slog.Info("Could not convert into Project")
+		return
+	}
+	name := strings.TrimSpace(p.newProjectName.Value())
+	if name == "" {
+		p.status.Set("", errors.New("project name can not be empty"))
+		return
+	}
+	template := p.mode == templateMode
+	if _, err := db.CopyProject(p.store, project.Id, db.WithName(name), db.AsTemplate(template)); err != nil {
+		p.status.Set("", err)
+		return
+	}
+
+	msg := fmt.Sprintf("Copied %s to %s", project.Name, name)
+	if template {
+		msg = fmt.Sprintf("Saved %s as template %s", project.Name, name)
+	} else if project.Template {
+		msg = fmt.Sprintf("Created %s from template %s", name, project.Name)
+	}
+	p.toBrowseMode(msg)
+	p.loadProjectsFromDb()
+}
+
 func (p *ProjectOverviewModel) deleteChosenProject() {
 	project, ok := p.projects.SelectedItem().(*db.Project)
 	if !ok {
@@ -139,6 +204,32 @@ func (p *ProjectOverviewModel) deleteChosenProject() {
 	p.loadProjectsFromDb()
 }
 
+func (p *ProjectOverviewModel) importProject() {
+	project, err := interchange.ImportFile(p.store, strings.TrimSpace(p.importPath.Value()), "", p.importOpts...)
+	if err != nil {
+		p.status.Set("", err)
+		return
+	}
+	p.toBrowseMode(fmt.Sprintf("Imported %d scenes into %s", len(project.Records), project.Name))
+	p.loadProjectsFromDb()
+}
+
+// exportFileName returns the file the scenes of the project are exported to
+func exportFileName(project *db.Project) string {
+	return strings.ReplaceAll(project.Name, " ", "_") + ".csv"
+}
+
+func (p *ProjectOverviewModel) exportChosenProject() {
+	project, ok := p.projects.SelectedItem().(*db.Project)
+	if !ok {
+		slog.Info("Could not convert into Project")
+		return
+	}
+	fname := exportFileName(project)
+	err := interchange.ExportProject(p.store, project.Name, fname)
+	p.status.Set(fmt.Sprintf("Exported %s to %s", project.Name, fname), err)
+}
+
 //
 // UI updates
 //
@@ -152,18 +243,36 @@ func (p *ProjectOverviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	var cmd tea.Cmd
 	switch p.mode {
-	case newProjectMode:
+	case newProjectMode, duplicateMode, templateMode:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
-				p.createNewProject()
+				if p.mode == newProjectMode {
+					p.createNewProject()
+				} else {
+					p.copyChosenProject()
+				}
 			case "esc":
 				p.toBrowseMode("")
 			}
 		}
 		p.newProjectName, cmd = p.newProjectName.Update(msg)
 		return p, cmd
+	case importMode:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "enter":
+				p.importProject()
+				return p, nil
+			case "esc":
+				p.toBrowseMode("")
+				return p, nil
+			}
+		}
+		p.importPath, cmd = p.importPath.Update(msg)
+		return p, cmd
 	case deleteConfirmationMode:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -184,6 +293,14 @@ func (p *ProjectOverviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				p.toTextInputMode("")
 			case "delete":
 				p.toDeleteConfirmation()
+			case "ctrl+o":
+				p.toImportMode()
+			case "ctrl+e":
+				p.exportChosenProject()
+			case "ctrl+d":
+				p.toCopyMode(duplicateMode)
+			case "ctrl+t":
+				p.toCopyMode(templateMode)
 			case "enter":
 				p.status.Set(fmt.Sprintf("Selected project %s", p.projects.SelectedItem().FilterValue()), nil)
 				return p, func() tea.Msg {
@@ -207,10 +324,14 @@ func (p *ProjectOverviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (p *ProjectOverviewModel) View() string {
+	input := p.newProjectName.View()
+	if p.mode == importMode {
+		input = p.importPath.View()
+	}
 	content := []string{
 		p.projects.View(),
-		p.newProjectName.View(),
-		helpStyle.Render("ctrl+n: New project \u2022 delete: Delete project \u2022 enter: Open project \u2022 ctrl+l: List libraries \u2022 ctrl+a: List pieces \u2022 ctrl+c: Quit"),
+		input,
+		helpStyle.Render("ctrl+n: New project \u2022 ctrl+d: Duplicate project or create from template \u2022 ctrl+t: Save as template \u2022 delete: Delete project \u2022 enter: Open project \u2022 ctrl+o: Import scenes \u2022 ctrl+e: Export scenes \u2022 ctrl+l: List libraries \u2022 ctrl+a: List pieces \u2022 ctrl+c: Quit"),
 		p.status.Render(modeDescription(p.mode)),
 	}
 	return lipgloss.JoinVertical(lipgloss.Left, content...)
@@ -222,6 +343,8 @@ func modeDescription(mode ProjectOverviewMode) string {
 		return "Browse mode"
 	case deleteConfirmationMode:
 		return "Delete confirm"
+	case importMode:
+		return "Import mode (esc to leave)"
 	default:
 		return "Text enter mode (esc to leave)"
 	}
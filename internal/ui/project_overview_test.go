@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -162,8 +164,9 @@ func TestModeDescription(t *testing.T) {
 		browseMode:             "Browse",
 		newProjectMode:         "Text",
 		deleteConfirmationMode: "Delete",
+		importMode:             "Import",
 	}
-	for _, mode := range []ProjectOverviewMode{browseMode, deleteConfirmationMode, newProjectMode} {
+	for _, mode := range []ProjectOverviewMode{browseMode, deleteConfirmationMode, newProjectMode, importMode} {
 		if desc := modeDescription(mode); !strings.Contains(desc, tokens[mode]) {
 			t.Errorf("Expected %s tp be part of %s", tokens[mode], desc)
 		}
@@ -222,3 +225,122 @@ func TestSaveProjectWithRepeatedName(t *testing.T) {
 		t.Error("Status should be in the error state")
 	}
 }
+
+func TestImportProjectFromTable(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "Nosferatu.tsv")
+	if err := os.WriteFile(fname, []byte("Description\tDuration\nOpening\t30\nCastle\t1:00\n"), 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	model := ProjectOverviewModel{store: initProjectDb()}
+	model.Init()
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+	if model.mode != importMode {
+		t.Errorf("Wanted mode %d got %d", importMode, model.mode)
+	}
+	model.importPath.SetValue(fname)
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if model.mode != browseMode {
+		t.Errorf("Wanted mode %d got %d", browseMode, model.mode)
+	}
+	if n := len(model.projects.Items()); n != 3 {
+		t.Errorf("Wanted 3 projects got %d", n)
+	}
+	if !strings.Contains(model.status.msg, "2 scenes") {
+		t.Errorf("Wanted number of scenes in status got %s", model.status.msg)
+	}
+}
+
+func TestImportProjectMissingFile(t *testing.T) {
+	model := ProjectOverviewModel{store: initProjectDb()}
+	model.Init()
+	model.toImportMode()
+	model.importPath.SetValue(filepath.Join(t.TempDir(), "missing.csv"))
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if model.mode != importMode {
+		t.Errorf("Should stay in mode %d is in %d", importMode, model.mode)
+	}
+	if model.status.kind != errorStatus {
+		t.Error("Status should be in the error state")
+	}
+}
+
+func TestExportProjectToTable(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := db.NewInMemoryStore()
+	store.Save(db.NewProject(db.WithName("my film"), db.WithRecords([]db.ProjectContentRecord{{SceneDesc: "Opening", DurationSec: 30}})))
+
+	model := ProjectOverviewModel{store: store}
+	model.Init()
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlE})
+
+	content, err := os.ReadFile("my_film.csv")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(string(content), "Opening") {
+		t.Errorf("Wanted the scene in the exported table got %s", content)
+	}
+}
+
+func TestCopyProjects(t *testing.T) {
+	store := db.NewInMemoryStore()
+	project := db.NewProject(db.WithName("comedy"), db.WithRecords([]db.ProjectContentRecord{{SceneDesc: "Chase", Theme: 1}}))
+	if err := store.Save(project); err != nil {
+		t.Fatal(err)
+	}
+	model := ProjectOverviewModel{store: store}
+	model.Init()
+
+	enterName := func(key tea.KeyType, name string) {
+		model.Update(tea.KeyMsg{Type: key})
+		model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)})
+		model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if model.mode != browseMode || model.status.kind == errorStatus {
+			t.Errorf("Wanted %s to be stored got mode %d and status %q", name, model.mode, model.status.msg)
+		}
+	}
+
+	enterName(tea.KeyCtrlT, "two-reeler")
+	if want := "Saved comedy as template two-reeler"; model.status.msg != want {
+		t.Errorf("Wanted %q got %q", want, model.status.msg)
+	}
+
+	model.projects.Select(1)
+	enterName(tea.KeyCtrlD, "new comedy")
+	if want := "Created new comedy from template two-reeler"; model.status.msg != want {
+		t.Errorf("Wanted %q got %q", want, model.status.msg)
+	}
+
+	projects, err := store.Load()
+	if err != nil || len(projects) != 3 {
+		t.Fatalf("Wanted three projects got %d (%v)", len(projects), err)
+	}
+	for _, project := range projects {
+		wantTemplate := project.Name == "two-reeler"
+		if project.Template != wantTemplate || len(project.Records) != 1 || project.Records[0].Theme != 1 {
+			t.Errorf("Wanted a copy of the scenes with template %v got %+v", wantTemplate, project)
+		}
+	}
+	if view := model.View(); !strings.Contains(view, "two-reeler (template)") {
+		t.Errorf("Wanted the template to be marked in %s", view)
+	}
+}
+
+func TestCopyWithExistingName(t *testing.T) {
+	model := ProjectOverviewModel{store: initProjectDb()}
+	model.Init()
+	model.Update(tea.KeyMsg{Type: tea.KeyCtrlD})
+	if model.mode != duplicateMode {
+		t.Fatalf("Wanted mode %d got %d", duplicateMode, model.mode)
+	}
+	model.newProjectName.SetValue("project2")
+	model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if model.mode != duplicateMode || model.status.kind != errorStatus {
+		t.Errorf("Wanted to stay in mode %d with an error got %d and %q", duplicateMode, model.mode, model.status.msg)
+	}
+}
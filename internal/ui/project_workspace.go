@@ -1,8 +1,10 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -30,10 +32,30 @@ const (
 	tiKeywords
 	tiTheme
 	tiDuration
+	tiIntensity
+	tiReel
+	tiStart
 )
 const rowPadding = 2
 
-type tiRow []textinput.Model
+// Largest number of scenes a piece is avoided in after it has been used
+const maxDiversityWindow = 6
+
+// Reading speeds in words per second that ctrl+r cycles through
+var readingSpeeds = []float64{1.0, 1.5, 2.0, 2.5, 3.0}
+
+// tiRow is a row of the table with an input per column
+type tiRow struct {
+	inputs []textinput.Model
+
+	// ID of the record shown in the row. Zero for rows that have not been
+	// saved. It is kept with the row such that it moves with it.
+	id uint
+
+	// Fingerprint of the piece pinned to the scene, which is not edited in
+	// the table but kept when the scenes are saved
+	pinned string
+}
 
 func NewTiRow(opts ...tiOpt) tiRow {
 	var (
@@ -42,6 +64,9 @@ func NewTiRow(opts ...tiOpt) tiRow {
 		keywordsTi  = textinput.New()
 		themeTi     = textinput.New()
 		startTi     = textinput.New()
+		intensityTi = textinput.New()
+		reelTi      = textinput.New()
+		timecodeTi  = textinput.New()
 	)
 
 	sceneDescTi.Width = 64
@@ -58,58 +83,87 @@ func NewTiRow(opts ...tiOpt) tiRow {
 
 	startTi.Width = 8
 	startTi.Prompt = ""
-	row := []textinput.Model{sceneDescTi, tempoTi, keywordsTi, themeTi, startTi}
+
+	intensityTi.Width = 9
+	intensityTi.Prompt = ""
+
+	reelTi.Width = 6
+	reelTi.Prompt = ""
+
+	timecodeTi.Width = 11
+	timecodeTi.Prompt = ""
+	row := tiRow{inputs: []textinput.Model{sceneDescTi, tempoTi, keywordsTi, themeTi, startTi, intensityTi, reelTi, timecodeTi}}
 
 	for _, fn := range opts {
 		fn(row)
 	}
-	row[tiScene].Focus()
+	row.inputs[tiScene].Focus()
 	return row
 }
 
 func (row tiRow) SetWidth(width int) {
 	remainingWidth := width - 2*rowPadding
-	row[tiTempo].Width = confine(6, 0, remainingWidth)
-	remainingWidth = remainingWidth - row[tiTempo].Width - 1
+	row.inputs[tiTempo].Width = confine(6, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiTempo].Width - 1
+
+	row.inputs[tiTheme].Width = confine(6, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiTheme].Width - 1
+
+	row.inputs[tiDuration].Width = confine(14, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiDuration].Width - 1
 
-	row[tiTheme].Width = confine(6, 0, remainingWidth)
-	remainingWidth = remainingWidth - row[tiTheme].Width - 1
+	row.inputs[tiIntensity].Width = confine(9, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiIntensity].Width - 1
 
-	row[tiDuration].Width = confine(14, 0, remainingWidth)
-	remainingWidth = remainingWidth - row[tiDuration].Width - 1
+	row.inputs[tiReel].Width = confine(6, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiReel].Width - 1
 
-	row[tiKeywords].Width = confine(remainingWidth/2, 0, remainingWidth)
-	remainingWidth = remainingWidth - row[tiKeywords].Width - 1
-	row[tiScene].Width = confine(remainingWidth, 0, remainingWidth)
+	row.inputs[tiStart].Width = confine(11, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiStart].Width - 1
+
+	row.inputs[tiKeywords].Width = confine(remainingWidth/2, 0, remainingWidth)
+	remainingWidth = remainingWidth - row.inputs[tiKeywords].Width - 1
+	row.inputs[tiScene].Width = confine(remainingWidth, 0, remainingWidth)
 }
 
 func NewTiRowFromRecord(record *db.ProjectContentRecord) tiRow {
 	row := NewTiRow()
+	row.id = record.ID
+	row.pinned = record.Pinned
 	if record.Tempo > 0 {
-		row[tiTempo].SetValue(fmt.Sprintf("%d", record.Tempo))
+		row.inputs[tiTempo].SetValue(fmt.Sprintf("%d", record.Tempo))
 	}
 
 	if record.Theme > 0 {
-		row[tiTheme].SetValue(fmt.Sprintf("%d", record.Theme))
+		row.inputs[tiTheme].SetValue(fmt.Sprintf("%d", record.Theme))
 	}
 
 	if record.DurationSec > 0 {
-		row[tiDuration].SetValue(fmt.Sprintf("%d", record.DurationSec))
+		row.inputs[tiDuration].SetValue(fmt.Sprintf("%d", record.DurationSec))
+	}
+
+	if record.Intensity > 0 {
+		row.inputs[tiIntensity].SetValue(fmt.Sprintf("%d", record.Intensity))
+	}
+
+	if record.Reel > 0 {
+		row.inputs[tiReel].SetValue(fmt.Sprintf("%d", record.Reel))
 	}
 
-	row[tiScene].SetValue(record.SceneDesc)
-	row[tiKeywords].SetValue(record.Keywords)
+	row.inputs[tiScene].SetValue(record.SceneDesc)
+	row.inputs[tiKeywords].SetValue(record.Keywords)
+	row.inputs[tiStart].SetValue(record.Timecode)
 	return row
 }
 
 func (t tiRow) Blur() {
-	for i := range t {
-		t[i].Blur()
+	for i := range t.inputs {
+		t.inputs[i].Blur()
 	}
 }
 
 func (t tiRow) Active() int {
-	for i, item := range t {
+	for i, item := range t.inputs {
 		if item.Focused() {
 			return i
 		}
@@ -119,19 +173,19 @@ func (t tiRow) Active() int {
 
 func (t tiRow) FocusRight() {
 	active := t.Active()
-	t[active].Blur()
-	t[confine(active+1, 0, len(t)-1)].Focus()
+	t.inputs[active].Blur()
+	t.inputs[confine(active+1, 0, len(t.inputs)-1)].Focus()
 }
 
 func (t tiRow) FocusLeft() {
 	active := t.Active()
-	t[active].Blur()
-	t[confine(active-1, 0, len(t)-1)].Focus()
+	t.inputs[active].Blur()
+	t.inputs[confine(active-1, 0, len(t.inputs)-1)].Focus()
 }
 
 func (t tiRow) View() string {
-	data := make([]string, len(t))
-	for i, item := range t {
+	data := make([]string, len(t.inputs))
+	for i, item := range t.inputs {
 		data[i] = item.View()
 	}
 	return strings.Repeat(" ", rowPadding) + lipgloss.JoinHorizontal(lipgloss.Top, data...)
@@ -139,9 +193,9 @@ func (t tiRow) View() string {
 
 func (t tiRow) Update(msg tea.Msg) tea.Cmd {
 	var cmd tea.Cmd
-	cmds := make([]tea.Cmd, len(t))
-	for i, item := range t {
-		t[i], cmd = item.Update(msg)
+	cmds := make([]tea.Cmd, len(t.inputs))
+	for i, item := range t.inputs {
+		t.inputs[i], cmd = item.Update(msg)
 		cmds[i] = cmd
 	}
 
@@ -153,42 +207,86 @@ func (t tiRow) Update(msg tea.Msg) tea.Cmd {
 }
 
 func (t tiRow) Duration() string {
-	return t[tiDuration].Value()
+	return t.inputs[tiDuration].Value()
 }
 
 func (t tiRow) Tempo() string {
-	return t[tiTempo].Value()
+	return t.inputs[tiTempo].Value()
 }
 
 func (t tiRow) TempoOrDefault() (int, error) {
-	return intOrDefault(t[tiTempo].Value(), 0)
+	return intOrDefault(t.inputs[tiTempo].Value(), 0)
 }
 
 func (t tiRow) ThemeOrDefault() (int, error) {
-	return intOrDefault(t[tiTheme].Value(), 0)
+	return intOrDefault(t.inputs[tiTheme].Value(), 0)
 }
 
 func (t tiRow) DurationOrDefault() (int, error) {
-	return intOrDefault(t[tiDuration].Value(), 0)
+	return intOrDefault(t.inputs[tiDuration].Value(), 0)
+}
+
+func (t tiRow) Intensity() string {
+	return t.inputs[tiIntensity].Value()
+}
+
+func (t tiRow) Reel() string {
+	return t.inputs[tiReel].Value()
+}
+
+func (t tiRow) Start() string {
+	return t.inputs[tiStart].Value()
+}
+
+func (t tiRow) ReelOrDefault() (int, error) {
+	if err := validateReel(t.Reel()); err != nil {
+		return 0, err
+	}
+	return intOrDefault(t.Reel(), 0)
+}
+
+func (t tiRow) IntensityOrDefault() (int, error) {
+	if err := validateIntensity(t.Intensity()); err != nil {
+		return 0, err
+	}
+	return intOrDefault(t.Intensity(), 0)
 }
 
 type tiOpt func(row tiRow)
 
 func WithDuration(start string) tiOpt {
 	return func(ti tiRow) {
-		ti[tiDuration].SetValue(start)
+		ti.inputs[tiDuration].SetValue(start)
 	}
 }
 
 func WithTempo(tempo string) tiOpt {
 	return func(ti tiRow) {
-		ti[tiTempo].SetValue(tempo)
+		ti.inputs[tiTempo].SetValue(tempo)
 	}
 }
 
 func WithTheme(theme string) tiOpt {
 	return func(ti tiRow) {
-		ti[tiTheme].SetValue(theme)
+		ti.inputs[tiTheme].SetValue(theme)
+	}
+}
+
+func WithIntensity(intensity string) tiOpt {
+	return func(ti tiRow) {
+		ti.inputs[tiIntensity].SetValue(intensity)
+	}
+}
+
+func WithReel(reel string) tiOpt {
+	return func(ti tiRow) {
+		ti.inputs[tiReel].SetValue(reel)
+	}
+}
+
+func WithStart(timecode string) tiOpt {
+	return func(ti tiRow) {
+		ti.inputs[tiStart].SetValue(timecode)
 	}
 }
 
@@ -210,12 +308,12 @@ func NewInteractiveTable() *InteractiveTable {
 func (it *InteractiveTable) Header() string {
 	style := lipgloss.NewStyle()
 
-	names := []string{"Scene desc", "Tempo", "Keywords", "Theme", "Duration (sec)"}
+	names := []string{"Scene desc", "Tempo", "Keywords", "Theme", "Duration (sec)", "Intensity", "Reel", "Start"}
 	header := make([]string, len(names))
 	for i, name := range names {
 		width := 20
 		if len(it.iRows) > 0 {
-			width = it.iRows[0][i].Width
+			width = it.iRows[0].inputs[i].Width
 		}
 		header[i] = style.Width(width + 1).Render(name)
 	}
@@ -236,13 +334,21 @@ func (it *InteractiveTable) View() string {
 	return lipgloss.JoinVertical(lipgloss.Left, rows...)
 }
 
-func (it *InteractiveTable) activeTiRow() tiRow {
+func (it *InteractiveTable) activeTiRow() *tiRow {
 	if len(it.iRows) > 0 {
-		return it.iRows[it.cursor]
+		return &it.iRows[it.cursor]
 	}
 	return nil
 }
 
+// recordID returns the ID of the record shown in the row
+func (it *InteractiveTable) recordID(row int) uint {
+	if row < len(it.iRows) {
+		return it.iRows[row].id
+	}
+	return 0
+}
+
 func (it *InteractiveTable) deleteActiveRow() {
 	if it.cursor < len(it.iRows)-1 {
 		it.iRows = append(it.iRows[:it.cursor], it.iRows[it.cursor+1:]...)
@@ -303,7 +409,7 @@ func (it *InteractiveTable) blurActiveRow() {
 
 func (it *InteractiveTable) activateCurrentRow() {
 	if len(it.iRows) > 0 {
-		it.iRows[it.cursor][0].Focus()
+		it.iRows[it.cursor].inputs[0].Focus()
 	}
 }
 
@@ -321,20 +427,26 @@ func (it *InteractiveTable) interchangeRows(current, target int) {
 	it.activateCurrentRow()
 }
 
+// createNewRow appends a row in the same reel as the last row
 func (it *InteractiveTable) createNewRow() {
 	slog.Info("Creating new row")
-	newRow := NewTiRow()
-	it.iRows = append(it.iRows, newRow)
+	var opts []tiOpt
+	if len(it.iRows) > 0 {
+		opts = append(opts, WithReel(it.iRows[len(it.iRows)-1].Reel()))
+	}
+	it.iRows = append(it.iRows, NewTiRow(opts...))
 }
 
 func (it *InteractiveTable) toRecords(projectId uint) ([]db.ProjectContentRecord, error) {
 	rows := make([]db.ProjectContentRecord, len(it.iRows))
 	for i, row := range it.iRows {
 		var (
-			duration int
-			tempo    int
-			theme    int
-			ierr     error
+			duration  int
+			tempo     int
+			theme     int
+			intensity int
+			reel      int
+			ierr      error
 		)
 
 		err := utils.ReturnFirstError(
@@ -350,19 +462,35 @@ func (it *InteractiveTable) toRecords(projectId uint) ([]db.ProjectContentRecord
 				theme, ierr = row.ThemeOrDefault()
 				return ierr
 			},
+			func() error {
+				intensity, ierr = row.IntensityOrDefault()
+				return ierr
+			},
+			func() error {
+				reel, ierr = row.ReelOrDefault()
+				return ierr
+			},
 		)
+		if err == nil {
+			err = validateStart(row.Start())
+		}
 		if err != nil {
 			return rows, err
 		}
 
 		rows[i] = db.ProjectContentRecord{
+			ID:          it.recordID(i),
 			ProjectID:   projectId,
 			Scene:       uint(i),
-			SceneDesc:   row[tiScene].Value(),
+			SceneDesc:   row.inputs[tiScene].Value(),
 			DurationSec: duration,
-			Keywords:    row[tiKeywords].Value(),
+			Keywords:    row.inputs[tiKeywords].Value(),
 			Tempo:       uint(tempo),
 			Theme:       uint(theme),
+			Intensity:   uint(intensity),
+			Reel:        uint(reel),
+			Timecode:    strings.TrimSpace(row.Start()),
+			Pinned:      row.pinned,
 		}
 	}
 	return rows, nil
@@ -375,21 +503,43 @@ type ProjectWorkspace struct {
 	iTable       *InteractiveTable
 	library      compose.Library
 	creator      musicxml.Creator
+	composeOpts  []compose.ComposeOpt
 	initialWidth int
+
+	// Write one score per reel in addition to the combined score
+	scorePerReel bool
+
+	// Frame rate of the timecodes in marker files
+	frameRate float64
+
+	// Scenes as they were after each save in this session. Undo and redo
+	// move between them.
+	undoStack [][]db.ProjectContentRecord
+	undoPos   int
+
+	// Set when a save was refused since the project was changed elsewhere.
+	// Reloading and overwriting are only offered while it is set.
+	conflict bool
 }
 
 func (pw *ProjectWorkspace) Init() tea.Cmd {
 	pw.status = NewStatus()
-	pw.iTable = NewInteractiveTable()
+	pw.setRows(pw.project.Records)
+	pw.undoStack = [][]db.ProjectContentRecord{slices.Clone(pw.project.Records)}
+	pw.undoPos = 0
+	return nil
+}
 
-	for _, record := range pw.project.Records {
+// setRows fills the table with a row per record
+func (pw *ProjectWorkspace) setRows(records []db.ProjectContentRecord) {
+	pw.iTable = NewInteractiveTable()
+	for _, record := range records {
 		row := NewTiRowFromRecord(&record)
 		row.SetWidth(pw.initialWidth)
 		row.Blur()
 		pw.iTable.iRows = append(pw.iTable.iRows, row)
 	}
 	pw.iTable.activateCurrentRow()
-	return nil
 }
 
 func (pw *ProjectWorkspace) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -418,16 +568,59 @@ func (pw *ProjectWorkspace) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else {
 				pw.status.Set(fmt.Sprintf("Successfully deleted schene %d", pw.iTable.cursor), err)
 			}
+		case "ctrl+z":
+			pw.status.Set("Undid last change", pw.moveInHistory(-1))
+			return pw, nil
+		case "ctrl+y":
+			pw.status.Set("Redid last change", pw.moveInHistory(1))
+			return pw, nil
+		case "ctrl+x":
+			pw.status.Set("Reloaded project", pw.reload())
+			return pw, nil
+		case "ctrl+o":
+			pw.status.Set("Overwrote the changes made elsewhere", pw.overwrite())
+			return pw, nil
+		case "ctrl+l":
+			if err := pw.save(); err != nil {
+				pw.status.Set("", err)
+				break
+			}
+			return pw, func() tea.Msg {
+				return toProjectHistory{project: pw.project}
+			}
+		case "ctrl+t":
+			pw.project.DiversityWindow = (pw.project.DiversityWindow + 1) % (maxDiversityWindow + 1)
+			err := pw.save()
+			pw.status.Set(pw.diversityDescription(), err)
+		case "ctrl+r":
+			pw.project.ReadingSpeed = nextReadingSpeed(pw.project.ReadingSpeed)
+			err := pw.save()
+			pw.status.Set(pw.readingSpeedDescription(), err)
 		case "ctrl+g":
 			if err := pw.save(); err != nil {
 				pw.status.Set("", err)
 				break
 			}
 
-			score := compose.CreateComposition(pw.library, pw.project)
-			fname := musicxml.FileNameFromScore(score)
-			err := musicxml.WriteScoreToFile(pw.creator, fname, score)
-			pw.status.Set(fmt.Sprintf("Successfully stored compiled score to %s", fname), err)
+			composition := compose.CreateComposition(pw.library, pw.project, pw.composeOpts...)
+			fname := musicxml.FileNameFromScore(composition.Score)
+			if err := musicxml.WriteScoreToFile(pw.creator, fname, composition.Score); err != nil {
+				pw.status.Set("", err)
+				break
+			}
+			if pw.scorePerReel {
+				if err := pw.writeReels(composition.Reels); err != nil {
+					pw.status.Set("", err)
+					break
+				}
+			}
+			cueFiles, err := composition.CueSheet.WriteFiles(pw.creator, compose.CueSheetBaseName(fname))
+			if err != nil {
+				pw.status.Set("", err)
+				break
+			}
+			markerFiles, err := composition.CueSheet.WriteMarkers(pw.creator, compose.MarkersBaseName(fname), pw.frameRate)
+			pw.status.Set(fmt.Sprintf("Successfully stored compiled score to %s, cue sheet to %s and markers to %s", fname, strings.Join(cueFiles, ", "), strings.Join(markerFiles, ", ")), err)
 		}
 	}
 	pw.iTable.Update(msg)
@@ -436,8 +629,46 @@ func (pw *ProjectWorkspace) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (pw *ProjectWorkspace) View() string {
 
-	helpString := helpStyle.Render("\u2191/\u2193 up/down \u2022 \u2190/\u2192 left/right \u2022 shift+(\u2191/\u2193) move row up/down \u2022 ctrl+g: generate score \u2022 ctrl+c: quit")
-	return lipgloss.JoinVertical(lipgloss.Left, pw.iTable.View(), helpString, pw.status.Render("Edit"))
+	helpString := helpStyle.Render("\u2191/\u2193 up/down \u2022 \u2190/\u2192 left/right \u2022 shift+(\u2191/\u2193) move row up/down \u2022 ctrl+t: diversity window \u2022 ctrl+r: reading speed \u2022 ctrl+g: generate score \u2022 ctrl+z/ctrl+y: undo/redo \u2022 ctrl+l: history \u2022 ctrl+c: quit")
+	if pw.conflict {
+		helpString = lipgloss.JoinVertical(lipgloss.Left, helpString, helpStyle.Render("ctrl+x: reload the stored project \u2022 ctrl+o: overwrite the changes made elsewhere"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, pw.iTable.View(), helpStyle.Render(pw.diversityDescription()+" \u2022 "+pw.readingSpeedDescription()), helpString, pw.status.Render("Edit"))
+}
+
+func (pw *ProjectWorkspace) writeReels(reels []*musicxml.Scorepartwise) error {
+	for _, reel := range reels {
+		if err := musicxml.WriteScoreToFile(pw.creator, musicxml.FileNameFromScore(reel), reel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pw *ProjectWorkspace) diversityDescription() string {
+	if pw.project.DiversityWindow == 0 {
+		return "Pieces may repeat in consecutive scenes"
+	}
+	return fmt.Sprintf("Pieces used in the last %d scenes without a theme are avoided", pw.project.DiversityWindow)
+}
+
+func (pw *ProjectWorkspace) readingSpeedDescription() string {
+	speed := pw.project.ReadingSpeed
+	if speed <= 0.0 {
+		speed = db.DefaultReadingSpeed
+	}
+	return fmt.Sprintf("Intertitles are read at %.1f words per second", speed)
+}
+
+// nextReadingSpeed returns the reading speed following the current one. It
+// wraps around to the slowest speed.
+func nextReadingSpeed(current float64) float64 {
+	for _, speed := range readingSpeeds {
+		if speed > current {
+			return speed
+		}
+	}
+	return readingSpeeds[0]
 }
 
 func (pw *ProjectWorkspace) validate() error {
@@ -445,6 +676,9 @@ func (pw *ProjectWorkspace) validate() error {
 		err := utils.ReturnFirstError(
 			func() error { return validateDuration(item.Duration()) },
 			func() error { return validateTempo(item.Tempo()) },
+			func() error { return validateIntensity(item.Intensity()) },
+			func() error { return validateReel(item.Reel()) },
+			func() error { return validateStart(item.Start()) },
 		)
 
 		if err != nil {
@@ -461,7 +695,111 @@ func (pw *ProjectWorkspace) save() error {
 		return err
 	}
 	pw.project.Records = records
-	return pw.store.Save(pw.project)
+	pw.project.SyncReels()
+	if err := pw.store.Save(pw.project); err != nil {
+		if errors.Is(err, db.ErrConflict) {
+			pw.conflict = true
+			return fmt.Errorf("%w: press ctrl+x to reload it or ctrl+o to overwrite the changes", err)
+		}
+		return err
+	}
+	pw.conflict = false
+
+	// New rows keep the IDs their records got when saved
+	for i, record := range pw.project.Records {
+		pw.iTable.iRows[i].id = record.ID
+	}
+	pw.pushUndo(pw.project.Records)
+	return nil
+}
+
+// storedProject returns the project as it is in the store
+func (pw *ProjectWorkspace) storedProject() (*db.Project, error) {
+	projects, err := pw.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(projects, func(p db.Project) bool { return p.Id == pw.project.Id })
+	if i < 0 {
+		return nil, ErrProjectDeleted
+	}
+	return &projects[i], nil
+}
+
+// reload replaces the project by the stored one after a conflicting save.
+// The edits that could not be saved are discarded, while the scenes as last
+// saved in this session can be brought back with undo.
+func (pw *ProjectWorkspace) reload() error {
+	if !pw.conflict {
+		return ErrNoConflict
+	}
+	stored, err := pw.storedProject()
+	if err != nil {
+		return err
+	}
+	*pw.project = *stored
+	pw.conflict = false
+	pw.setRows(pw.project.Records)
+	pw.pushUndo(pw.project.Records)
+	return nil
+}
+
+// overwrite saves the project over changes made elsewhere since it was
+// loaded, after a conflicting save
+func (pw *ProjectWorkspace) overwrite() error {
+	if !pw.conflict {
+		return ErrNoConflict
+	}
+	stored, err := pw.storedProject()
+	if errors.Is(err, ErrProjectDeleted) {
+		return pw.save()
+	}
+	if err != nil {
+		return err
+	}
+	pw.project.Version = stored.Version
+	return pw.save()
+}
+
+// pushUndo adds the saved records to the undo stack unless they are the same
+// as the current entry. Changes that were undone can no longer be redone.
+func (pw *ProjectWorkspace) pushUndo(records []db.ProjectContentRecord) {
+	if len(pw.undoStack) > 0 && len(db.DiffRecords(pw.undoStack[pw.undoPos], records)) == 0 {
+		return
+	}
+	pw.undoStack = append(pw.undoStack[:pw.undoPos+1], slices.Clone(records))
+	pw.undoPos = len(pw.undoStack) - 1
+}
+
+// moveInHistory replaces the scenes by those saved the given number of steps
+// back or forward in the undo stack and saves the project. Unsaved edits are
+// saved first such that they can be redone.
+func (pw *ProjectWorkspace) moveInHistory(step int) error {
+	if err := pw.save(); err != nil {
+		return err
+	}
+	pos := pw.undoPos + step
+	summary := "Undo"
+	switch {
+	case pos < 0:
+		return ErrNothingToUndo
+	case pos >= len(pw.undoStack):
+		return ErrNothingToRedo
+	case step > 0:
+		summary = "Redo"
+	}
+
+	records := slices.Clone(pw.undoStack[pos])
+	pw.project.Records = records
+	pw.project.SyncReels()
+	pw.project.ChangeSummary = summary
+	defer func() { pw.project.ChangeSummary = "" }()
+	if err := pw.store.Save(pw.project); err != nil {
+		return err
+	}
+	pw.undoPos = pos
+	pw.setRows(records)
+	return nil
 }
 
 func validateDuration(duration string) error {
@@ -486,6 +824,39 @@ func validateTempo(tempo string) error {
 	return nil
 }
 
+func validateIntensity(intensity string) error {
+	if intensity == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(intensity)
+	if err != nil || value < 1 || value > 5 {
+		return ErrIntensityOutOfRange
+	}
+	return nil
+}
+
+func validateReel(reel string) error {
+	if reel == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(reel)
+	if err != nil || value < 1 {
+		return ErrReelMustBePositive
+	}
+	return nil
+}
+
+func validateStart(timecode string) error {
+	timecode = strings.TrimSpace(timecode)
+	if timecode == "" {
+		return nil
+	}
+	if _, err := db.ParseClock(timecode); err != nil {
+		return ErrStartMustBeClockTime
+	}
+	return nil
+}
+
 func intOrDefault(value string, defaultValue int) (int, error) {
 	if value != "" {
 		return strconv.Atoi(value)
@@ -2,7 +2,9 @@ package ui
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -22,7 +24,7 @@ func initializedPw() ProjectWorkspace {
 
 func numFocused(row tiRow) int {
 	num := 0
-	for _, item := range row {
+	for _, item := range row.inputs {
 		if item.Focused() {
 			num += 1
 		}
@@ -61,7 +63,7 @@ func TestInteravtoveTableEvent(t *testing.T) {
 				}
 
 				// No element in row 0 should be focused
-				for i, item := range it.iRows[0] {
+				for i, item := range it.iRows[0].inputs {
 					if item.Focused() {
 						t.Errorf("%d-th item in row 0 is focused", i)
 					}
@@ -180,9 +182,49 @@ func TestValidateTempo(t *testing.T) {
 	}
 }
 
+func TestValidateIntensity(t *testing.T) {
+	for _, test := range []struct {
+		intensity string
+		err       error
+		desc      string
+	}{
+		{
+			intensity: "3",
+			err:       nil,
+			desc:      "Valid intensity",
+		},
+		{
+			intensity: "",
+			err:       nil,
+			desc:      "Empty intensity",
+		},
+		{
+			intensity: "6",
+			err:       ErrIntensityOutOfRange,
+			desc:      "Too high intensity",
+		},
+		{
+			intensity: "0",
+			err:       ErrIntensityOutOfRange,
+			desc:      "Too low intensity",
+		},
+		{
+			intensity: "ff",
+			err:       ErrIntensityOutOfRange,
+			desc:      "Intensity is not an integer",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			if err := validateIntensity(test.intensity); err != test.err {
+				t.Errorf("Wanted %v got %v", test.err, err)
+			}
+		})
+	}
+}
+
 func TestBlur(t *testing.T) {
 	row := NewTiRow()
-	row[1].Focus()
+	row.inputs[1].Focus()
 
 	if n := numFocused(row); n != 2 {
 		t.Errorf("2 item should be focused got %d", n)
@@ -228,7 +270,7 @@ func TestActive(t *testing.T) {
 		t.Errorf("Active should be zero when no row is active")
 	}
 
-	row[2].Focus()
+	row.inputs[2].Focus()
 	if a := row.Active(); a != 2 {
 		t.Errorf("Wanted 2 got %d", a)
 	}
@@ -287,7 +329,7 @@ func TestProjectWorkspaceEnterOk(t *testing.T) {
 func TestProjectWorkspaceEnterErr(t *testing.T) {
 	pw := initializedPw()
 	pw.iTable.createNewRow()
-	pw.iTable.iRows[0][tiDuration].SetValue("01:00")
+	pw.iTable.iRows[0].inputs[tiDuration].SetValue("01:00")
 	pw.Update(tea.KeyMsg{Type: tea.KeyEnter})
 
 	if pw.status.msg != ErrDurationMustBeInteger.Error() {
@@ -312,6 +354,22 @@ func TestValidate(t *testing.T) {
 			row: NewTiRow(WithTempo("andante")),
 			err: ErrTempoMustBeInteger,
 		},
+		{
+			row: NewTiRow(WithIntensity("9")),
+			err: ErrIntensityOutOfRange,
+		},
+		{
+			row: NewTiRow(WithReel("0")),
+			err: ErrReelMustBePositive,
+		},
+		{
+			row: NewTiRow(WithStart("01:00:61")),
+			err: ErrStartMustBeClockTime,
+		},
+		{
+			row: NewTiRow(WithStart("01:00:10.5")),
+			err: nil,
+		},
 	} {
 		pw := initializedPw()
 		pw.iTable.iRows = append(pw.iTable.iRows, test.row)
@@ -349,7 +407,7 @@ func TestInterchange(t *testing.T) {
 			pw.iTable.interchangeRows(test.current, test.target)
 
 			for i, row := range pw.iTable.iRows {
-				if v := row[tiDuration].Value(); v != test.want[i] {
+				if v := row.inputs[tiDuration].Value(); v != test.want[i] {
 					t.Errorf("Wanted %s got %s", test.want[i], v)
 				}
 			}
@@ -365,7 +423,7 @@ func TestShiftKeyUpDown(t *testing.T) {
 	pw.iTable.Update(tea.KeyMsg{Type: tea.KeyShiftDown})
 	want := []string{"12", "10"}
 	for i, row := range pw.iTable.iRows {
-		if v := row[tiTempo].Value(); v != want[i] {
+		if v := row.inputs[tiTempo].Value(); v != want[i] {
 			t.Errorf("Wanted %s got %s", want[i], v)
 		}
 	}
@@ -378,7 +436,7 @@ func TestShiftKeyUpDown(t *testing.T) {
 	pw.iTable.Update(tea.KeyMsg{Type: tea.KeyShiftUp})
 	want = []string{"10", "12"}
 	for i, row := range pw.iTable.iRows {
-		if v := row[tiTempo].Value(); v != want[i] {
+		if v := row.inputs[tiTempo].Value(); v != want[i] {
 			t.Errorf("Wanted %s got %s", want[i], v)
 		}
 	}
@@ -454,7 +512,17 @@ func TestToRecords(t *testing.T) {
 			desc:       "Wrong tempo (should be integer)",
 		},
 		{
-			rows:       []tiRow{NewTiRow(WithDuration("2"), WithTempo("88"), WithTheme("0"))},
+			rows:       []tiRow{NewTiRow(WithIntensity("7"))},
+			shouldFail: true,
+			desc:       "Intensity out of range",
+		},
+		{
+			rows:       []tiRow{NewTiRow(WithReel("first"))},
+			shouldFail: true,
+			desc:       "Reel is not an integer",
+		},
+		{
+			rows:       []tiRow{NewTiRow(WithDuration("2"), WithTempo("88"), WithTheme("0"), WithIntensity("4"))},
 			shouldFail: false,
 			desc:       "Valid row",
 		},
@@ -672,36 +740,59 @@ func (c *customFileCreator) Create(name string) (musicxml.WriterCloser, error) {
 	return os.Create(c.name)
 }
 
+// dirFileCreator creates the files in a directory
+type dirFileCreator struct {
+	dir string
+}
+
+func (c *dirFileCreator) Create(name string) (musicxml.WriterCloser, error) {
+	return os.Create(filepath.Join(c.dir, name))
+}
+
 func TestGenerateScore(t *testing.T) {
-	tmpFile := t.TempDir() + "/test.xml"
+	dir := t.TempDir()
 	pw := ProjectWorkspace{
 		store:   db.NewInMemoryProjectStore(),
-		project: db.NewProject(db.WithName("my-project")),
+		project: db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{{SceneDesc: "Opening", DurationSec: 30}})),
 		library: compose.NewStandardLibrary(),
-		creator: &customFileCreator{name: tmpFile},
+		creator: &dirFileCreator{dir: dir},
 	}
 	pw.Init()
 
 	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
 
-	content, err := os.ReadFile(tmpFile)
-	if err != nil {
-		t.Error(err)
-		return
-	}
-	if !strings.Contains(string(content), "<score-partwise") {
-		t.Errorf("Wanted to find <score-piecewise> in file %s", string(content))
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{name: "my-project.musicxml", want: "<score-partwise"},
+		{name: "my-project_cues.musicxml", want: "<score-partwise"},
+		{name: "my-project_cues.md", want: "| Cue | Start | End |"},
+		{name: "my-project_cues.csv", want: "Cue,Start,End"},
+		{name: "my-project_markers.edl", want: "FCM: NON-DROP FRAME"},
+		{name: "my-project_markers.fcpxml", want: "<fcpxml"},
+		{name: "my-project_markers.srt", want: "1M1"},
+		{name: "my-project_markers.srt", want: " --> "},
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, test.name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(content), test.want) {
+			t.Errorf("Wanted to find %s in %s got %s", test.want, test.name, string(content))
+		}
 	}
 }
 
 func TestWidth(t *testing.T) {
 	row := NewTiRow(WithWidth(250))
 	totalWidth := 0
-	for _, item := range row {
+	for _, item := range row.inputs {
 		totalWidth += item.Width
 	}
 
-	expect := 250 - 2*rowPadding - 4
+	expect := 250 - 2*rowPadding - 7
 	if totalWidth != expect {
 		t.Errorf("Wanted total width to be %d got %d", expect, totalWidth)
 	}
@@ -711,7 +802,7 @@ func uniqueWidths(rows []tiRow) map[int]struct{} {
 	unique := make(map[int]struct{})
 	for _, item := range rows {
 		totalWidth := 0
-		for _, col := range item {
+		for _, col := range item.inputs {
 			totalWidth += col.Width
 		}
 		unique[totalWidth] = struct{}{}
@@ -753,3 +844,336 @@ func TestWindowResize(t *testing.T) {
 		}
 	}
 }
+
+func TestCycleDiversityWindow(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	pw := ProjectWorkspace{
+		store:   store,
+		project: db.NewProject(db.WithName("my-project"), db.WithDiversityWindow(maxDiversityWindow-1)),
+	}
+	pw.Init()
+
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	if pw.project.DiversityWindow != maxDiversityWindow {
+		t.Errorf("Wanted diversity window %d got %d", maxDiversityWindow, pw.project.DiversityWindow)
+	}
+	if !strings.Contains(pw.View(), fmt.Sprintf("last %d scenes", maxDiversityWindow)) {
+		t.Errorf("Wanted diversity window in view got %s", pw.View())
+	}
+
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	if pw.project.DiversityWindow != 0 {
+		t.Errorf("Wanted diversity window to wrap around to 0 got %d", pw.project.DiversityWindow)
+	}
+	if !strings.Contains(pw.View(), "Pieces may repeat") {
+		t.Errorf("Wanted disabled diversity penalty in view got %s", pw.View())
+	}
+
+	projects, err := store.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(projects) != 1 || projects[0].DiversityWindow != 0 {
+		t.Errorf("Wanted the diversity window to be stored got %+v", projects)
+	}
+}
+
+func TestCycleReadingSpeed(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	pw := ProjectWorkspace{
+		store:   store,
+		project: db.NewProject(db.WithName("my-project"), db.WithReadingSpeed(2.5)),
+	}
+	pw.Init()
+
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if pw.project.ReadingSpeed != 3.0 {
+		t.Errorf("Wanted reading speed 3.0 got %f", pw.project.ReadingSpeed)
+	}
+	if !strings.Contains(pw.View(), "3.0 words per second") {
+		t.Errorf("Wanted reading speed in view got %s", pw.View())
+	}
+
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if pw.project.ReadingSpeed != readingSpeeds[0] {
+		t.Errorf("Wanted reading speed to wrap around to %f got %f", readingSpeeds[0], pw.project.ReadingSpeed)
+	}
+
+	projects, err := store.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(projects) != 1 || projects[0].ReadingSpeed != readingSpeeds[0] {
+		t.Errorf("Wanted the reading speed to be stored got %+v", projects)
+	}
+}
+
+func TestNewRowContinuesReel(t *testing.T) {
+	table := NewInteractiveTable()
+	table.iRows = append(table.iRows, NewTiRow(WithReel("3")))
+	table.createNewRow()
+	if reel := table.iRows[1].Reel(); reel != "3" {
+		t.Errorf("Wanted new row in reel 3 got %q", reel)
+	}
+}
+
+func TestSaveSyncsReels(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	pw := ProjectWorkspace{store: store, project: db.NewProject(db.WithName("my-project"))}
+	pw.Init()
+	pw.iTable.iRows = append(pw.iTable.iRows, NewTiRow(), NewTiRow(WithReel("2")))
+	if err := pw.save(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	projects, err := store.Load()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(projects) != 1 || len(projects[0].Reels) != 2 || projects[0].Records[1].Reel != 2 {
+		t.Errorf("Wanted two reels got %+v", projects)
+	}
+}
+
+func TestGenerateScorePerReel(t *testing.T) {
+	dir := t.TempDir()
+	project := db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{
+		{SceneDesc: "Opening", Keywords: "waltz", DurationSec: 20, Reel: 1},
+		{SceneDesc: "Chase", Keywords: "galop", DurationSec: 20, Reel: 2},
+	}))
+	pw := ProjectWorkspace{
+		store:        db.NewInMemoryProjectStore(),
+		project:      project,
+		library:      compose.NewStandardLibrary(),
+		creator:      &dirFileCreator{dir: dir},
+		scorePerReel: true,
+	}
+	pw.Init()
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlG})
+
+	for _, name := range []string{"my-project.musicxml", "my-project_-_Reel_1.musicxml", "my-project_-_Reel_2.musicxml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Wanted %s to be written: %s", name, err)
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{
+		{Scene: 0, SceneDesc: "Opening"},
+		{Scene: 1, SceneDesc: "Chase"},
+	}))
+	if err := store.Save(project); err != nil {
+		t.Fatal(err)
+	}
+	pw := ProjectWorkspace{store: store, project: project}
+	pw.Init()
+
+	descriptions := func() string {
+		var desc []string
+		for _, row := range pw.iTable.iRows {
+			desc = append(desc, row.inputs[tiScene].Value())
+		}
+		return strings.Join(desc, ",")
+	}
+
+	for i, step := range []struct {
+		key  tea.KeyType
+		want string
+		err  bool
+	}{
+		{key: tea.KeyCtrlZ, want: "Opening,Chase", err: true},
+		{key: tea.KeyDelete, want: "Chase"},
+		{key: tea.KeyCtrlZ, want: "Opening,Chase"},
+		{key: tea.KeyCtrlY, want: "Chase"},
+		{key: tea.KeyCtrlY, want: "Chase", err: true},
+		{key: tea.KeyCtrlZ, want: "Opening,Chase"},
+	} {
+		pw.Update(tea.KeyMsg{Type: step.key})
+		if got := descriptions(); got != step.want {
+			t.Errorf("Step #%d: Wanted %s got %s", i, step.want, got)
+		}
+		if (pw.status.kind == errorStatus) != step.err {
+			t.Errorf("Step #%d: Wanted error %v got status %q", i, step.err, pw.status.msg)
+		}
+		if len(project.Records) != len(pw.iTable.iRows) {
+			t.Errorf("Step #%d: Wanted the project to be saved with %d scenes got %d", i, len(pw.iTable.iRows), len(project.Records))
+		}
+	}
+
+	snapshots, err := store.History(project.Id)
+	if err != nil || len(snapshots) != 5 || snapshots[0].Summary != "Undo" || snapshots[1].Summary != "Redo" {
+		t.Errorf("Wanted snapshots of undo and redo got %v (%v)", snapshots, err)
+	}
+}
+
+func TestUndoKeepsUnsavedEdits(t *testing.T) {
+	pw := initializedPw()
+	pw.Update(tea.KeyMsg{Type: tea.KeyDown})
+	pw.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if len(pw.iTable.iRows) != 0 {
+		t.Errorf("Wanted no rows after undo got %d", len(pw.iTable.iRows))
+	}
+
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlY})
+	if len(pw.iTable.iRows) != 1 || pw.iTable.iRows[0].inputs[tiScene].Value() != "a" {
+		t.Errorf("Wanted the edit to be redone")
+	}
+}
+
+func TestCtrlLOpensHistory(t *testing.T) {
+	pw := initializedPw()
+	_, cmd := pw.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	if cmd == nil {
+		t.Fatal("Wanted command to open the history")
+	}
+	if msg, ok := cmd().(toProjectHistory); !ok || msg.project != pw.project {
+		t.Errorf("Wanted history of the project got %v", msg)
+	}
+}
+
+func TestRowsKeepRecordIDs(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{
+		{Scene: 0, SceneDesc: "Opening"},
+		{Scene: 1, SceneDesc: "Chase"},
+		{Scene: 2, SceneDesc: "Finale"},
+	}))
+	if err := store.Save(project); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]uint)
+	for _, record := range project.Records {
+		ids[record.SceneDesc] = record.ID
+	}
+
+	pw := ProjectWorkspace{store: store, project: project}
+	pw.Init()
+	pw.Update(tea.KeyMsg{Type: tea.KeyShiftDown})
+	pw.Update(tea.KeyMsg{Type: tea.KeyDelete})
+	pw.Update(tea.KeyMsg{Type: tea.KeyDown})
+	pw.Update(tea.KeyMsg{Type: tea.KeyDown})
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+
+	var got []string
+	for _, record := range project.Records {
+		got = append(got, record.SceneDesc)
+		if record.SceneDesc != "" && record.ID != ids[record.SceneDesc] {
+			t.Errorf("Wanted %s to keep ID %d got %d", record.SceneDesc, ids[record.SceneDesc], record.ID)
+		}
+	}
+	if strings.Join(got, ",") != "Chase,Finale," {
+		t.Errorf("Wanted Chase, Finale and a new scene got %v", got)
+	}
+	if id := project.Records[2].ID; id == 0 || id == ids["Opening"] || pw.iTable.recordID(2) != id {
+		t.Errorf("Wanted the new row to get a new ID got %d", id)
+	}
+}
+
+func TestSaveKeepsPinnedPiece(t *testing.T) {
+	store := db.NewInMemoryProjectStore()
+	project := db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{
+		{Scene: 0, SceneDesc: "Opening", Pinned: "abc"},
+		{Scene: 1, SceneDesc: "Chase"},
+	}))
+	if err := store.Save(project); err != nil {
+		t.Fatal(err)
+	}
+
+	pw := ProjectWorkspace{store: store, project: project}
+	pw.Init()
+	pw.Update(tea.KeyMsg{Type: tea.KeyShiftDown})
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+
+	var got []string
+	for _, record := range project.Records {
+		got = append(got, record.SceneDesc+":"+record.Pinned)
+	}
+	if want := "Chase:,Opening:abc"; strings.Join(got, ",") != want {
+		t.Errorf("Wanted %v got %v", want, got)
+	}
+}
+
+func TestConflictingSave(t *testing.T) {
+	for _, test := range []struct {
+		key  tea.KeyType
+		want string
+	}{
+		{key: tea.KeyCtrlX, want: "Theirs"},
+		{key: tea.KeyCtrlO, want: "Mine"},
+	} {
+		store := db.NewInMemoryProjectStore()
+		project := db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{{Scene: 0, SceneDesc: "Opening"}}))
+		if err := store.Save(project); err != nil {
+			t.Fatal(err)
+		}
+		pw := ProjectWorkspace{store: store, project: project}
+		pw.Init()
+
+		// Another instance saves the project in the meantime
+		other := *project
+		other.Records = []db.ProjectContentRecord{{Scene: 0, SceneDesc: "Theirs"}}
+		if err := store.Save(&other); err != nil {
+			t.Fatal(err)
+		}
+
+		pw.iTable.iRows[0].inputs[tiScene].SetValue("Mine")
+		pw.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+		if pw.status.kind != errorStatus || !strings.Contains(pw.status.msg, "ctrl+x") {
+			t.Errorf("Wanted a conflict with a choice to reload or overwrite got %q", pw.status.msg)
+		}
+		if !strings.Contains(pw.View(), "ctrl+o: overwrite") {
+			t.Errorf("Wanted reload and overwrite offered during the conflict")
+		}
+
+		pw.Update(tea.KeyMsg{Type: test.key})
+		if pw.status.kind == errorStatus || pw.conflict {
+			t.Errorf("Wanted the conflict to be resolved got %q", pw.status.msg)
+		}
+		if got := pw.iTable.iRows[0].inputs[tiScene].Value(); got != test.want {
+			t.Errorf("Wanted %s got %s", test.want, got)
+		}
+		projects, err := store.Load()
+		if err != nil || projects[0].Records[0].SceneDesc != test.want {
+			t.Errorf("Wanted %s to be stored got %+v (%v)", test.want, projects, err)
+		}
+	}
+}
+
+func TestReloadOnlyAfterConflict(t *testing.T) {
+	for _, key := range []tea.KeyType{tea.KeyCtrlX, tea.KeyCtrlO} {
+		store := db.NewInMemoryProjectStore()
+		project := db.NewProject(db.WithName("my-project"), db.WithRecords([]db.ProjectContentRecord{{Scene: 0, SceneDesc: "Opening"}}))
+		if err := store.Save(project); err != nil {
+			t.Fatal(err)
+		}
+		pw := ProjectWorkspace{store: store, project: project}
+		pw.Init()
+		pw.iTable.iRows[0].inputs[tiScene].SetValue("Unsaved")
+		pw.Update(tea.KeyMsg{Type: key})
+		if pw.status.msg != ErrNoConflict.Error() {
+			t.Errorf("Wanted %q got %q", ErrNoConflict, pw.status.msg)
+		}
+		if got := pw.iTable.iRows[0].inputs[tiScene].Value(); got != "Unsaved" {
+			t.Errorf("Wanted the edit kept got %s", got)
+		}
+		if strings.Contains(pw.View(), "ctrl+x") {
+			t.Errorf("Wanted reload to be offered only after a conflict")
+		}
+	}
+}
+
+func TestReloadDeletedProject(t *testing.T) {
+	pw := initializedPw()
+	pw.conflict = true
+	pw.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+	if pw.status.msg != ErrProjectDeleted.Error() {
+		t.Errorf("Wanted %q got %q", ErrProjectDeleted, pw.status.msg)
+	}
+}
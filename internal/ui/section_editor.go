@@ -0,0 +1,232 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+const noMark = -1
+
+type editorFocus int
+
+const (
+	focusMeasures editorFocus = iota
+	focusSections
+)
+
+// SectionEditorView lets the user define, rename and disable the sections
+// of a piece. The sections are stored by the fingerprint of the piece.
+type SectionEditorView struct {
+	store         db.SectionStore
+	lib           compose.Library
+	piece         compose.LibraryContent
+	measureText   []string
+	sections      []db.PieceSection
+	measureCursor int
+	sectionCursor int
+	mark          int
+	focus         editorFocus
+	renaming      bool
+	nameInput     textinput.Model
+	status        *Status
+	width         int
+	height        int
+}
+
+func (s *SectionEditorView) Init() tea.Cmd {
+	s.status = NewStatus()
+	s.mark = noMark
+	s.nameInput = textinput.New()
+	s.nameInput.Prompt = "Name: "
+
+	measures := compose.PlayableMeasures(s.lib.Piece(s.piece.Fingerprint))
+	s.measureText = make([]string, len(measures))
+	for i, measure := range measures {
+		var texts []string
+		for _, item := range musicxml.DirectionFromMeasure(measure) {
+			texts = append(texts, item.Text)
+		}
+		s.measureText[i] = strings.Join(texts, " ")
+	}
+
+	sections, err := s.store.LoadSections(s.piece.Fingerprint)
+	if err != nil {
+		s.status.Set("", err)
+		return nil
+	}
+	s.sections = sections
+	if len(s.sections) == 0 {
+		s.sections = compose.SuggestedSections(measures)
+		s.status.Set("No sections stored. Showing suggested sections", nil)
+	}
+	return nil
+}
+
+func (s *SectionEditorView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.width = msg.Width
+		s.height = msg.Height
+	case tea.KeyMsg:
+		if s.renaming {
+			return s, s.updateRename(msg)
+		}
+		switch msg.String() {
+		case "esc":
+			return s, func() tea.Msg {
+				return toLibraryContent{}
+			}
+		case "tab":
+			if s.focus == focusMeasures {
+				s.focus = focusSections
+			} else {
+				s.focus = focusMeasures
+			}
+		case "up":
+			s.moveCursor(-1)
+		case "down":
+			s.moveCursor(1)
+		case " ":
+			if s.focus == focusMeasures {
+				s.markBar()
+			}
+		case "r":
+			if s.focus == focusSections && len(s.sections) > 0 {
+				s.renaming = true
+				s.nameInput.SetValue(s.sections[s.sectionCursor].Name)
+				return s, s.nameInput.Focus()
+			}
+		case "d":
+			if s.focus == focusSections && len(s.sections) > 0 {
+				s.sections[s.sectionCursor].Disabled = !s.sections[s.sectionCursor].Disabled
+			}
+		case "delete", "x":
+			if s.focus == focusSections && len(s.sections) > 0 {
+				s.sections = append(s.sections[:s.sectionCursor], s.sections[s.sectionCursor+1:]...)
+				s.sectionCursor = confine(s.sectionCursor, 0, max(len(s.sections)-1, 0))
+			}
+		case "ctrl+s":
+			err := s.store.SaveSections(s.piece.Fingerprint, s.sections)
+			s.status.Set(fmt.Sprintf("Stored %d sections for %s", len(s.sections), s.piece.ScoreTitle), err)
+		}
+	}
+	return s, nil
+}
+
+func (s *SectionEditorView) updateRename(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "enter":
+		s.sections[s.sectionCursor].Name = s.nameInput.Value()
+		fallthrough
+	case "esc":
+		s.renaming = false
+		s.nameInput.Blur()
+		return nil
+	}
+	var cmd tea.Cmd
+	s.nameInput, cmd = s.nameInput.Update(msg)
+	return cmd
+}
+
+func (s *SectionEditorView) moveCursor(step int) {
+	if s.focus == focusMeasures {
+		s.measureCursor = confine(s.measureCursor+step, 0, max(len(s.measureText)-1, 0))
+	} else {
+		s.sectionCursor = confine(s.sectionCursor+step, 0, max(len(s.sections)-1, 0))
+	}
+}
+
+// markBar marks the first bar of a new section. When a bar is already marked,
+// a section spanning from the marked bar to the current bar is added.
+func (s *SectionEditorView) markBar() {
+	if len(s.measureText) == 0 {
+		return
+	}
+	if s.mark == noMark {
+		s.mark = s.measureCursor
+		s.status.Set(fmt.Sprintf("Section starts at bar %d. Press space at the last bar", s.mark+1), nil)
+		return
+	}
+	first, last := min(s.mark, s.measureCursor), max(s.mark, s.measureCursor)
+	s.sections = append(s.sections, db.PieceSection{
+		Name:     fmt.Sprintf("Section %d", len(s.sections)+1),
+		FirstBar: first + 1,
+		LastBar:  last + 1,
+	})
+	s.mark = noMark
+	s.status.Set(fmt.Sprintf("Added section with bars %d-%d", first+1, last+1), nil)
+}
+
+func (s *SectionEditorView) sectionsWithBar(bar int) string {
+	var names []string
+	for _, section := range s.sections {
+		if bar >= section.FirstBar && bar <= section.LastBar {
+			names = append(names, section.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func cursorPrefix(active bool) string {
+	if active {
+		return selection_arrow + " "
+	}
+	return "  "
+}
+
+// visibleRange returns the range of num lines to show such that the cursor is visible
+func visibleRange(cursor, num, height int) (int, int) {
+	if height <= 0 || num <= height {
+		return 0, num
+	}
+	start := confine(cursor-height/2, 0, num-height)
+	return start, start + height
+}
+
+func (s *SectionEditorView) measuresView() string {
+	start, end := visibleRange(s.measureCursor, len(s.measureText), s.height-8)
+	lines := []string{"Bar  Sections      Text"}
+	for i := start; i < end; i++ {
+		mark := " "
+		if i == s.mark {
+			mark = "["
+		}
+		line := fmt.Sprintf("%s%s%4d %-12s %s", cursorPrefix(s.focus == focusMeasures && i == s.measureCursor), mark, i+1, s.sectionsWithBar(i+1), s.measureText[i])
+		lines = append(lines, line)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (s *SectionEditorView) sectionsView() string {
+	lines := []string{"Sections"}
+	for i, section := range s.sections {
+		disabled := ""
+		if section.Disabled {
+			disabled = " (disabled)"
+		}
+		line := fmt.Sprintf("%s%s: bars %d-%d%s", cursorPrefix(s.focus == focusSections && i == s.sectionCursor), section.Name, section.FirstBar, section.LastBar, disabled)
+		lines = append(lines, line)
+	}
+	if s.renaming {
+		lines = append(lines, s.nameInput.View())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (s *SectionEditorView) View() string {
+	columnStyle := lipgloss.NewStyle().Width(max(s.width/2-2, 20))
+	content := []string{
+		pad2.Render(fmt.Sprintf("%s (%s)", s.piece.ScoreTitle, s.piece.Composer)),
+		pad2.Render(lipgloss.JoinHorizontal(lipgloss.Top, columnStyle.Render(s.measuresView()), columnStyle.Render(s.sectionsView()))),
+		helpStyle.Render("tab: switch list \u2022 space: mark first/last bar \u2022 r: rename \u2022 d: disable/enable \u2022 x: delete \u2022 ctrl+s: save \u2022 esc: back"),
+		s.status.Render("Sections"),
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, content...)
+}
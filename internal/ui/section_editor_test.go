@@ -0,0 +1,190 @@
+package ui
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/davidkleiven/silent-score/internal/compose"
+	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/musicxml"
+)
+
+func sectionEditor(t *testing.T, store db.SectionStore) *SectionEditorView {
+	measures := []musicxml.Measure{
+		*musicxml.NewMeasure(),
+		*musicxml.NewMeasure(musicxml.WithRehersalMark("A")),
+		*musicxml.NewMeasure(musicxml.WithDirection(musicxml.NewDirection(musicxml.WithWords("Agitato")))),
+		*musicxml.NewMeasure(musicxml.WithRehersalMark("B")),
+		*musicxml.NewMeasure(),
+	}
+	for i := range measures {
+		measures[i].NumberAttr = strconv.Itoa(i + 1)
+	}
+	lib := &compose.InMemoryLibrary{Scores: []*musicxml.Scorepartwise{
+		musicxml.NewScorePartwise(musicxml.WithComposer("Chopin"), musicxml.WithPart(musicxml.Part{Measure: measures})),
+	}}
+
+	view := &SectionEditorView{store: store, lib: lib, piece: lib.Content()[0], width: 120, height: 40}
+	view.Init()
+	if len(view.measureText) != len(measures) {
+		t.Fatalf("Wanted %d measures got %d", len(measures), len(view.measureText))
+	}
+	return view
+}
+
+func pressKeys(model tea.Model, keys ...string) tea.Cmd {
+	var cmd tea.Cmd
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "up":
+			msg = tea.KeyMsg{Type: tea.KeyUp}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		case "tab":
+			msg = tea.KeyMsg{Type: tea.KeyTab}
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "space":
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+		case "ctrl+s":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlS}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		_, cmd = model.Update(msg)
+	}
+	return cmd
+}
+
+func TestSectionEditorSuggestsSections(t *testing.T) {
+	view := sectionEditor(t, db.NewInMemorySectionStore())
+	if len(view.sections) != 3 || view.sections[1].Name != "A" {
+		t.Errorf("Wanted suggested sections got %+v", view.sections)
+	}
+
+	result := view.View()
+	for _, substr := range []string{"Agitato", "A: bars 2-3", "B: bars 4-5", "Chopin"} {
+		if !strings.Contains(result, substr) {
+			t.Errorf("Expected %s in view, got %s", substr, result)
+		}
+	}
+}
+
+func TestSectionEditorLoadsStoredSections(t *testing.T) {
+	store := db.NewInMemorySectionStore()
+	view := sectionEditor(t, store)
+	store.SaveSections(view.piece.Fingerprint, []db.PieceSection{{Name: "Stored", FirstBar: 1, LastBar: 5}})
+
+	view.Init()
+	if len(view.sections) != 1 || view.sections[0].Name != "Stored" {
+		t.Errorf("Wanted stored section got %+v", view.sections)
+	}
+}
+
+func TestSectionEditorAddSection(t *testing.T) {
+	store := db.NewInMemorySectionStore()
+	view := sectionEditor(t, store)
+	view.sections = nil
+
+	pressKeys(view, "down", "down", "down", "space", "up", "up", "space", "ctrl+s")
+
+	sections, err := store.LoadSections(view.piece.Fingerprint)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(sections) != 1 || sections[0].FirstBar != 2 || sections[0].LastBar != 4 {
+		t.Errorf("Wanted one section with bars 2-4 got %+v", sections)
+	}
+	if view.mark != noMark {
+		t.Errorf("Wanted the mark to be cleared")
+	}
+}
+
+func TestSectionEditorRenameDisableDelete(t *testing.T) {
+	view := sectionEditor(t, db.NewInMemorySectionStore())
+
+	pressKeys(view, "tab", "down", "r")
+	if !view.renaming {
+		t.Errorf("Wanted renaming")
+		return
+	}
+	view.nameInput.SetValue("Chase")
+	pressKeys(view, "enter")
+	if view.renaming || view.sections[1].Name != "Chase" {
+		t.Errorf("Wanted section to be renamed got %+v", view.sections[1])
+	}
+
+	pressKeys(view, "r", "esc")
+	if view.renaming || view.sections[1].Name != "Chase" {
+		t.Errorf("Wanted rename to be cancelled got %+v", view.sections[1])
+	}
+
+	pressKeys(view, "d")
+	if !view.sections[1].Disabled {
+		t.Errorf("Wanted section to be disabled")
+	}
+	if !strings.Contains(view.View(), "(disabled)") {
+		t.Errorf("Wanted disabled section in view")
+	}
+
+	pressKeys(view, "down", "x")
+	if len(view.sections) != 2 || view.sectionCursor != 1 {
+		t.Errorf("Wanted last section deleted and cursor at 1 got %+v, cursor %d", view.sections, view.sectionCursor)
+	}
+}
+
+func TestSectionEditorEsc(t *testing.T) {
+	view := sectionEditor(t, db.NewInMemorySectionStore())
+	cmd := pressKeys(view, "esc")
+	if _, ok := cmd().(toLibraryContent); !ok {
+		t.Errorf("Wanted transition to library content")
+	}
+}
+
+type failingSectionStore struct{}
+
+func (f *failingSectionStore) SaveSections(fingerprint string, sections []db.PieceSection) error {
+	return errors.New("save failed")
+}
+
+func (f *failingSectionStore) LoadSections(fingerprint string) ([]db.PieceSection, error) {
+	return nil, errors.New("load failed")
+}
+
+func TestSectionEditorStoreErrors(t *testing.T) {
+	view := sectionEditor(t, &failingSectionStore{})
+	if view.status.kind != errorStatus {
+		t.Errorf("Wanted error status after failing load")
+	}
+
+	view.status = NewStatus()
+	pressKeys(view, "ctrl+s")
+	if view.status.kind != errorStatus || view.status.msg != "save failed" {
+		t.Errorf("Wanted error status after failing save got %+v", view.status)
+	}
+}
+
+func TestVisibleRange(t *testing.T) {
+	for _, test := range []struct {
+		cursor, num, height int
+		start, end          int
+	}{
+		{cursor: 0, num: 5, height: 10, start: 0, end: 5},
+		{cursor: 0, num: 20, height: 10, start: 0, end: 10},
+		{cursor: 12, num: 20, height: 10, start: 7, end: 17},
+		{cursor: 19, num: 20, height: 10, start: 10, end: 20},
+		{cursor: 3, num: 20, height: 0, start: 0, end: 20},
+	} {
+		start, end := visibleRange(test.cursor, test.num, test.height)
+		if start != test.start || end != test.end {
+			t.Errorf("Wanted (%d, %d) got (%d, %d) for %+v", test.start, test.end, start, end, test)
+		}
+	}
+}
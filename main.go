@@ -1,18 +1,68 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/davidkleiven/silent-score/internal/compose"
 	"github.com/davidkleiven/silent-score/internal/db"
+	"github.com/davidkleiven/silent-score/internal/interchange"
 	"github.com/davidkleiven/silent-score/internal/ui"
+	"gorm.io/gorm"
 )
 
+var errUnknownMigrateCommand = errors.New("-migrate must be 'status' or 'apply'")
+
 func main() {
 
-	config := ui.NewConfig()
+	excludeFailing := flag.Bool("exclude-failing", false, "Exclude pieces failing the library health check from matching")
+	sections := flag.String("sections", "rehearsal", "Section detection: 'rehearsal' (rehearsal marks only), 'auto' (detect when there are no rehearsal marks) or 'combined'")
+	minConfidence := flag.Float64("min-boundary-confidence", 0.4, "Confidence (0-1) a detected section boundary must have to be used")
+	tacetEmpty := flag.Bool("tacet-empty", false, "Give scenes with empty keywords no music")
+	scorePerReel := flag.Bool("score-per-reel", false, "Write one score per reel in addition to the combined score")
+	importFile := flag.String("import", "", "Create a project from the scenes of a .csv or .tsv file, the cues of an .srt or .vtt file or the events of an .edl file, and exit")
+	subtitleGap := flag.Duration("subtitle-gap", interchange.DefaultSubtitleGap, "Longest silence between two subtitles that is merged into the scene before it")
+	exportFile := flag.String("export", "", "Write the scenes of the project given by -project to a .csv or .tsv file and exit")
+	frameRate := flag.Float64("fps", interchange.DefaultFrameRate, "Frame rate of the timecodes in edit decision lists and marker files")
+	flag.Float64Var(frameRate, "edl-fps", interchange.DefaultFrameRate, "Same as -fps")
+	minShot := flag.Duration("min-shot", 0, "Merge shots of imported edit decision lists shorter than this into one scene")
+	projectDir := flag.String("project-dir", "", "Store projects as files in this directory instead of in the database")
+	projectFormat := flag.String("project-format", "yaml", "Format of project files: 'yaml' or 'json'")
+	projectName := flag.String("project", "", "Name of the project to import to or export from. Imports default to the file name")
+	migrate := flag.String("migrate", "", "Show the schema migrations of the database with 'status', or apply the pending ones with 'apply', and exit")
+	flag.Parse()
+
+	detection, err := compose.ParseSectionDetection(*sections)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fileFormat, err := db.ParseProjectFileFormat(*projectFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *migrate != "" && *migrate != "status" && *migrate != "apply" {
+		log.Fatal(errUnknownMigrateCommand)
+	}
+
+	edits := []ui.EditConfigFunc{
+		ui.WithExcludeFailingPieces(*excludeFailing),
+		ui.WithSectionDetection(detection, *minConfidence),
+		ui.WithTacetForEmptyKeywords(*tacetEmpty),
+		ui.WithScorePerReel(*scorePerReel),
+		ui.WithSubtitleGap(*subtitleGap),
+		ui.WithFrameRate(*frameRate),
+		ui.WithMinShotLength(*minShot),
+	}
+	if *projectDir != "" {
+		edits = append(edits, ui.WithProjectFiles(*projectDir, fileFormat))
+	}
+	config := ui.NewConfig(edits...)
 	os.Remove(config.LogFile)
 	f, err := tea.LogToFile(config.LogFile, "")
 	if err != nil {
@@ -26,14 +76,82 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err = db.AutoMigrate(programDb); err != nil {
+	if *migrate != "" {
+		if err := migrateCommand(programDb, *migrate); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if _, err = db.Migrate(programDb); err != nil {
 		log.Fatal(err)
 	}
 
-	model := ui.NewAppModel(&db.GormStore{Database: programDb})
+	store := config.Store(programDb)
+	if *importFile != "" {
+		project, err := interchange.ImportFile(store, *importFile, *projectName, config.ImportOpts()...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Imported %d scenes into %s\n", len(project.Records), project.Name)
+		return
+	}
+	if *exportFile != "" {
+		if err := interchange.ExportProject(store, *projectName, *exportFile); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Exported %s to %s\n", *projectName, *exportFile)
+		return
+	}
+
+	model := ui.NewAppModel(store, edits...)
 	program := tea.NewProgram(model)
 
 	if _, err := program.Run(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// migrateCommand lists the applied and pending migrations, or applies the
+// pending migrations
+func migrateCommand(con *gorm.DB, command string) error {
+	pending, err := db.PendingMigrations(con)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "status":
+		applied, err := db.AppliedMigrations(con)
+		if err != nil {
+			return err
+		}
+		version, err := db.CurrentSchemaVersion(con)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schema version %d with %d pending migrations\n", version, len(pending))
+		for _, migration := range applied {
+			fmt.Printf("  applied  %3d  %s  %s\n", migration.Version, migration.AppliedAt.Format(time.DateTime), migration.Description)
+		}
+		for _, migration := range pending {
+			fmt.Printf("  pending  %3d  %s\n", migration.Version, migration.Description)
+		}
+	case "apply":
+		backup, err := db.Migrate(con)
+		if backup != "" {
+			fmt.Printf("Backed up the database to %s\n", backup)
+		}
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			fmt.Printf("Applied migration %d: %s\n", migration.Version, migration.Description)
+		}
+		if len(pending) == 0 {
+			fmt.Println("The database is up to date")
+		}
+	default:
+		return errUnknownMigrateCommand
+	}
+	return nil
+}
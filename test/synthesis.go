@@ -15,6 +15,10 @@ func GenerateProjectContentRecord(t *rapid.T, projectId uint) db.ProjectContentR
 		Keywords:    stringSampler.Draw(t, "keywords"),
 		Tempo:       rapid.UintMax(200).Draw(t, "tempo"),
 		Theme:       rapid.UintMax(20).Draw(t, "theme"),
+		Intensity:   rapid.UintMax(5).Draw(t, "intensity"),
+		Reel:        rapid.UintMax(3).Draw(t, "reel"),
+		Timecode:    rapid.SampledFrom([]string{"", "00:01:30", "01:00:10.5"}).Draw(t, "timecode"),
+		Pinned:      rapid.SampledFrom([]string{"", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}).Draw(t, "pinned"),
 	}
 }
 